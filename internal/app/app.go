@@ -6,12 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/memkit/repodex/internal/cachex"
 	"github.com/memkit/repodex/internal/cli"
 	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/depgraph"
 	"github.com/memkit/repodex/internal/fetch"
 	"github.com/memkit/repodex/internal/gitx"
 	"github.com/memkit/repodex/internal/hash"
@@ -19,12 +19,14 @@ import (
 	"github.com/memkit/repodex/internal/index"
 	"github.com/memkit/repodex/internal/lang"
 	"github.com/memkit/repodex/internal/lang/factory"
+	"github.com/memkit/repodex/internal/lockx"
 	"github.com/memkit/repodex/internal/profile"
 	"github.com/memkit/repodex/internal/scan"
 	"github.com/memkit/repodex/internal/search"
 	"github.com/memkit/repodex/internal/serve"
 	"github.com/memkit/repodex/internal/statusx"
 	"github.com/memkit/repodex/internal/store"
+	"github.com/memkit/repodex/internal/symbols"
 	"github.com/memkit/repodex/internal/textutil"
 	"github.com/memkit/repodex/internal/tokenize"
 )
@@ -52,6 +54,9 @@ type StatusResponse struct {
 
 	SchemaVersion  int    `json:"schema_version,omitempty"`
 	RepodexVersion string `json:"repodex_version,omitempty"`
+	// Generation names the published index build under .repodex/gen; empty
+	// for indexes written in the flat layout.
+	Generation string `json:"generation,omitempty"`
 
 	GitBaseHead         string   `json:"git_base_head,omitempty"`
 	GitCurrentHead      string   `json:"git_current_head,omitempty"`
@@ -62,6 +67,23 @@ type StatusResponse struct {
 	GitChangedReason    string            `json:"git_changed_reason,omitempty"`
 	GitChangedIndexable bool              `json:"git_changed_indexable,omitempty"`
 	SyncPlan            *statusx.SyncPlan `json:"sync_plan,omitempty"`
+
+	// Limits reports the effective search and fetch limits from config.
+	Limits *LimitsStatus `json:"limits,omitempty"`
+	// ScanIgnore lists the effective scan ignore patterns with their sources,
+	// in the order they are applied.
+	ScanIgnore []profile.IgnorePattern `json:"scan_ignore,omitempty"`
+	// SyncInProgress is the owner of the sync lock while a sync runs.
+	SyncInProgress *lockx.Owner `json:"sync_in_progress,omitempty"`
+}
+
+// LimitsStatus mirrors config.LimitsConfig for the status payload.
+type LimitsStatus struct {
+	MaxTopK       int `json:"max_top_k"`
+	MaxPerFile    int `json:"max_per_file"`
+	FetchMaxIDs   int `json:"fetch_max_ids"`
+	FetchMaxLines int `json:"fetch_max_lines"`
+	MaxReferences int `json:"max_references"`
 }
 
 // Run executes the CLI app and returns an exit code.
@@ -92,19 +114,75 @@ func Run(args []string) int {
 		}
 		return 0
 	case "sync":
-		if err := runIndexSync(repoRoot); err != nil {
+		if err := runIndexSync(repoRoot, !cmd.NoWait); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "verify":
+		if err := runVerify(repoRoot, cmd.JSON); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			return 1
 		}
 		return 0
 	case "search":
-		if err := runSearch(repoRoot, cmd.Q, cmd.TopK); err != nil {
+		if err := runSearch(repoRoot, cmd.Q, search.Options{TopK: cmd.TopK, After: cmd.Cursor, Collapse: cmd.Collapse}); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			return 1
 		}
 		return 0
 	case "fetch":
-		if err := runFetch(repoRoot, cmd.IDs, cmd.MaxLines); err != nil {
+		opts := fetch.Options{MaxLines: cmd.MaxLines, Before: cmd.Before, After: cmd.After, Mode: cmd.Mode, Rev: cmd.Rev, MaxBytes: cmd.MaxBytes, MaxTokens: cmd.MaxTokens}
+		if cmd.Path != "" {
+			if err := runFetchRange(repoRoot, cmd.Path, cmd.StartLine, cmd.EndLine, opts); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			return 0
+		}
+		if err := runFetch(repoRoot, cmd.IDs, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "git_diff":
+		if err := runGitDiff(repoRoot, cmd.IDs, cmd.Path, cmd.StartLine, cmd.EndLine, cmd.Rev); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "symbols":
+		if err := runSymbols(repoRoot, cmd.Name, cmd.Kind); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "definition":
+		if err := runDefinition(repoRoot, symbols.Target{Name: cmd.Name, Path: cmd.Path, Line: cmd.Line}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "references":
+		if err := runReferences(repoRoot, symbols.Target{Name: cmd.Name, Path: cmd.Path, Line: cmd.Line}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "imports":
+		if err := runImports(repoRoot, cmd.Path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "importers":
+		if err := runImporters(repoRoot, cmd.Path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "outline":
+		if err := runOutline(repoRoot, cmd.Path); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			return 1
 		}
@@ -112,7 +190,7 @@ func Run(args []string) int {
 	case "index":
 		switch cmd.Subcommand {
 		case "sync":
-			if err := runIndexSync(repoRoot); err != nil {
+			if err := runIndexSync(repoRoot, !cmd.NoWait); err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				return 1
 			}
@@ -173,7 +251,7 @@ func runInit(root string, force bool) error {
 	if err != nil {
 		return err
 	}
-	cfgHash := combinedConfigHash(cfgBytes, rules.RulesHash)
+	cfgHash := combinedConfigHash(cfgBytes, rules.RulesHash, depgraph.ConfigHash(root))
 	meta := store.NewMeta(cfg.IndexVersion, 0, 0, 0, cfgHash, repoHead)
 	if err := store.SaveMeta(store.MetaPath(root), meta); err != nil {
 		return err
@@ -182,7 +260,8 @@ func runInit(root string, force bool) error {
 }
 
 func precomputedFromCache(entry cachex.CacheEntry) (index.PrecomputedFile, error) {
-	if len(entry.Chunks) != len(entry.Tokens) {
+	if len(entry.Chunks) != len(entry.Tokens) || len(entry.Chunks) != len(entry.TermFreqs) ||
+		len(entry.Chunks) != len(entry.Positions) || len(entry.Chunks) != len(entry.TokenCounts) {
 		return index.PrecomputedFile{}, fmt.Errorf("cache invalid for %s: chunk/token length mismatch", entry.RelPath)
 	}
 	const maxU32 = uint64(^uint32(0))
@@ -194,19 +273,49 @@ func precomputedFromCache(entry cachex.CacheEntry) (index.PrecomputedFile, error
 		if uint64(ch.Start) > maxU32 || uint64(ch.End) > maxU32 {
 			return index.PrecomputedFile{}, fmt.Errorf("cache invalid for %s: invalid chunk line range", entry.RelPath)
 		}
+		if len(entry.Tokens[idx]) != len(entry.TermFreqs[idx]) || len(entry.Tokens[idx]) != len(entry.Positions[idx]) {
+			return index.PrecomputedFile{}, fmt.Errorf("cache invalid for %s: token/frequency length mismatch", entry.RelPath)
+		}
 		chunks = append(chunks, index.PrecomputedChunk{
-			StartLine: uint32(ch.Start),
-			EndLine:   uint32(ch.End),
-			Snippet:   ch.Snippet,
-			Tokens:    entry.Tokens[idx],
+			StartLine:  uint32(ch.Start),
+			EndLine:    uint32(ch.End),
+			Snippet:    ch.Snippet,
+			Tokens:     entry.Tokens[idx],
+			TermFreqs:  entry.TermFreqs[idx],
+			Positions:  entry.Positions[idx],
+			TokenCount: entry.TokenCounts[idx],
 		})
 	}
+	symbols := make([]lang.Symbol, 0, len(entry.Symbols))
+	for _, sym := range entry.Symbols {
+		if sym.Start < 1 || sym.End < sym.Start || uint64(sym.End) > maxU32 {
+			return index.PrecomputedFile{}, fmt.Errorf("cache invalid for %s: invalid symbol line range", entry.RelPath)
+		}
+		symbols = append(symbols, lang.Symbol{
+			Name:      sym.Name,
+			Kind:      sym.Kind,
+			Parent:    sym.Parent,
+			Exported:  sym.Exported,
+			StartLine: uint32(sym.Start),
+			EndLine:   uint32(sym.End),
+		})
+	}
+	imports := make([]lang.Import, 0, len(entry.Imports))
+	for _, imp := range entry.Imports {
+		if imp.Line < 1 || uint64(imp.Line) > maxU32 {
+			return index.PrecomputedFile{}, fmt.Errorf("cache invalid for %s: invalid import line", entry.RelPath)
+		}
+		imports = append(imports, lang.Import{Specifier: imp.Specifier, Kind: imp.Kind, Line: uint32(imp.Line)})
+	}
 	return index.PrecomputedFile{
-		Path:   filepath.ToSlash(entry.RelPath),
-		MTime:  entry.MTime,
-		Size:   entry.Size,
-		Hash64: entry.Hash64,
-		Chunks: chunks,
+		Path:     filepath.ToSlash(entry.RelPath),
+		MTime:    entry.MTime,
+		Size:     entry.Size,
+		Hash64:   entry.Hash64,
+		Chunks:   chunks,
+		Symbols:  symbols,
+		Imports:  imports,
+		Snapshot: entry.Snapshot,
 	}, nil
 }
 
@@ -229,18 +338,21 @@ func buildCacheEntry(ref scan.FileRef, plugin lang.LanguagePlugin, cfg config.Co
 	lineTokens := make([][]string, len(lines))
 	if tokenCfg.TokenizeStringLiterals {
 		for i, line := range lines {
-			lineTokens[i] = tokenizer.Text(line)
+			lineTokens[i] = tokenizer.Sequence(line)
 		}
 	} else {
 		var st tokenize.StringScanState
 		for i, line := range lines {
-			lineTokens[i] = tokenizer.TextWithState(line, &st)
+			lineTokens[i] = tokenizer.SequenceWithState(line, &st)
 		}
 	}
 
 	precomputedChunks := make([]index.PrecomputedChunk, 0, len(chunkDrafts))
 	cacheChunks := make([]cachex.LocalChunk, 0, len(chunkDrafts))
 	tokenSets := make([][]string, 0, len(chunkDrafts))
+	freqSets := make([][]uint32, 0, len(chunkDrafts))
+	positionSets := make([][][]uint32, 0, len(chunkDrafts))
+	tokenCounts := make([]uint32, 0, len(chunkDrafts))
 
 	for _, ch := range chunkDrafts {
 		start := int(ch.StartLine)
@@ -251,26 +363,20 @@ func buildCacheEntry(ref scan.FileRef, plugin lang.LanguagePlugin, cfg config.Co
 		if end > len(lines) {
 			end = len(lines)
 		}
-		tokenSet := make(map[string]struct{}, len(pathTokens))
-		for _, tok := range pathTokens {
-			tokenSet[tok] = struct{}{}
-		}
+		var textTokens []string
 		for idx := start - 1; idx < end && idx >= 0 && idx < len(lineTokens); idx++ {
-			for _, tok := range lineTokens[idx] {
-				tokenSet[tok] = struct{}{}
-			}
+			textTokens = append(textTokens, lineTokens[idx]...)
 		}
 		// Invariant: tokens are unique and sorted to keep downstream index building deterministic.
-		tokens := make([]string, 0, len(tokenSet))
-		for tok := range tokenSet {
-			tokens = append(tokens, tok)
-		}
-		sort.Strings(tokens)
+		tokens, freqs, positions, total := index.TermCounts(pathTokens, textTokens)
 		precomputedChunks = append(precomputedChunks, index.PrecomputedChunk{
-			StartLine: ch.StartLine,
-			EndLine:   ch.EndLine,
-			Snippet:   ch.Snippet,
-			Tokens:    tokens,
+			StartLine:  ch.StartLine,
+			EndLine:    ch.EndLine,
+			Snippet:    ch.Snippet,
+			Tokens:     tokens,
+			TermFreqs:  freqs,
+			Positions:  positions,
+			TokenCount: total,
 		})
 		cacheChunks = append(cacheChunks, cachex.LocalChunk{
 			Start:   int(ch.StartLine),
@@ -278,27 +384,75 @@ func buildCacheEntry(ref scan.FileRef, plugin lang.LanguagePlugin, cfg config.Co
 			Snippet: ch.Snippet,
 		})
 		tokenSets = append(tokenSets, tokens)
+		freqSets = append(freqSets, freqs)
+		positionSets = append(positionSets, positions)
+		tokenCounts = append(tokenCounts, total)
+	}
+
+	symbols := plugin.Symbols(ref.RelPath, normalized)
+	cacheSymbols := make([]cachex.LocalSymbol, 0, len(symbols))
+	for _, sym := range symbols {
+		cacheSymbols = append(cacheSymbols, cachex.LocalSymbol{
+			Name:     sym.Name,
+			Kind:     sym.Kind,
+			Parent:   sym.Parent,
+			Exported: sym.Exported,
+			Start:    int(sym.StartLine),
+			End:      int(sym.EndLine),
+		})
+	}
+
+	imports := plugin.Imports(ref.RelPath, normalized)
+	cacheImports := make([]cachex.LocalImport, 0, len(imports))
+	for _, imp := range imports {
+		cacheImports = append(cacheImports, cachex.LocalImport{Specifier: imp.Specifier, Kind: imp.Kind, Line: int(imp.Line)})
+	}
+
+	var snapshot []byte
+	if cfg.Fetch.Snapshots {
+		snapshot, err = index.CompressSnapshot(normalized)
+		if err != nil {
+			return index.PrecomputedFile{}, cachex.CacheEntry{}, err
+		}
 	}
 
 	file := index.PrecomputedFile{
-		Path:   filepath.ToSlash(ref.RelPath),
-		MTime:  ref.MTime,
-		Size:   ref.Size,
-		Hash64: hash64,
-		Chunks: precomputedChunks,
+		Path:     filepath.ToSlash(ref.RelPath),
+		MTime:    ref.MTime,
+		Size:     ref.Size,
+		Hash64:   hash64,
+		Chunks:   precomputedChunks,
+		Symbols:  symbols,
+		Imports:  imports,
+		Snapshot: snapshot,
 	}
 	cacheEntry := cachex.CacheEntry{
-		RelPath: filepath.ToSlash(ref.RelPath),
-		Size:    ref.Size,
-		MTime:   ref.MTime,
-		Hash64:  hash64,
-		Chunks:  cacheChunks,
-		Tokens:  tokenSets,
+		RelPath:     filepath.ToSlash(ref.RelPath),
+		Size:        ref.Size,
+		MTime:       ref.MTime,
+		Hash64:      hash64,
+		Chunks:      cacheChunks,
+		Tokens:      tokenSets,
+		TermFreqs:   freqSets,
+		Positions:   positionSets,
+		TokenCounts: tokenCounts,
+		Symbols:     cacheSymbols,
+		Imports:     cacheImports,
+		Snapshot:    snapshot,
 	}
 	return file, cacheEntry, nil
 }
 
-func runIndexSync(root string) error {
+// runIndexSync rebuilds the index under the sync lock. When another sync
+// holds the lock it waits for it to finish if wait is set, and otherwise
+// fails with a *lockx.HeldError.
+func runIndexSync(root string, wait bool) error {
+	lock, err := acquireSyncLock(root, wait)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
 	st, err := computeStatusResolved(root)
 	if err != nil {
 		return err
@@ -316,7 +470,7 @@ func runIndexSync(root string) error {
 	if err != nil {
 		return err
 	}
-	cfgHash := combinedConfigHash(cfgBytes, rules.RulesHash)
+	cfgHash := combinedConfigHash(cfgBytes, rules.RulesHash, depgraph.ConfigHash(root))
 
 	plugin, err := factory.FromProjectType(cfg.ProjectType)
 	if err != nil {
@@ -392,18 +546,86 @@ func runIndexSync(root string) error {
 		return err
 	}
 
-	if err := index.Serialize(root, fileEntries, chunkEntries, postings); err != nil {
+	// Build into a new generation and publish it only once it is complete, so
+	// readers never see a half-written or mixed index.
+	gen, lease, err := store.NewGeneration(root)
+	if err != nil {
 		return err
 	}
+	defer lease.Release()
+	published := false
+	defer func() {
+		if !published {
+			_ = os.RemoveAll(gen.Dir)
+		}
+	}()
+
+	if err := index.SerializeGeneration(gen, fileEntries, chunkEntries, postings); err != nil {
+		return err
+	}
+	if err := index.WriteSymbols(gen.SymbolsPath(), index.SymbolsFromPrecomputed(fileEntries, precomputed)); err != nil {
+		return err
+	}
+	imports, err := depgraph.FromPrecomputed(root, fileEntries, precomputed)
+	if err != nil {
+		return err
+	}
+	if err := index.WriteImports(gen.ImportsPath(), imports); err != nil {
+		return err
+	}
+	if cfg.Fetch.Snapshots {
+		if err := index.WriteSnapshots(gen.SnapshotsPath(), index.SnapshotsFromPrecomputed(fileEntries, precomputed)); err != nil {
+			return err
+		}
+	}
 
 	repoHead := currentRepoHead(root)
 	meta := store.NewMeta(cfg.IndexVersion, len(fileEntries), len(chunkEntries), len(postings), cfgHash, repoHead)
-	if err := store.SaveMeta(store.MetaPath(root), meta); err != nil {
+	if err := store.Publish(root, gen, meta); err != nil {
 		return err
 	}
+	published = true
+	// Collection is best effort: a generation left behind now is removed by
+	// the next sync once its readers are gone.
+	_, _ = store.CollectGenerations(root)
 	return nil
 }
 
+// acquireSyncLock takes the sync lock. Without a .repodex directory there is
+// nothing to protect and no lock is taken; the sync then fails on the
+// missing config.
+func acquireSyncLock(root string, wait bool) (*lockx.Lock, error) {
+	if _, err := os.Stat(store.Dir(root)); os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err := store.EnsureLeasesDir(root); err != nil {
+		return nil, err
+	}
+	path := store.SyncLockPath(root)
+	var lock *lockx.Lock
+	var err error
+	if wait {
+		lock, err = lockx.AcquireWait(path, func(owner lockx.Owner) {
+			fmt.Fprintf(os.Stderr, "waiting for the sync run by pid %d on %s to finish\n", owner.PID, owner.Host)
+		})
+	} else {
+		lock, err = lockx.TryAcquire(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sync in progress: %w", err)
+	}
+	return lock, nil
+}
+
+// syncHolder returns the owner of a running sync, if any.
+func syncHolder(root string) *lockx.Owner {
+	owner, held, err := lockx.Holder(store.SyncLockPath(root))
+	if err != nil || !held {
+		return nil
+	}
+	return &owner
+}
+
 func runStatus(root string, jsonOut bool) error {
 	resp, err := computeStatusResolved(root)
 	if err != nil {
@@ -457,6 +679,9 @@ func computeStatusResolved(root string) (StatusResponse, error) {
 	chunksPath := store.ChunksPath(root)
 	termsPath := store.TermsPath(root)
 	postingsPath := store.PostingsPath(root)
+	positionsPath := store.PositionsPath(root)
+	symbolsPath := store.SymbolsPath(root)
+	importsPath := store.ImportsPath(root)
 	cfgPath := store.ConfigPath(root)
 
 	metaExists, err := fileExistsOk(metaPath)
@@ -479,8 +704,20 @@ func computeStatusResolved(root string) (StatusResponse, error) {
 	if err != nil {
 		return StatusResponse{}, err
 	}
+	positionsExists, err := fileExistsOk(positionsPath)
+	if err != nil {
+		return StatusResponse{}, err
+	}
+	symbolsExists, err := fileExistsOk(symbolsPath)
+	if err != nil {
+		return StatusResponse{}, err
+	}
+	importsExists, err := fileExistsOk(importsPath)
+	if err != nil {
+		return StatusResponse{}, err
+	}
 
-	if !metaExists || !filesExists || !chunksExists || !termsExists || !postingsExists {
+	if !metaExists || !filesExists || !chunksExists || !termsExists || !postingsExists || !positionsExists || !symbolsExists || !importsExists {
 		var meta store.Meta
 		if metaExists {
 			if loaded, err := store.LoadMeta(metaPath); err == nil {
@@ -501,6 +738,7 @@ func computeStatusResolved(root string) (StatusResponse, error) {
 			ChangedFiles:  0,
 		}
 		applyGitInfo(&resp, gitInfo)
+		resp.SyncInProgress = syncHolder(root)
 		resp.SyncPlan = &statusx.SyncPlan{
 			Mode:             statusx.ModeFull,
 			Why:              statusx.WhyMissingIndex,
@@ -535,7 +773,7 @@ func computeStatusResolved(root string) (StatusResponse, error) {
 	if err != nil {
 		return StatusResponse{}, err
 	}
-	cfgHash := combinedConfigHash(cfgBytes, rules.RulesHash)
+	cfgHash := combinedConfigHash(cfgBytes, rules.RulesHash, depgraph.ConfigHash(root))
 
 	plan := statusx.BuildSyncPlan(meta, cfgHash, gitInfo)
 
@@ -549,9 +787,19 @@ func computeStatusResolved(root string) (StatusResponse, error) {
 		ChangedFiles:   plan.ChangedPathCount,
 		SchemaVersion:  meta.SchemaVersion,
 		RepodexVersion: meta.RepodexVersion,
+		Generation:     meta.Generation,
 	}
 	applyGitInfo(&resp, gitInfo)
 	resp.SyncPlan = plan
+	resp.SyncInProgress = syncHolder(root)
+	resp.Limits = &LimitsStatus{
+		MaxTopK:       cfg.Limits.MaxTopK,
+		MaxPerFile:    cfg.Limits.MaxPerFile,
+		FetchMaxIDs:   cfg.Limits.FetchMaxIDs,
+		FetchMaxLines: cfg.Limits.FetchMaxLines,
+		MaxReferences: cfg.Limits.MaxReferences,
+	}
+	resp.ScanIgnore = rules.IgnoreSources
 	return resp, nil
 }
 
@@ -579,11 +827,13 @@ func applyGitInfo(resp *StatusResponse, info statusx.GitInfo) {
 	}
 }
 
-func combinedConfigHash(cfgBytes []byte, rulesHash uint64) uint64 {
-	buf := make([]byte, 8)
-	binary.LittleEndian.PutUint64(buf, rulesHash)
+// combinedConfigHash covers everything besides file contents that shapes the
+// index: the config, the effective scan rules, and the tsconfig and
+// package.json files import resolution reads.
+func combinedConfigHash(cfgBytes []byte, rulesHash, resolverHash uint64) uint64 {
 	payload := append([]byte{}, cfgBytes...)
-	payload = append(payload, buf...)
+	payload = binary.LittleEndian.AppendUint64(payload, rulesHash)
+	payload = binary.LittleEndian.AppendUint64(payload, resolverHash)
 	return hash.Sum64(payload)
 }
 
@@ -592,7 +842,7 @@ func runServeStdio(root string) error {
 		return computeStatusResolved(root)
 	}
 	syncFn := func() (interface{}, error) {
-		if err := runIndexSync(root); err != nil {
+		if err := runIndexSync(root, false); err != nil {
 			return nil, err
 		}
 		return computeStatusResolved(root)
@@ -600,11 +850,11 @@ func runServeStdio(root string) error {
 	return serve.ServeStdio(root, statusFn, syncFn)
 }
 
-func runSearch(root string, q string, topK int) error {
+func runSearch(root string, q string, opts search.Options) error {
 	if q == "" {
 		return fmt.Errorf("query cannot be empty")
 	}
-	results, err := search.Search(root, q, search.Options{TopK: topK})
+	results, err := search.Search(root, q, opts)
 	if err != nil {
 		return err
 	}
@@ -612,11 +862,71 @@ func runSearch(root string, q string, topK int) error {
 	return enc.Encode(results)
 }
 
-func runFetch(root string, ids []uint32, maxLines int) error {
+func runFetch(root string, ids []uint32, opts fetch.Options) error {
 	if len(ids) == 0 {
 		return fmt.Errorf("at least one id is required")
 	}
-	results, err := fetch.Fetch(root, ids, maxLines)
+	results, err := fetch.Fetch(root, ids, opts)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(results)
+}
+
+func runFetchRange(root string, path string, startLine int, endLine int, opts fetch.Options) error {
+	result, err := fetch.FetchRange(root, path, startLine, endLine, opts)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(result)
+}
+
+func runGitDiff(root string, ids []uint32, path string, startLine int, endLine int, rev string) error {
+	var result interface{}
+	var err error
+	if len(ids) > 0 {
+		result, err = fetch.DiffChunks(root, ids, rev)
+	} else {
+		result, err = fetch.DiffRange(root, path, startLine, endLine, rev)
+	}
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(result)
+}
+
+func runSymbols(root string, name string, kind string) error {
+	results, err := symbols.Lookup(root, symbols.Query{Name: name, Kind: kind})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(results)
+}
+
+func runDefinition(root string, target symbols.Target) error {
+	results, err := symbols.LookupDefinitions(root, target)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(results)
+}
+
+func runReferences(root string, target symbols.Target) error {
+	results, err := symbols.LookupReferences(root, target)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(results)
+}
+
+func runImports(root string, path string) error {
+	results, err := depgraph.LookupImports(root, path)
 	if err != nil {
 		return err
 	}
@@ -624,6 +934,85 @@ func runFetch(root string, ids []uint32, maxLines int) error {
 	return enc.Encode(results)
 }
 
+func runImporters(root string, path string) error {
+	results, err := depgraph.LookupImporters(root, path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(results)
+}
+
+func runOutline(root string, path string) error {
+	outline, err := symbols.LookupOutline(root, path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(outline)
+}
+
+// runVerify checks the current index generation and prints the report. It
+// fails when any problem was found.
+func runVerify(root string, jsonOut bool) error {
+	gen, meta, lease, err := acquireIndex(root)
+	if err != nil {
+		return err
+	}
+	defer lease.Release()
+
+	report := index.Verify(gen, meta)
+	if jsonOut {
+		if err := json.NewEncoder(os.Stdout).Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, a := range report.Artifacts {
+			switch {
+			case a.Info != nil:
+				fmt.Printf("ok       %-14s %s v%d, %d bytes, crc %08x\n", a.Name, a.Info.Kind, a.Info.Version, a.Info.Size, a.Info.CRC)
+			case a.Missing:
+				fmt.Printf("absent   %s\n", a.Name)
+			default:
+				fmt.Printf("FAILED   %-14s %s\n", a.Name, a.Error)
+			}
+		}
+		for _, p := range report.Problems {
+			fmt.Printf("problem: %s\n", p)
+		}
+	}
+	if !report.OK {
+		return fmt.Errorf("index verification failed: %d problems", len(report.Problems))
+	}
+	return nil
+}
+
+// acquireIndex leases the current generation together with the meta.json
+// that published it, retrying if a sync publishes in between.
+func acquireIndex(root string) (store.Generation, store.Meta, *store.Lease, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		gen, lease, err := store.AcquireCurrent(root)
+		if err != nil {
+			return store.Generation{}, store.Meta{}, nil, err
+		}
+		meta, err := store.LoadMeta(store.MetaPath(root))
+		if err != nil {
+			lease.Release()
+			if os.IsNotExist(err) {
+				return store.Generation{}, store.Meta{}, nil, fmt.Errorf("no index found; run sync first")
+			}
+			return store.Generation{}, store.Meta{}, nil, err
+		}
+		// An unusable Generation keeps resolving to the same layout; Verify
+		// reports the mismatch.
+		if meta.Generation == gen.Name || store.Current(root) == gen {
+			return gen, meta, lease, nil
+		}
+		lease.Release()
+	}
+	return store.Generation{}, store.Meta{}, nil, fmt.Errorf("index generation changed while opening it")
+}
+
 func currentRepoHead(root string) string {
 	isRepo, err := gitx.IsRepo(root)
 	if err != nil || !isRepo {
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +11,11 @@ import (
 	"testing"
 
 	"github.com/memkit/repodex/internal/cachex"
+	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/depgraph"
+	"github.com/memkit/repodex/internal/fetch"
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/lockx"
 	"github.com/memkit/repodex/internal/search"
 	"github.com/memkit/repodex/internal/statusx"
 	"github.com/memkit/repodex/internal/store"
@@ -138,7 +144,7 @@ func TestComputeStatusCRLFFileNotDirtyAfterSync(t *testing.T) {
 		t.Fatalf("runInit failed: %v", err)
 	}
 
-	if err := runIndexSync(root); err != nil {
+	if err := runIndexSync(root, false); err != nil {
 		t.Fatalf("runIndexSync failed: %v", err)
 	}
 
@@ -175,6 +181,9 @@ func TestComputeStatusGitFastPathWhenClean(t *testing.T) {
 	if !resp.HeadMatches {
 		t.Fatalf("expected HeadMatches=true")
 	}
+	if resp.Limits == nil || resp.Limits.MaxTopK != config.DefaultMaxTopK || resp.Limits.FetchMaxLines != config.DefaultFetchMaxLines {
+		t.Fatalf("expected default limits in status, got %+v", resp.Limits)
+	}
 }
 
 func TestComputeStatusGitDirtyRepodexOnly(t *testing.T) {
@@ -317,6 +326,9 @@ func TestIncrementalSyncDoesNotReadUnchangedFiles(t *testing.T) {
 	for i := 1; i <= 3; i++ {
 		name := fmt.Sprintf("file%d.ts", i)
 		content := fmt.Sprintf("export const value%d = %d;\n", i, i)
+		if i == 2 {
+			content = "import { value3 } from './file3';\n" + content
+		}
 		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
 			t.Fatalf("write %s: %v", name, err)
 		}
@@ -327,7 +339,7 @@ func TestIncrementalSyncDoesNotReadUnchangedFiles(t *testing.T) {
 	if err := runInit(root, false); err != nil {
 		t.Fatalf("runInit failed: %v", err)
 	}
-	if err := runIndexSync(root); err != nil {
+	if err := runIndexSync(root, false); err != nil {
 		t.Fatalf("initial sync failed: %v", err)
 	}
 
@@ -347,7 +359,7 @@ func TestIncrementalSyncDoesNotReadUnchangedFiles(t *testing.T) {
 		t.Fatalf("modify file1: %v", err)
 	}
 
-	if err := runIndexSync(root); err != nil {
+	if err := runIndexSync(root, false); err != nil {
 		t.Fatalf("incremental sync failed: %v", err)
 	}
 
@@ -366,13 +378,344 @@ func TestIncrementalSyncDoesNotReadUnchangedFiles(t *testing.T) {
 		t.Fatalf("expected per-file cache entries to be created")
 	}
 
-	results, err := search.Search(root, "value1", search.Options{TopK: 3})
+	resp, err := search.Search(root, "value1", search.Options{TopK: 3})
 	if err != nil {
 		t.Fatalf("search: %v", err)
 	}
+	results := resp.Results
 	if len(results) == 0 {
 		t.Fatalf("expected search results after incremental sync")
 	}
+
+	// file2.ts is unreadable, so its imports must come from the cache.
+	importers, err := depgraph.LookupImporters(root, "file3.ts")
+	if err != nil {
+		t.Fatalf("importers: %v", err)
+	}
+	if len(importers.Importers) != 1 || importers.Importers[0].Path != "file2.ts" {
+		t.Fatalf("expected cached import edge from file2.ts, got %+v", importers)
+	}
+}
+
+func TestTSConfigPathsEditResyncsImports(t *testing.T) {
+	requireGit(t)
+
+	root := t.TempDir()
+	runGit(t, root, "init")
+	runGit(t, root, "config", "user.email", "test@example.com")
+	runGit(t, root, "config", "user.name", "Test User")
+	// The paths live in a shared config under the git-ignored node_modules,
+	// so editing it leaves the worktree clean.
+	base := "node_modules/@acme/tsconfig/base.json"
+	sources := map[string]string{
+		".gitignore":     "node_modules\n",
+		"src/app.ts":     "import { a } from '@lib/a';\n",
+		"src/lib/a.ts":   "export const a = 1;\n",
+		"src/other/a.ts": "export const a = 2;\n",
+		"tsconfig.json":  `{ "extends": "@acme/tsconfig/base.json" }`,
+		base:             `{ "compilerOptions": { "paths": { "@lib/*": ["../../../src/lib/*"] } } }`,
+	}
+	for rel, content := range sources {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	runGit(t, root, "add", ".")
+	runGit(t, root, "commit", "-m", "initial files")
+	if err := runInit(root, false); err != nil {
+		t.Fatalf("runInit failed: %v", err)
+	}
+	if err := runIndexSync(root, false); err != nil {
+		t.Fatalf("initial sync failed: %v", err)
+	}
+	resolved := func() string {
+		t.Helper()
+		imports, err := depgraph.LookupImports(root, "src/app.ts")
+		if err != nil || len(imports.Imports) != 1 {
+			t.Fatalf("imports: %+v (%v)", imports, err)
+		}
+		return imports.Imports[0].Resolved
+	}
+	if got := resolved(); got != "src/lib/a.ts" {
+		t.Fatalf("expected @lib/a to resolve to src/lib/a.ts, got %q", got)
+	}
+
+	remapped := `{ "compilerOptions": { "paths": { "@lib/*": ["../../../src/other/*"] } } }`
+	if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(base)), []byte(remapped), 0o644); err != nil {
+		t.Fatalf("write tsconfig: %v", err)
+	}
+	resp, err := computeStatusResolved(root)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if resp.SyncPlan == nil || resp.SyncPlan.Why != statusx.WhyConfigChanged {
+		t.Fatalf("expected a tsconfig paths edit to change the config hash, got %+v", resp.SyncPlan)
+	}
+	if err := runIndexSync(root, false); err != nil {
+		t.Fatalf("resync failed: %v", err)
+	}
+	if got := resolved(); got != "src/other/a.ts" {
+		t.Fatalf("expected @lib/a to resolve to src/other/a.ts after resync, got %q", got)
+	}
+}
+
+func TestSnapshotFetchForStaleFiles(t *testing.T) {
+	requireGit(t)
+
+	root := t.TempDir()
+	runGit(t, root, "init")
+	runGit(t, root, "config", "user.email", "test@example.com")
+	runGit(t, root, "config", "user.name", "Test User")
+	for _, name := range []string{"a.ts", "b.ts"} {
+		content := fmt.Sprintf("export function %sValue() {\n  return 1;\n}\n", strings.TrimSuffix(name, ".ts"))
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	runGit(t, root, "add", ".")
+	runGit(t, root, "commit", "-m", "initial files")
+
+	if err := runInit(root, false); err != nil {
+		t.Fatalf("runInit failed: %v", err)
+	}
+	cfg, _, err := config.Load(store.ConfigPath(root))
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.Fetch.Snapshots = true
+	if err := config.Save(store.ConfigPath(root), cfg); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+	if err := runIndexSync(root, false); err != nil {
+		t.Fatalf("initial sync failed: %v", err)
+	}
+
+	// a.ts changes and is re-synced, so b.ts's snapshot must be carried over from the cache.
+	if err := os.WriteFile(filepath.Join(root, "a.ts"), []byte("export const a = 2;\n"), 0o644); err != nil {
+		t.Fatalf("modify a.ts: %v", err)
+	}
+	if err := runIndexSync(root, false); err != nil {
+		t.Fatalf("incremental sync failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "b.ts"), []byte("// moved down\n\nexport function bValue() {\n  return 2;\n}\n"), 0o644); err != nil {
+		t.Fatalf("modify b.ts: %v", err)
+	}
+
+	chunks, err := index.LoadChunkEntries(store.ChunksPath(root))
+	if err != nil {
+		t.Fatalf("load chunks: %v", err)
+	}
+	ids := make([]uint32, 0, len(chunks))
+	for _, ch := range chunks {
+		ids = append(ids, ch.ChunkID)
+	}
+	results, err := fetch.Fetch(root, ids, fetch.Options{})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	for _, res := range results {
+		switch res.Path {
+		case "a.ts":
+			if res.Stale || res.Source != "" {
+				t.Fatalf("a.ts is unchanged since sync: %+v", res)
+			}
+		case "b.ts":
+			if !res.Stale || res.Source != fetch.SourceSnapshot || res.Lines[0] != "1| export function bValue() {" || res.Lines[1] != "2|   return 1;" {
+				t.Fatalf("expected indexed b.ts lines from the snapshot: %+v", res)
+			}
+		}
+	}
+
+	cfg.Fetch.Snapshots = false
+	if err := config.Save(store.ConfigPath(root), cfg); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+	if err := runIndexSync(root, false); err != nil {
+		t.Fatalf("sync without snapshots failed: %v", err)
+	}
+	if _, err := os.Stat(store.SnapshotsPath(root)); !os.IsNotExist(err) {
+		t.Fatalf("expected snapshots.dat to be removed, got %v", err)
+	}
+}
+
+func TestIndexSyncPublishesGenerations(t *testing.T) {
+	root := setupGitRepoWithIndex(t, true, false)
+
+	first, err := store.LoadMeta(store.MetaPath(root))
+	if err != nil {
+		t.Fatalf("load meta: %v", err)
+	}
+	if first.Generation == "" {
+		t.Fatalf("expected sync to publish a generation")
+	}
+	if _, err := os.Stat(filepath.Join(store.Dir(root), store.ChunksFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected no flat-layout artifacts, got %v", err)
+	}
+
+	// A reader holding the first generation keeps it alive across a sync.
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sample.ts"), []byte("const y = 2;\n"), 0o644); err != nil {
+		t.Fatalf("modify sample.ts: %v", err)
+	}
+	if err := runIndexSync(root, false); err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	second, err := store.LoadMeta(store.MetaPath(root))
+	if err != nil {
+		t.Fatalf("load meta: %v", err)
+	}
+	if second.Generation == "" || second.Generation == first.Generation {
+		t.Fatalf("expected a new generation, got %q after %q", second.Generation, first.Generation)
+	}
+	if _, err := index.LoadChunkEntries(gen.ChunksPath()); err != nil {
+		t.Fatalf("expected leased generation to stay readable: %v", err)
+	}
+
+	lease.Release()
+	if err := runIndexSync(root, false); err != nil {
+		t.Fatalf("third sync failed: %v", err)
+	}
+	if _, err := os.Stat(gen.Dir); !os.IsNotExist(err) {
+		t.Fatalf("expected released generation to be collected, got %v", err)
+	}
+}
+
+func TestFetchAtRevisionAndGitDiff(t *testing.T) {
+	requireGit(t)
+
+	root := t.TempDir()
+	runGit(t, root, "init")
+	runGit(t, root, "config", "user.email", "test@example.com")
+	runGit(t, root, "config", "user.name", "Test User")
+	var lines []string
+	for i := 1; i <= 8; i++ {
+		lines = append(lines, fmt.Sprintf("export const value%d = %d;", i, i))
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.ts"), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("write a.ts: %v", err)
+	}
+	runGit(t, root, "add", ".")
+	runGit(t, root, "commit", "-m", "initial files")
+	if err := runInit(root, false); err != nil {
+		t.Fatalf("runInit failed: %v", err)
+	}
+	if err := runIndexSync(root, false); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	lines[1] = "export const value2 = 20;"
+	lines = append(lines[:6], lines[7:]...)
+	if err := os.WriteFile(filepath.Join(root, "a.ts"), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("modify a.ts: %v", err)
+	}
+
+	rng, err := fetch.FetchRange(root, "a.ts", 2, 2, fetch.Options{Rev: "HEAD"})
+	if err != nil {
+		t.Fatalf("fetch at HEAD: %v", err)
+	}
+	if rng.Rev != "HEAD" || rng.Stale || len(rng.Lines) != 1 || rng.Lines[0] != "2| export const value2 = 2;" {
+		t.Fatalf("expected committed line, got %+v", rng)
+	}
+	if _, err := fetch.FetchRange(root, "a.ts", 1, 1, fetch.Options{Rev: "--output=x"}); err == nil || !strings.Contains(err.Error(), "invalid rev") {
+		t.Fatalf("expected option-like rev to be rejected, got %v", err)
+	}
+
+	diff, err := fetch.DiffRange(root, "a.ts", 1, 3, "")
+	if err != nil {
+		t.Fatalf("git diff: %v", err)
+	}
+	if diff.Rev == "" || len(diff.Hunks) != 1 || diff.Hunks[0].OldStart != 2 || fmt.Sprint(diff.Hunks[0].Lines) != "[-export const value2 = 2; +export const value2 = 20;]" {
+		t.Fatalf("expected the value2 hunk only, got %+v", diff)
+	}
+	diff, err = fetch.DiffRange(root, "a.ts", 7, 8, "HEAD")
+	if err != nil {
+		t.Fatalf("git diff: %v", err)
+	}
+	if len(diff.Hunks) != 1 || diff.Hunks[0].OldStart != 7 || diff.Hunks[0].NewLines != 0 {
+		t.Fatalf("expected the deletion hunk, got %+v", diff)
+	}
+	diff, err = fetch.DiffRange(root, "a.ts", 4, 5, "HEAD")
+	if err != nil {
+		t.Fatalf("git diff: %v", err)
+	}
+	if diff.Hunks == nil || len(diff.Hunks) != 0 {
+		t.Fatalf("expected no hunks for untouched lines, got %+v", diff.Hunks)
+	}
+
+	chunkDiffs, err := fetch.DiffChunks(root, []uint32{1}, "")
+	if err != nil {
+		t.Fatalf("git diff chunks: %v", err)
+	}
+	if len(chunkDiffs) != 1 || chunkDiffs[0].ChunkID != 1 || len(chunkDiffs[0].Hunks) != 2 {
+		t.Fatalf("expected both hunks for the whole-file chunk, got %+v", chunkDiffs)
+	}
+}
+
+func TestIndexSyncRefusesWhileLocked(t *testing.T) {
+	root := setupGitRepoWithIndex(t, true, false)
+
+	lock, err := lockx.TryAcquire(store.SyncLockPath(root))
+	if err != nil {
+		t.Fatalf("acquire sync lock: %v", err)
+	}
+	defer lock.Release()
+
+	err = runIndexSync(root, false)
+	var held *lockx.HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("expected sync to fail with a held lock, got %v", err)
+	}
+	if held.Owner.PID != os.Getpid() {
+		t.Fatalf("unexpected lock owner %+v", held.Owner)
+	}
+	resp, err := computeStatus(root)
+	if err != nil {
+		t.Fatalf("computeStatus: %v", err)
+	}
+	if resp.SyncInProgress == nil || resp.SyncInProgress.PID != os.Getpid() {
+		t.Fatalf("expected status to report the running sync, got %+v", resp.SyncInProgress)
+	}
+
+	lock.Release()
+	if err := runIndexSync(root, false); err != nil {
+		t.Fatalf("sync after release: %v", err)
+	}
+	if _, err := os.Stat(store.SyncLockPath(root)); !os.IsNotExist(err) {
+		t.Fatalf("expected sync to release its lock, got %v", err)
+	}
+}
+
+func TestVerifyAfterSync(t *testing.T) {
+	root := setupGitRepoWithIndex(t, true, false)
+
+	gen, meta, lease, err := acquireIndex(root)
+	if err != nil {
+		t.Fatalf("acquireIndex: %v", err)
+	}
+	report := index.Verify(gen, meta)
+	lease.Release()
+	if !report.OK {
+		t.Fatalf("expected a synced index to verify, got %v", report.Problems)
+	}
+
+	data, err := os.ReadFile(store.ChunksPath(root))
+	if err != nil {
+		t.Fatalf("read chunks: %v", err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(store.ChunksPath(root), data, 0o644); err != nil {
+		t.Fatalf("write chunks: %v", err)
+	}
+	if err := runVerify(root, true); err == nil {
+		t.Fatalf("expected verify to fail on a damaged chunks.dat")
+	}
 }
 
 func setupGitRepoWithIndex(t *testing.T, ignoreRepodex bool, corruptFiles bool) string {
@@ -403,7 +746,7 @@ func setupGitRepoWithIndex(t *testing.T, ignoreRepodex bool, corruptFiles bool)
 	if err := runInit(root, false); err != nil {
 		t.Fatalf("runInit failed: %v", err)
 	}
-	if err := runIndexSync(root); err != nil {
+	if err := runIndexSync(root, false); err != nil {
 		t.Fatalf("runIndexSync failed: %v", err)
 	}
 	if !ignoreRepodex {
@@ -36,7 +36,7 @@ func TestGitChangeDetectionAndSyncPlan(t *testing.T) {
 	if err := runInit(root, false); err != nil {
 		t.Fatalf("runInit failed: %v", err)
 	}
-	if err := runIndexSync(root); err != nil {
+	if err := runIndexSync(root, false); err != nil {
 		t.Fatalf("runIndexSync failed: %v", err)
 	}
 
@@ -130,7 +130,7 @@ func TestGitChangeDetectionAndSyncPlan(t *testing.T) {
 	assertDirtyMatchesPlan(t, resp)
 
 	// 7) sync no-op when up-to-date.
-	if err := runIndexSync(root); err != nil {
+	if err := runIndexSync(root, false); err != nil {
 		t.Fatalf("runIndexSync (refresh) failed: %v", err)
 	}
 	resp = statusMust(t, root)
@@ -147,7 +147,7 @@ func TestGitChangeDetectionAndSyncPlan(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to stat meta: %v", err)
 	}
-	if err := runIndexSync(root); err != nil {
+	if err := runIndexSync(root, false); err != nil {
 		t.Fatalf("runIndexSync (noop) failed: %v", err)
 	}
 	afterBytes, err := os.ReadFile(metaPath)
@@ -216,7 +216,7 @@ func TestRepodexOnlyDirtySyncPlanNoop(t *testing.T) {
 	if err := runInit(root, false); err != nil {
 		t.Fatalf("runInit failed: %v", err)
 	}
-	if err := runIndexSync(root); err != nil {
+	if err := runIndexSync(root, false); err != nil {
 		t.Fatalf("runIndexSync failed: %v", err)
 	}
 
@@ -249,7 +249,7 @@ func TestRepodexOnlyDirtySyncPlanNoop(t *testing.T) {
 		t.Fatalf("expected GitDirtyPathCount>0 for repodex-only dirt")
 	}
 
-	if err := runIndexSync(root); err != nil {
+	if err := runIndexSync(root, false); err != nil {
 		t.Fatalf("runIndexSync (repodex dirty) failed: %v", err)
 	}
 
@@ -11,16 +11,24 @@ import (
 	"github.com/memkit/repodex/internal/store"
 )
 
-const CacheVersion = "v2"
+const CacheVersion = "v7"
 
 // CacheEntry represents a serialized per-file cache record.
+// Tokens, TermFreqs, Positions and TokenCounts are parallel to Chunks;
+// TermFreqs[i] and Positions[i] are parallel to Tokens[i].
 type CacheEntry struct {
-	RelPath string       `json:"rel_path"`
-	Size    int64        `json:"size"`
-	MTime   int64        `json:"mtime"`
-	Hash64  uint64       `json:"hash64"`
-	Chunks  []LocalChunk `json:"chunks"`
-	Tokens  [][]string   `json:"tokens"`
+	RelPath     string        `json:"rel_path"`
+	Size        int64         `json:"size"`
+	MTime       int64         `json:"mtime"`
+	Hash64      uint64        `json:"hash64"`
+	Chunks      []LocalChunk  `json:"chunks"`
+	Tokens      [][]string    `json:"tokens"`
+	TermFreqs   [][]uint32    `json:"term_freqs"`
+	Positions   [][][]uint32  `json:"positions"`
+	TokenCounts []uint32      `json:"token_counts"`
+	Symbols     []LocalSymbol `json:"symbols"`
+	Imports     []LocalImport `json:"imports"`
+	Snapshot    []byte        `json:"snapshot,omitempty"`
 }
 
 // LocalChunk mirrors a chunk without a global ChunkID.
@@ -30,6 +38,24 @@ type LocalChunk struct {
 	Snippet string `json:"snippet"`
 }
 
+// LocalSymbol mirrors a declaration reported by the language plugin.
+type LocalSymbol struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Parent   string `json:"parent,omitempty"`
+	Exported bool   `json:"exported,omitempty"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// LocalImport mirrors an unresolved import specifier. Resolution depends on the
+// whole file set, so it is redone on every sync.
+type LocalImport struct {
+	Specifier string `json:"specifier"`
+	Kind      string `json:"kind"`
+	Line      int    `json:"line"`
+}
+
 // CacheDir returns the cache directory for the current cache version under the repo root.
 func CacheDir(root string) string {
 	return filepath.Join(store.Dir(root), "cache", CacheVersion)
@@ -70,12 +96,25 @@ func LoadByPath(root string, relPath string) (CacheEntry, bool, error) {
 	if filepath.ToSlash(entry.RelPath) != normalized {
 		return CacheEntry{}, false, nil
 	}
-	if len(entry.Chunks) != len(entry.Tokens) {
+	if !entry.consistent() {
 		return CacheEntry{}, false, nil
 	}
 	return entry, true, nil
 }
 
+func (e CacheEntry) consistent() bool {
+	if len(e.Chunks) != len(e.Tokens) || len(e.Chunks) != len(e.TermFreqs) ||
+		len(e.Chunks) != len(e.Positions) || len(e.Chunks) != len(e.TokenCounts) {
+		return false
+	}
+	for i := range e.Tokens {
+		if len(e.Tokens[i]) != len(e.TermFreqs[i]) || len(e.Tokens[i]) != len(e.Positions[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func cachePath(dir string, relPath string) string {
 	sum := sha1.Sum([]byte(filepath.ToSlash(relPath)))
 	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
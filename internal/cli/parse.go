@@ -15,8 +15,24 @@ type Command struct {
 	Stdio      bool
 	Q          string
 	TopK       int
+	Cursor     string
+	Collapse   bool
 	IDs        []uint32
 	MaxLines   int
+	Name       string
+	Kind       string
+	Path       string
+	Line       int
+	StartLine  int
+	EndLine    int
+	Before     int
+	After      int
+	Mode       string
+	Rev        string
+	MaxBytes   int
+	MaxTokens  int
+	// NoWait makes sync fail instead of waiting when another sync is running.
+	NoWait bool
 }
 
 // Parse converts argv into a Command description.
@@ -36,8 +52,8 @@ func Parse(args []string) (Command, error) {
 			}
 		}
 		return c, nil
-	case "status":
-		c := Command{Action: "status"}
+	case "status", "verify":
+		c := Command{Action: cmd}
 		for _, a := range args[1:] {
 			if a == "--json" {
 				c.JSON = true
@@ -47,10 +63,7 @@ func Parse(args []string) (Command, error) {
 		}
 		return c, nil
 	case "sync":
-		if len(args) > 1 {
-			return Command{}, fmt.Errorf("unknown flag %s", args[1])
-		}
-		return Command{Action: "sync"}, nil
+		return parseSync(Command{Action: "sync"}, args[1:])
 	case "search":
 		c := Command{Action: "search"}
 		i := 1
@@ -75,6 +88,15 @@ func Parse(args []string) (Command, error) {
 				}
 				c.TopK = val
 				i += 2
+			case "--cursor":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for --cursor")
+				}
+				c.Cursor = args[i+1]
+				i += 2
+			case "--collapse":
+				c.Collapse = true
+				i++
 			default:
 				return Command{}, fmt.Errorf("unknown flag %s", args[i])
 			}
@@ -118,14 +140,210 @@ func Parse(args []string) (Command, error) {
 				}
 				c.MaxLines = val
 				i += 2
+			case "--path":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for --path")
+				}
+				c.Path = args[i+1]
+				i += 2
+			case "--mode":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for --mode")
+				}
+				c.Mode = args[i+1]
+				i += 2
+			case "--rev":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for --rev")
+				}
+				c.Rev = args[i+1]
+				i += 2
+			case "--start_line", "--end_line", "--before", "--after", "--max_bytes", "--max_tokens":
+				flag := args[i]
+				name := strings.TrimPrefix(flag, "--")
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for %s", flag)
+				}
+				val, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					return Command{}, fmt.Errorf("invalid %s %s", name, args[i+1])
+				}
+				if val < 0 {
+					return Command{}, fmt.Errorf("%s must be non-negative", name)
+				}
+				switch flag {
+				case "--start_line":
+					c.StartLine = val
+				case "--end_line":
+					c.EndLine = val
+				case "--before":
+					c.Before = val
+				case "--after":
+					c.After = val
+				case "--max_bytes":
+					c.MaxBytes = val
+				case "--max_tokens":
+					c.MaxTokens = val
+				}
+				i += 2
 			default:
 				return Command{}, fmt.Errorf("unknown flag %s", args[i])
 			}
 		}
+		if c.Path != "" {
+			if len(c.IDs) > 0 {
+				return Command{}, fmt.Errorf("--ids and --path are mutually exclusive")
+			}
+			if c.StartLine == 0 {
+				return Command{}, fmt.Errorf("missing required --start_line")
+			}
+			if c.Mode != "" {
+				return Command{}, fmt.Errorf("--mode applies to --ids only")
+			}
+			return c, nil
+		}
 		if len(c.IDs) == 0 {
-			return Command{}, fmt.Errorf("missing required --ids")
+			return Command{}, fmt.Errorf("missing required --ids or --path")
 		}
 		return c, nil
+	case "git_diff":
+		c := Command{Action: "git_diff"}
+		i := 1
+		for i < len(args) {
+			switch args[i] {
+			case "--ids":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for --ids")
+				}
+				for _, r := range strings.Split(args[i+1], ",") {
+					r = strings.TrimSpace(r)
+					if r == "" {
+						continue
+					}
+					id, err := strconv.ParseUint(r, 10, 32)
+					if err != nil {
+						return Command{}, fmt.Errorf("invalid id %s", r)
+					}
+					c.IDs = append(c.IDs, uint32(id))
+				}
+				i += 2
+			case "--path", "--rev":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for %s", args[i])
+				}
+				if args[i] == "--path" {
+					c.Path = args[i+1]
+				} else {
+					c.Rev = args[i+1]
+				}
+				i += 2
+			case "--start_line", "--end_line":
+				flag := args[i]
+				name := strings.TrimPrefix(flag, "--")
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for %s", flag)
+				}
+				val, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					return Command{}, fmt.Errorf("invalid %s %s", name, args[i+1])
+				}
+				if val < 0 {
+					return Command{}, fmt.Errorf("%s must be non-negative", name)
+				}
+				if flag == "--start_line" {
+					c.StartLine = val
+				} else {
+					c.EndLine = val
+				}
+				i += 2
+			default:
+				return Command{}, fmt.Errorf("unknown flag %s", args[i])
+			}
+		}
+		if c.Path != "" {
+			if len(c.IDs) > 0 {
+				return Command{}, fmt.Errorf("--ids and --path are mutually exclusive")
+			}
+			if c.StartLine == 0 {
+				return Command{}, fmt.Errorf("missing required --start_line")
+			}
+			return c, nil
+		}
+		if len(c.IDs) == 0 {
+			return Command{}, fmt.Errorf("missing required --ids or --path")
+		}
+		return c, nil
+	case "symbols":
+		c := Command{Action: "symbols"}
+		i := 1
+		for i < len(args) {
+			switch args[i] {
+			case "--name":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for --name")
+				}
+				c.Name = args[i+1]
+				i += 2
+			case "--kind":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for --kind")
+				}
+				c.Kind = args[i+1]
+				i += 2
+			default:
+				return Command{}, fmt.Errorf("unknown flag %s", args[i])
+			}
+		}
+		if c.Name == "" && c.Kind == "" {
+			return Command{}, fmt.Errorf("missing required --name or --kind")
+		}
+		return c, nil
+	case "definition", "references":
+		c := Command{Action: cmd}
+		i := 1
+		for i < len(args) {
+			switch args[i] {
+			case "--name":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for --name")
+				}
+				c.Name = args[i+1]
+				i += 2
+			case "--path":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for --path")
+				}
+				c.Path = args[i+1]
+				i += 2
+			case "--line":
+				if i+1 >= len(args) {
+					return Command{}, fmt.Errorf("missing value for --line")
+				}
+				val, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					return Command{}, fmt.Errorf("invalid line %s", args[i+1])
+				}
+				if val < 0 {
+					return Command{}, fmt.Errorf("line must be non-negative")
+				}
+				c.Line = val
+				i += 2
+			default:
+				return Command{}, fmt.Errorf("unknown flag %s", args[i])
+			}
+		}
+		if c.Name == "" {
+			return Command{}, fmt.Errorf("missing required --name")
+		}
+		return c, nil
+	case "imports", "importers", "outline":
+		if len(args) < 2 {
+			return Command{}, fmt.Errorf("missing file path")
+		}
+		if len(args) > 2 {
+			return Command{}, fmt.Errorf("unknown argument %s", args[2])
+		}
+		return Command{Action: cmd, Path: args[1]}, nil
 	case "index":
 		if len(args) < 2 {
 			return Command{}, fmt.Errorf("missing index subcommand")
@@ -133,7 +351,7 @@ func Parse(args []string) (Command, error) {
 		sub := args[1]
 		switch sub {
 		case "sync":
-			return Command{Action: "index", Subcommand: "sync"}, nil
+			return parseSync(Command{Action: "index", Subcommand: "sync"}, args[2:])
 		case "status":
 			parsed := Command{Action: "index", Subcommand: "status"}
 			if len(args) > 2 {
@@ -163,3 +381,19 @@ func Parse(args []string) (Command, error) {
 		return Command{}, fmt.Errorf("unknown command %s", cmd)
 	}
 }
+
+// parseSync reads the flags shared by sync and index sync; the last of
+// --wait and --no-wait wins.
+func parseSync(c Command, args []string) (Command, error) {
+	for _, a := range args {
+		switch a {
+		case "--wait":
+			c.NoWait = false
+		case "--no-wait":
+			c.NoWait = true
+		default:
+			return Command{}, fmt.Errorf("unknown flag %s", a)
+		}
+	}
+	return c, nil
+}
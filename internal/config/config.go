@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 )
 
@@ -15,6 +16,8 @@ type Config struct {
 	Chunk        ChunkingConfig     `json:"Chunk"`
 	Token        TokenizationConfig `json:"Token"`
 	Limits       LimitsConfig       `json:"Limits"`
+	Search       SearchConfig       `json:"Search"`
+	Fetch        FetchConfig        `json:"Fetch"`
 }
 
 // ChunkingConfig configures how files are chunked.
@@ -40,11 +43,79 @@ type TokenizationConfig struct {
 // ScanConfig controls scanning behavior.
 type ScanConfig struct {
 	MaxTextFileSizeBytes int64 `json:"MaxTextFileSizeBytes"`
+	// UseGitignore also skips paths ignored by git: every .gitignore in the
+	// tree (applied to its own directory) and .git/info/exclude.
+	UseGitignore bool `json:"UseGitignore"`
 }
 
-// LimitsConfig controls output limits.
+// LimitsConfig controls output limits. Zero values fall back to the defaults;
+// values above the hard ceilings are rejected by Load.
 type LimitsConfig struct {
 	MaxSnippetBytes int `json:"MaxSnippetBytes"`
+	// MaxTopK is the default and maximum number of search results.
+	MaxTopK int `json:"MaxTopK"`
+	// MaxPerFile is the default number of search results per file.
+	MaxPerFile int `json:"MaxPerFile"`
+	// FetchMaxIDs is the number of chunk ids a fetch processes; extra ids are ignored.
+	FetchMaxIDs int `json:"FetchMaxIDs"`
+	// FetchMaxLines is the default and maximum number of lines returned per chunk.
+	FetchMaxLines int `json:"FetchMaxLines"`
+	// MaxReferences caps the occurrences returned by a references lookup.
+	MaxReferences int `json:"MaxReferences"`
+}
+
+// Default limits.
+const (
+	DefaultMaxTopK       = 20
+	DefaultMaxPerFile    = 2
+	DefaultFetchMaxIDs   = 5
+	DefaultFetchMaxLines = 120
+	DefaultMaxReferences = 200
+)
+
+// Hard ceilings for configurable limits; the server never exceeds these.
+const (
+	CeilingMaxTopK       = 200
+	CeilingFetchMaxIDs   = 50
+	CeilingFetchMaxLines = 2000
+	CeilingMaxReferences = 2000
+)
+
+// FetchConfig controls what sync stores for fetches.
+type FetchConfig struct {
+	// Snapshots stores a compressed copy of every indexed file in snapshots.dat
+	// so fetches of stale chunks return the lines that were indexed.
+	Snapshots bool `json:"Snapshots"`
+}
+
+// SearchConfig controls ranking. A zero or missing BM25K1 and a missing BM25B
+// fall back to the defaults; BM25B may be set to 0, which turns off length
+// normalization.
+type SearchConfig struct {
+	BM25K1 float64  `json:"BM25K1"`
+	BM25B  *float64 `json:"BM25B,omitempty"`
+}
+
+// Default BM25 parameters.
+const (
+	DefaultBM25K1 = 1.2
+	DefaultBM25B  = 0.75
+)
+
+// K1 returns BM25K1, or DefaultBM25K1 when it is unset.
+func (s SearchConfig) K1() float64 {
+	if s.BM25K1 == 0 {
+		return DefaultBM25K1
+	}
+	return s.BM25K1
+}
+
+// B returns BM25B, or DefaultBM25B when it is unset.
+func (s SearchConfig) B() float64 {
+	if s.BM25B == nil {
+		return DefaultBM25B
+	}
+	return *s.BM25B
 }
 
 // DefaultConfig returns a Config populated with defaults.
@@ -73,10 +144,23 @@ func DefaultConfig() Config {
 		},
 		Limits: LimitsConfig{
 			MaxSnippetBytes: 800,
+			MaxTopK:         DefaultMaxTopK,
+			MaxPerFile:      DefaultMaxPerFile,
+			FetchMaxIDs:     DefaultFetchMaxIDs,
+			FetchMaxLines:   DefaultFetchMaxLines,
+			MaxReferences:   DefaultMaxReferences,
+		},
+		Search: SearchConfig{
+			BM25K1: DefaultBM25K1,
+			BM25B:  float64Ptr(DefaultBM25B),
 		},
 	}
 }
 
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
 func defaultStopWords() []string {
 	return []string{
 		"const", "let", "var", "function", "return", "export", "import", "from",
@@ -108,12 +192,64 @@ func Load(path string) (Config, []byte, error) {
 		return cfg, nil, err
 	}
 	applyDefaults(&cfg)
+	if err := validate(cfg); err != nil {
+		return cfg, nil, err
+	}
 	return cfg, data, nil
 }
 
+// validate rejects limits outside their hard ceilings and BM25 parameters
+// outside their range.
+func validate(cfg Config) error {
+	checks := []struct {
+		name    string
+		value   int
+		ceiling int
+	}{
+		{"Limits.MaxTopK", cfg.Limits.MaxTopK, CeilingMaxTopK},
+		{"Limits.MaxPerFile", cfg.Limits.MaxPerFile, cfg.Limits.MaxTopK},
+		{"Limits.FetchMaxIDs", cfg.Limits.FetchMaxIDs, CeilingFetchMaxIDs},
+		{"Limits.FetchMaxLines", cfg.Limits.FetchMaxLines, CeilingFetchMaxLines},
+		{"Limits.MaxReferences", cfg.Limits.MaxReferences, CeilingMaxReferences},
+	}
+	for _, c := range checks {
+		if c.value < 1 || c.value > c.ceiling {
+			return fmt.Errorf("invalid config: %s must be between 1 and %d, got %d", c.name, c.ceiling, c.value)
+		}
+	}
+	if cfg.Search.BM25K1 < 0 {
+		return fmt.Errorf("invalid config: Search.BM25K1 must not be negative, got %g", cfg.Search.BM25K1)
+	}
+	if b := cfg.Search.BM25B; b != nil && (*b < 0 || *b > 1) {
+		return fmt.Errorf("invalid config: Search.BM25B must be between 0 and 1, got %g", *b)
+	}
+	return nil
+}
+
 // applyDefaults fills in any missing fields introduced in newer versions.
 func applyDefaults(cfg *Config) {
 	if cfg.Scan.MaxTextFileSizeBytes == 0 {
 		cfg.Scan.MaxTextFileSizeBytes = 1024 * 1024
 	}
+	if cfg.Limits.MaxTopK == 0 {
+		cfg.Limits.MaxTopK = DefaultMaxTopK
+	}
+	if cfg.Limits.MaxPerFile == 0 {
+		cfg.Limits.MaxPerFile = DefaultMaxPerFile
+	}
+	if cfg.Limits.FetchMaxIDs == 0 {
+		cfg.Limits.FetchMaxIDs = DefaultFetchMaxIDs
+	}
+	if cfg.Limits.FetchMaxLines == 0 {
+		cfg.Limits.FetchMaxLines = DefaultFetchMaxLines
+	}
+	if cfg.Limits.MaxReferences == 0 {
+		cfg.Limits.MaxReferences = DefaultMaxReferences
+	}
+	if cfg.Search.BM25K1 == 0 {
+		cfg.Search.BM25K1 = DefaultBM25K1
+	}
+	if cfg.Search.BM25B == nil {
+		cfg.Search.BM25B = float64Ptr(DefaultBM25B)
+	}
 }
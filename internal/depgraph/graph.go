@@ -0,0 +1,174 @@
+package depgraph
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/lang"
+	"github.com/memkit/repodex/internal/store"
+)
+
+// Dependency is an import of a file, resolved when it names an indexed file.
+type Dependency struct {
+	Specifier string `json:"specifier"`
+	Kind      string `json:"kind"`
+	Line      uint32 `json:"line"`
+	Resolved  string `json:"resolved,omitempty"`
+	// External marks bare specifiers (packages, node builtins) that are not indexed files.
+	External bool `json:"external,omitempty"`
+}
+
+// FileImports lists what a file depends on, in source order.
+type FileImports struct {
+	Path    string       `json:"path"`
+	Imports []Dependency `json:"imports"`
+}
+
+// Importer is a file that imports the queried file.
+type Importer struct {
+	Path      string `json:"path"`
+	Line      uint32 `json:"line"`
+	Specifier string `json:"specifier"`
+	Kind      string `json:"kind"`
+}
+
+// FileImporters lists who depends on a file, in path and line order.
+type FileImporters struct {
+	Path      string     `json:"path"`
+	Importers []Importer `json:"importers"`
+}
+
+// Build resolves every file's imports against the indexed file set and returns
+// edges ordered by path then line.
+func Build(root string, files []index.FileEntry, imports map[string][]lang.Import) ([]index.ImportEntry, error) {
+	paths := make([]string, 0, len(files))
+	for _, fe := range files {
+		paths = append(paths, fe.Path)
+	}
+	resolver, err := NewResolver(root, paths)
+	if err != nil {
+		return nil, err
+	}
+	var edges []index.ImportEntry
+	for _, fe := range files {
+		for _, imp := range imports[filepath.ToSlash(fe.Path)] {
+			edges = append(edges, index.ImportEntry{
+				FileID:    fe.FileID,
+				Path:      fe.Path,
+				Line:      imp.Line,
+				Kind:      imp.Kind,
+				Specifier: imp.Specifier,
+				Resolved:  resolver.Resolve(fe.Path, imp.Specifier),
+			})
+		}
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].Path != edges[j].Path {
+			return edges[i].Path < edges[j].Path
+		}
+		return edges[i].Line < edges[j].Line
+	})
+	return edges, nil
+}
+
+// FromPrecomputed builds the graph from the imports carried by precomputed files.
+func FromPrecomputed(root string, files []index.FileEntry, precomputed []index.PrecomputedFile) ([]index.ImportEntry, error) {
+	imports := make(map[string][]lang.Import, len(precomputed))
+	for _, f := range precomputed {
+		if len(f.Imports) > 0 {
+			imports[filepath.ToSlash(f.Path)] = f.Imports
+		}
+	}
+	return Build(root, files, imports)
+}
+
+// LookupImports loads the index and returns the imports of path.
+func LookupImports(root string, p string) (FileImports, error) {
+	files, edges, err := load(root)
+	if err != nil {
+		return FileImports{}, err
+	}
+	return Imports(files, edges, p)
+}
+
+// LookupImporters loads the index and returns the files importing path.
+func LookupImporters(root string, p string) (FileImporters, error) {
+	files, edges, err := load(root)
+	if err != nil {
+		return FileImporters{}, err
+	}
+	return Importers(files, edges, p)
+}
+
+func load(root string) ([]index.FileEntry, []index.ImportEntry, error) {
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer lease.Release()
+
+	files, err := index.LoadFileEntries(gen.FilesPath())
+	if err != nil {
+		return nil, nil, err
+	}
+	edges, err := index.LoadImports(gen.ImportsPath())
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, edges, nil
+}
+
+// Imports returns the dependencies of an indexed file.
+func Imports(files []index.FileEntry, edges []index.ImportEntry, p string) (FileImports, error) {
+	p, err := indexedPath(files, p)
+	if err != nil {
+		return FileImports{}, err
+	}
+	out := FileImports{Path: p, Imports: []Dependency{}}
+	for _, e := range edges {
+		if e.Path != p {
+			continue
+		}
+		out.Imports = append(out.Imports, Dependency{
+			Specifier: e.Specifier,
+			Kind:      e.Kind,
+			Line:      e.Line,
+			Resolved:  e.Resolved,
+			External:  e.Resolved == "" && !isRelative(e.Specifier) && !strings.HasPrefix(e.Specifier, "/"),
+		})
+	}
+	return out, nil
+}
+
+// Importers returns the indexed files that import p.
+func Importers(files []index.FileEntry, edges []index.ImportEntry, p string) (FileImporters, error) {
+	p, err := indexedPath(files, p)
+	if err != nil {
+		return FileImporters{}, err
+	}
+	out := FileImporters{Path: p, Importers: []Importer{}}
+	for _, e := range edges {
+		if e.Resolved != p {
+			continue
+		}
+		out.Importers = append(out.Importers, Importer{
+			Path:      e.Path,
+			Line:      e.Line,
+			Specifier: e.Specifier,
+			Kind:      e.Kind,
+		})
+	}
+	return out, nil
+}
+
+func indexedPath(files []index.FileEntry, p string) (string, error) {
+	fe, ok := index.FileByPath(files, p)
+	if !ok {
+		return "", fmt.Errorf("path not indexed: %s", path.Clean(filepath.ToSlash(strings.TrimSpace(p))))
+	}
+	return fe.Path, nil
+}
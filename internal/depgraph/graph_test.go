@@ -0,0 +1,160 @@
+package depgraph
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/lang"
+)
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", rel, err)
+	}
+}
+
+func TestResolverSpecifiers(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "tsconfig.base.json", `{
+  // shared options
+  "compilerOptions": { "baseUrl": "src" },
+}`)
+	writeFile(t, root, "tsconfig.json", `{
+  "extends": "./tsconfig.base",
+  "compilerOptions": {
+    /* aliases */
+    "paths": {
+      "@app/*": ["app/*"],
+      "@config": ["config/index.ts"],
+    },
+  },
+}`)
+	writeFile(t, root, "package.json", `{"name": "root", "workspaces": ["packages/*"]}`)
+	writeFile(t, root, "packages/ui/package.json", `{
+  "name": "@acme/ui",
+  "exports": {
+    ".": { "types": "./dist/index.d.ts", "source": "./src/index.ts" },
+    "./widgets/*": "./src/widgets/*.tsx"
+  }
+}`)
+	writeFile(t, root, "packages/util/package.json", `{"name": "@acme/util", "main": "lib/main.js"}`)
+
+	files := []string{
+		"src/app/users.ts",
+		"src/app/orders/index.ts",
+		"src/config/index.ts",
+		"src/shared/log.ts",
+		"packages/ui/src/index.ts",
+		"packages/ui/src/widgets/Button.tsx",
+		"packages/util/lib/main.ts",
+	}
+	r, err := NewResolver(root, files)
+	if err != nil {
+		t.Fatalf("resolver: %v", err)
+	}
+	cases := []struct {
+		from, spec, want string
+	}{
+		{"src/app/users.ts", "./orders", "src/app/orders/index.ts"},
+		{"src/app/orders/index.ts", "../users.js", "src/app/users.ts"},
+		{"src/app/users.ts", "@app/users", "src/app/users.ts"},
+		{"src/app/users.ts", "@config", "src/config/index.ts"},
+		{"src/app/users.ts", "shared/log", "src/shared/log.ts"},
+		{"src/app/users.ts", "@acme/ui", "packages/ui/src/index.ts"},
+		{"src/app/users.ts", "@acme/ui/widgets/Button", "packages/ui/src/widgets/Button.tsx"},
+		{"src/app/users.ts", "@acme/util", "packages/util/lib/main.ts"},
+		{"src/app/users.ts", "react", ""},
+		{"src/app/users.ts", "node:fs", ""},
+		{"src/app/users.ts", "../../../outside", ""},
+	}
+	for _, c := range cases {
+		if got := r.Resolve(c.from, c.spec); got != c.want {
+			t.Fatalf("Resolve(%q, %q) = %q, want %q", c.from, c.spec, got, c.want)
+		}
+	}
+}
+
+func TestResolverRejectsBrokenTSConfig(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "tsconfig.json", `{ "compilerOptions": `)
+	if _, err := NewResolver(root, nil); err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
+
+func TestResolverExtendsInstalledConfigs(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "tsconfig.json", `{ "extends": ["@acme/tsconfig", "@acme/tsconfig/strict.json"] }`)
+	writeFile(t, root, "node_modules/@acme/tsconfig/tsconfig.json", `{ "compilerOptions": { "baseUrl": "../../../src" } }`)
+	writeFile(t, root, "node_modules/@acme/tsconfig/strict.json", `{ "compilerOptions": { "paths": { "@app/*": ["app/*"] } } }`)
+	r, err := NewResolver(root, []string{"src/app/users.ts"})
+	if err != nil {
+		t.Fatalf("new resolver: %v", err)
+	}
+	if got := r.Resolve("src/main.ts", "@app/users"); got != "src/app/users.ts" {
+		t.Fatalf("expected paths from the installed config, got %q", got)
+	}
+	before := ConfigHash(root)
+	writeFile(t, root, "node_modules/@acme/tsconfig/strict.json", `{ "compilerOptions": { "paths": {} } }`)
+	if ConfigHash(root) == before {
+		t.Fatalf("expected an edit to an extended config to change the hash")
+	}
+
+	writeFile(t, root, "tsconfig.json", `{ "extends": "@tsconfig/node18/tsconfig.json" }`)
+	if _, err := NewResolver(root, nil); err == nil || !strings.Contains(err.Error(), "@tsconfig/node18") {
+		t.Fatalf("expected an error for an extends that is not installed, got %v", err)
+	}
+}
+
+func TestImportsAndImporters(t *testing.T) {
+	root := t.TempDir()
+	files := []index.FileEntry{
+		{FileID: 1, Path: "src/a.ts"},
+		{FileID: 2, Path: "src/b.ts"},
+		{FileID: 3, Path: "src/c.ts"},
+	}
+	edges, err := Build(root, files, map[string][]lang.Import{
+		"src/a.ts": {
+			{Specifier: "react", Kind: lang.ImportStatic, Line: 1},
+			{Specifier: "./b", Kind: lang.ImportStatic, Line: 2},
+			{Specifier: "./missing", Kind: lang.ImportDynamic, Line: 9},
+		},
+		"src/c.ts": {{Specifier: "./b", Kind: lang.ImportExport, Line: 3}},
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	imports, err := Imports(files, edges, "./src/a.ts")
+	if err != nil {
+		t.Fatalf("imports: %v", err)
+	}
+	want := []Dependency{
+		{Specifier: "react", Kind: lang.ImportStatic, Line: 1, External: true},
+		{Specifier: "./b", Kind: lang.ImportStatic, Line: 2, Resolved: "src/b.ts"},
+		{Specifier: "./missing", Kind: lang.ImportDynamic, Line: 9},
+	}
+	if imports.Path != "src/a.ts" || !reflect.DeepEqual(imports.Imports, want) {
+		t.Fatalf("unexpected imports %+v", imports)
+	}
+
+	importers, err := Importers(files, edges, "src/b.ts")
+	if err != nil {
+		t.Fatalf("importers: %v", err)
+	}
+	if len(importers.Importers) != 2 || importers.Importers[0].Path != "src/a.ts" || importers.Importers[1].Kind != lang.ImportExport {
+		t.Fatalf("unexpected importers %+v", importers)
+	}
+	if _, err := Importers(files, edges, "src/zzz.ts"); err == nil {
+		t.Fatalf("expected error for unindexed path")
+	}
+}
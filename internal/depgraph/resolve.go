@@ -0,0 +1,521 @@
+package depgraph
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/memkit/repodex/internal/hash"
+)
+
+// resolveExtensions are tried, in order, after a specifier without a known extension.
+var resolveExtensions = []string{".ts", ".tsx", ".d.ts", ".js", ".jsx", ".mjs", ".cjs"}
+
+// sourceForOutput maps emitted extensions written in ESM-style TS imports to their sources.
+var sourceForOutput = map[string][]string{
+	".js":  {".ts", ".tsx"},
+	".jsx": {".tsx"},
+	".mjs": {".mts"},
+	".cjs": {".cts"},
+}
+
+// exportConditions are tried, in order, when an exports target is a conditions object.
+var exportConditions = []string{"source", "types", "import", "module", "default", "require", "node"}
+
+// maxExtendsDepth bounds tsconfig "extends" chains.
+const maxExtendsDepth = 8
+
+// Resolver maps import specifiers to indexed files using relative paths,
+// tsconfig.json baseUrl/paths, and workspace package.json exports.
+type Resolver struct {
+	files    map[string]struct{}
+	baseURL  string
+	hasBase  bool
+	paths    []pathMapping
+	packages []workspacePackage
+	// inputs holds every config file read, as path and content, in read order.
+	inputs []byte
+}
+
+type pathMapping struct {
+	prefix   string
+	suffix   string
+	wildcard bool
+	targets  []string
+}
+
+type workspacePackage struct {
+	name    string
+	dir     string
+	exports interface{}
+	entries []string
+}
+
+// NewResolver loads tsconfig.json and package.json from root. Missing files are
+// fine; files that exist but cannot be parsed are reported as errors.
+func NewResolver(root string, files []string) (*Resolver, error) {
+	r := &Resolver{files: make(map[string]struct{}, len(files))}
+	for _, f := range files {
+		r.files[filepath.ToSlash(f)] = struct{}{}
+	}
+	if err := r.load(root); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Resolver) load(root string) error {
+	if err := r.loadTSConfig(root, "tsconfig.json", 0); err != nil {
+		return err
+	}
+	return r.loadPackages(root)
+}
+
+// ConfigHash hashes the tsconfig and package.json files NewResolver reads
+// from root, including extended configs and workspace packages, so a sync
+// can tell that resolved imports went stale. A file that fails to parse
+// still counts with its content; the sync then reports the error.
+func ConfigHash(root string) uint64 {
+	r := &Resolver{}
+	_ = r.load(root)
+	return hash.Sum64(r.inputs)
+}
+
+// readInput reads the repo-relative config file rel and records it as an input.
+func (r *Resolver) readInput(root, rel string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+	r.inputs = append(r.inputs, rel...)
+	r.inputs = append(r.inputs, 0)
+	r.inputs = binary.AppendUvarint(r.inputs, uint64(len(data)))
+	r.inputs = append(r.inputs, data...)
+	return data, nil
+}
+
+// Resolve returns the indexed file that specifier, imported from the file at
+// from, refers to, or "" when it is external or cannot be resolved.
+func (r *Resolver) Resolve(from, specifier string) string {
+	if specifier == "" {
+		return ""
+	}
+	if isRelative(specifier) {
+		return r.resolveFile(path.Join(path.Dir(from), specifier))
+	}
+	if strings.HasPrefix(specifier, "/") || strings.Contains(specifier, ":") {
+		return ""
+	}
+	if resolved := r.resolvePaths(specifier); resolved != "" {
+		return resolved
+	}
+	if r.hasBase {
+		if resolved := r.resolveFile(path.Join(r.baseURL, specifier)); resolved != "" {
+			return resolved
+		}
+	}
+	return r.resolvePackage(specifier)
+}
+
+func isRelative(specifier string) bool {
+	return specifier == "." || specifier == ".." || strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../")
+}
+
+// resolveFile tries p as written, with emitted extensions mapped back to
+// sources, with each known extension appended, and as a directory index.
+func (r *Resolver) resolveFile(p string) string {
+	p = path.Clean(p)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return ""
+	}
+	if _, ok := r.files[p]; ok {
+		return p
+	}
+	ext := path.Ext(p)
+	for _, src := range sourceForOutput[ext] {
+		if candidate := strings.TrimSuffix(p, ext) + src; r.has(candidate) {
+			return candidate
+		}
+	}
+	for _, ext := range resolveExtensions {
+		if r.has(p + ext) {
+			return p + ext
+		}
+	}
+	for _, ext := range resolveExtensions {
+		if candidate := path.Join(p, "index"+ext); r.has(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func (r *Resolver) has(p string) bool {
+	_, ok := r.files[p]
+	return ok
+}
+
+// resolvePaths applies tsconfig paths, preferring the longest matching prefix.
+func (r *Resolver) resolvePaths(specifier string) string {
+	for _, m := range r.paths {
+		var star string
+		if m.wildcard {
+			if len(specifier) < len(m.prefix)+len(m.suffix) || !strings.HasPrefix(specifier, m.prefix) || !strings.HasSuffix(specifier, m.suffix) {
+				continue
+			}
+			star = specifier[len(m.prefix) : len(specifier)-len(m.suffix)]
+		} else if specifier != m.prefix {
+			continue
+		}
+		for _, target := range m.targets {
+			if resolved := r.resolveFile(strings.Replace(target, "*", star, 1)); resolved != "" {
+				return resolved
+			}
+		}
+	}
+	return ""
+}
+
+func (r *Resolver) resolvePackage(specifier string) string {
+	for _, pkg := range r.packages {
+		var subpath string
+		switch {
+		case specifier == pkg.name:
+			subpath = "."
+		case strings.HasPrefix(specifier, pkg.name+"/"):
+			subpath = "./" + strings.TrimPrefix(specifier, pkg.name+"/")
+		default:
+			continue
+		}
+		if pkg.exports != nil {
+			for _, target := range exportTargets(pkg.exports, subpath) {
+				if resolved := r.resolveFile(path.Join(pkg.dir, target)); resolved != "" {
+					return resolved
+				}
+			}
+			return ""
+		}
+		if subpath != "." {
+			return r.resolveFile(path.Join(pkg.dir, subpath))
+		}
+		for _, entry := range pkg.entries {
+			if resolved := r.resolveFile(path.Join(pkg.dir, entry)); resolved != "" {
+				return resolved
+			}
+		}
+		return r.resolveFile(pkg.dir)
+	}
+	return ""
+}
+
+// exportTargets lists the candidate targets of a package.json exports field
+// for subpath ("." or "./x"), in condition order.
+func exportTargets(exports interface{}, subpath string) []string {
+	obj, isObj := exports.(map[string]interface{})
+	subpathKeyed := false
+	if isObj {
+		for key := range obj {
+			subpathKeyed = strings.HasPrefix(key, ".")
+			break
+		}
+	}
+	if !subpathKeyed {
+		if subpath != "." {
+			return nil
+		}
+		return conditionTargets(exports, "")
+	}
+	if target, ok := obj[subpath]; ok {
+		return conditionTargets(target, "")
+	}
+	// Wildcard keys such as "./utils/*"; the longest prefix wins.
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		if strings.Count(key, "*") == 1 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	for _, key := range keys {
+		star := strings.Index(key, "*")
+		prefix, suffix := key[:star], key[star+1:]
+		if len(subpath) >= len(prefix)+len(suffix) && strings.HasPrefix(subpath, prefix) && strings.HasSuffix(subpath, suffix) {
+			return conditionTargets(obj[key], subpath[len(prefix):len(subpath)-len(suffix)])
+		}
+	}
+	return nil
+}
+
+func conditionTargets(target interface{}, star string) []string {
+	switch t := target.(type) {
+	case string:
+		return []string{strings.ReplaceAll(t, "*", star)}
+	case []interface{}:
+		var out []string
+		for _, item := range t {
+			out = append(out, conditionTargets(item, star)...)
+		}
+		return out
+	case map[string]interface{}:
+		var out []string
+		for _, cond := range exportConditions {
+			if v, ok := t[cond]; ok {
+				out = append(out, conditionTargets(v, star)...)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+type tsconfigFile struct {
+	Extends         interface{} `json:"extends"`
+	CompilerOptions struct {
+		BaseURL *string             `json:"baseUrl"`
+		Paths   map[string][]string `json:"paths"`
+	} `json:"compilerOptions"`
+}
+
+// loadTSConfig reads rel (repo-relative) and the configs it extends; options
+// from rel override inherited ones. baseUrl and paths targets are stored
+// relative to the repo root.
+func (r *Resolver) loadTSConfig(root, rel string, depth int) error {
+	if depth > maxExtendsDepth {
+		return fmt.Errorf("tsconfig extends chain too deep at %s", rel)
+	}
+	data, err := r.readInput(root, rel)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cfg tsconfigFile
+	if err := json.Unmarshal(stripJSONC(data), &cfg); err != nil {
+		return fmt.Errorf("parse %s: %w", rel, err)
+	}
+	dir := path.Dir(rel)
+	var extends []string
+	switch ext := cfg.Extends.(type) {
+	case string:
+		extends = []string{ext}
+	case []interface{}:
+		// TypeScript 5 accepts a list; later entries override earlier ones.
+		for _, item := range ext {
+			if s, ok := item.(string); ok {
+				extends = append(extends, s)
+			}
+		}
+	}
+	for _, ext := range extends {
+		parent, err := extendedConfig(root, dir, ext)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		if err := r.loadTSConfig(root, parent, depth+1); err != nil {
+			return err
+		}
+	}
+	if cfg.CompilerOptions.BaseURL != nil {
+		r.baseURL = path.Join(dir, *cfg.CompilerOptions.BaseURL)
+		r.hasBase = true
+	}
+	if cfg.CompilerOptions.Paths != nil {
+		base := dir
+		if r.hasBase {
+			base = r.baseURL
+		}
+		r.paths = r.paths[:0]
+		for pattern, targets := range cfg.CompilerOptions.Paths {
+			m := pathMapping{prefix: pattern}
+			if star := strings.Index(pattern, "*"); star >= 0 {
+				m = pathMapping{prefix: pattern[:star], suffix: pattern[star+1:], wildcard: true}
+			}
+			for _, target := range targets {
+				m.targets = append(m.targets, path.Join(base, target))
+			}
+			r.paths = append(r.paths, m)
+		}
+		sort.Slice(r.paths, func(i, j int) bool {
+			if len(r.paths[i].prefix) != len(r.paths[j].prefix) {
+				return len(r.paths[i].prefix) > len(r.paths[j].prefix)
+			}
+			return r.paths[i].prefix+r.paths[i].suffix < r.paths[j].prefix+r.paths[j].suffix
+		})
+	}
+	return nil
+}
+
+// extendedConfig returns the repo-relative path of the config that a tsconfig
+// in dir names in "extends". Relative names are joined to dir; package names
+// such as "@tsconfig/node18/tsconfig.json" are looked up in the node_modules
+// of dir and its parents, as TypeScript does, and must be installed.
+func extendedConfig(root, dir, ext string) (string, error) {
+	if isRelative(ext) {
+		parent := path.Join(dir, ext)
+		if path.Ext(parent) != ".json" {
+			parent += ".json"
+		}
+		return parent, nil
+	}
+	for d := dir; ; d = path.Dir(d) {
+		base := path.Join(d, "node_modules", ext)
+		candidates := []string{base + ".json", path.Join(base, "tsconfig.json")}
+		if path.Ext(base) == ".json" {
+			candidates = []string{base}
+		}
+		for _, c := range candidates {
+			if info, err := os.Stat(filepath.Join(root, filepath.FromSlash(c))); err == nil && info.Mode().IsRegular() {
+				return c, nil
+			}
+		}
+		if d == "." {
+			break
+		}
+	}
+	return "", fmt.Errorf("extends %q, which is not installed under node_modules", ext)
+}
+
+type packageFile struct {
+	Name       string      `json:"name"`
+	Main       string      `json:"main"`
+	Module     string      `json:"module"`
+	Types      string      `json:"types"`
+	Source     string      `json:"source"`
+	Exports    interface{} `json:"exports"`
+	Workspaces interface{} `json:"workspaces"`
+}
+
+// loadPackages reads the root package.json and the packages matched by its
+// workspaces globs, so bare specifiers naming them resolve to their sources.
+func (r *Resolver) loadPackages(root string) error {
+	rootPkg, ok, err := r.readPackage(root, ".")
+	if err != nil || !ok {
+		return err
+	}
+	r.addPackage(".", rootPkg)
+
+	var patterns []string
+	switch ws := rootPkg.Workspaces.(type) {
+	case []interface{}:
+		for _, p := range ws {
+			if s, ok := p.(string); ok {
+				patterns = append(patterns, s)
+			}
+		}
+	case map[string]interface{}:
+		if list, ok := ws["packages"].([]interface{}); ok {
+			for _, p := range list {
+				if s, ok := p.(string); ok {
+					patterns = append(patterns, s)
+				}
+			}
+		}
+	}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern), "package.json"))
+		if err != nil {
+			return fmt.Errorf("workspaces pattern %q: %w", pattern, err)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			rel, err := filepath.Rel(root, filepath.Dir(m))
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			pkg, ok, err := r.readPackage(root, rel)
+			if err != nil {
+				return err
+			}
+			if ok {
+				r.addPackage(rel, pkg)
+			}
+		}
+	}
+	// Longer names first so "@scope/ui-kit" is not shadowed by "@scope/ui".
+	sort.SliceStable(r.packages, func(i, j int) bool { return len(r.packages[i].name) > len(r.packages[j].name) })
+	return nil
+}
+
+func (r *Resolver) addPackage(dir string, pkg packageFile) {
+	if pkg.Name == "" {
+		return
+	}
+	wp := workspacePackage{name: pkg.Name, dir: dir, exports: pkg.Exports}
+	for _, entry := range []string{pkg.Source, pkg.Types, pkg.Module, pkg.Main} {
+		if entry != "" {
+			wp.entries = append(wp.entries, entry)
+		}
+	}
+	r.packages = append(r.packages, wp)
+}
+
+func (r *Resolver) readPackage(root, dir string) (packageFile, bool, error) {
+	rel := path.Join(dir, "package.json")
+	data, err := r.readInput(root, rel)
+	if errors.Is(err, os.ErrNotExist) {
+		return packageFile{}, false, nil
+	}
+	if err != nil {
+		return packageFile{}, false, err
+	}
+	var pkg packageFile
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return packageFile{}, false, fmt.Errorf("parse %s: %w", rel, err)
+	}
+	return pkg, true, nil
+}
+
+// stripJSONC removes comments and trailing commas so tsconfig files parse as JSON.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+		if inString {
+			out = append(out, ch)
+			if ch == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if ch == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case ch == '"':
+			inString = true
+			out = append(out, ch)
+		case ch == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			out = append(out, '\n')
+		case ch == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		case ch == ']' || ch == '}':
+			j := len(out) - 1
+			for j >= 0 && (out[j] == ' ' || out[j] == '\t' || out[j] == '\n' || out[j] == '\r') {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, ch)
+		default:
+			out = append(out, ch)
+		}
+	}
+	return out
+}
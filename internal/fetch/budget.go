@@ -0,0 +1,207 @@
+package fetch
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// approxBytesPerToken converts Options.MaxTokens into bytes. It is the usual
+// rule of thumb for code with BPE tokenizers, not an exact count.
+const approxBytesPerToken = 4
+
+// Line classes, in the order a byte budget drops them.
+const (
+	classBlank = iota
+	classImport
+	classComment
+	classCode
+)
+
+var importStartPattern = regexp.MustCompile(`^(?:import\b|export\s+(?:type\s+)?(?:\*|\{[^}]*\})\s*(?:as\s+[\w$]+\s+)?from\b|(?:const|let|var)\s+[^=]+=\s*require\()`)
+
+// budget returns the byte budget for a request, the smaller of MaxBytes and
+// MaxTokens*approxBytesPerToken; 0 means unlimited.
+func (o Options) budget() int {
+	b := o.MaxBytes
+	if o.MaxTokens > 0 {
+		if t := o.MaxTokens * approxBytesPerToken; b <= 0 || t < b {
+			b = t
+		}
+	}
+	if b < 0 {
+		return 0
+	}
+	return b
+}
+
+type budgetLine struct {
+	num     int
+	text    string
+	class   int
+	cost    int
+	dropped bool
+	cropped bool
+}
+
+// budgetResult is a window after the budget was applied.
+type budgetResult struct {
+	lines   []string
+	omitted []LineRange
+	cropped []uint32
+}
+
+// applyBudget fits the windows of one request into budget bytes of formatted
+// output (each line costs its "N| text" form plus a newline). Blank lines go
+// first, then import statements, then comment lines, each class taken from the
+// last window backwards so earlier (more relevant) results keep theirs longest.
+// If that is not enough, the remaining budget is shared equally between the
+// windows, smaller windows returning their unused share, and each window keeps
+// its lines from the top; a first line longer than its share is cropped.
+func applyBudget(windows []lineWindow, budget int) []budgetResult {
+	items := make([][]budgetLine, len(windows))
+	total := 0
+	for i, w := range windows {
+		classes := classifyLines(w.text)
+		items[i] = make([]budgetLine, len(w.text))
+		for j, text := range w.text {
+			num := w.from + j
+			l := budgetLine{num: num, text: text, class: classes[j], cost: len(formatLine(num, text)) + 1}
+			items[i][j] = l
+			total += l.cost
+		}
+	}
+
+	for class := classBlank; class < classCode && total > budget; class++ {
+		for i := len(items) - 1; i >= 0 && total > budget; i-- {
+			for j := len(items[i]) - 1; j >= 0 && total > budget; j-- {
+				if l := &items[i][j]; !l.dropped && l.class == class {
+					l.dropped = true
+					total -= l.cost
+				}
+			}
+		}
+	}
+	if total > budget {
+		shareBudget(items, budget)
+	}
+
+	out := make([]budgetResult, len(items))
+	for i, lines := range items {
+		res := budgetResult{lines: []string{}}
+		for _, l := range lines {
+			if l.dropped {
+				if n := len(res.omitted); n > 0 && int(res.omitted[n-1].EndLine) == l.num-1 {
+					res.omitted[n-1].EndLine = uint32(l.num)
+				} else {
+					res.omitted = append(res.omitted, LineRange{StartLine: uint32(l.num), EndLine: uint32(l.num)})
+				}
+				continue
+			}
+			res.lines = append(res.lines, formatLine(l.num, l.text))
+			if l.cropped {
+				res.cropped = append(res.cropped, uint32(l.num))
+			}
+		}
+		out[i] = res
+	}
+	return out
+}
+
+// shareBudget truncates windows from the bottom so their kept lines fit budget.
+func shareBudget(items [][]budgetLine, budget int) {
+	costs := make([]int, len(items))
+	order := make([]int, len(items))
+	for i, lines := range items {
+		order[i] = i
+		for _, l := range lines {
+			if !l.dropped {
+				costs[i] += l.cost
+			}
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return costs[order[a]] < costs[order[b]] })
+
+	remaining := budget
+	for k, i := range order {
+		share := remaining / (len(order) - k)
+		if costs[i] <= share {
+			remaining -= costs[i]
+			continue
+		}
+		used := 0
+		full := false
+		for j := range items[i] {
+			l := &items[i][j]
+			if l.dropped {
+				continue
+			}
+			if !full && used+l.cost <= share {
+				used += l.cost
+				continue
+			}
+			if !full && used == 0 {
+				if text, ok := cropLine(l.num, l.text, share); ok {
+					l.text, l.cropped = text, true
+					used = len(formatLine(l.num, text)) + 1
+					full = true
+					continue
+				}
+			}
+			full = true
+			l.dropped = true
+		}
+		remaining -= used
+	}
+}
+
+// cropLine shortens text at a rune boundary so its formatted line fits limit bytes.
+func cropLine(num int, text string, limit int) (string, bool) {
+	room := limit - len(formatLine(num, "")) - 1
+	if room <= 0 {
+		return "", false
+	}
+	if room > len(text) {
+		room = len(text)
+	}
+	for room > 0 && !utf8.RuneStart(text[room]) {
+		room--
+	}
+	if room == 0 {
+		return "", false
+	}
+	return text[:room], true
+}
+
+// classifyLines assigns a class to each line, following multi-line import
+// statements and block comments that start inside the window.
+func classifyLines(lines []string) []int {
+	classes := make([]int, len(lines))
+	inImport, inComment := false, false
+	for i, raw := range lines {
+		t := strings.TrimSpace(raw)
+		switch {
+		case t == "":
+			classes[i] = classBlank
+		case inComment:
+			classes[i] = classComment
+			inComment = !strings.Contains(t, "*/")
+		case strings.HasPrefix(t, "//"), t == "*", strings.HasPrefix(t, "* "), strings.HasPrefix(t, "*/"):
+			classes[i] = classComment
+		case strings.HasPrefix(t, "/*"):
+			classes[i] = classComment
+			inComment = !strings.Contains(t[2:], "*/")
+		case inImport:
+			classes[i] = classImport
+			inImport = !strings.Contains(t, "from") && !strings.HasSuffix(t, ";")
+		case importStartPattern.MatchString(t):
+			classes[i] = classImport
+			// "import {" opens a specifier list that continues until "from".
+			inImport = strings.Contains(t, "{") && !strings.Contains(t, "}")
+		default:
+			classes[i] = classCode
+		}
+	}
+	return classes
+}
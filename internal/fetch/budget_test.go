@@ -0,0 +1,149 @@
+package fetch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/index"
+)
+
+func testWindow(from int, text ...string) lineWindow {
+	return lineWindow{from: from, to: from + len(text) - 1, last: from + len(text) - 1, text: text}
+}
+
+func windowCost(w lineWindow) int {
+	total := 0
+	for j, text := range w.text {
+		total += len(formatLine(w.from+j, text)) + 1
+	}
+	return total
+}
+
+func TestClassifyLines(t *testing.T) {
+	lines := []string{
+		"import fs from 'fs';",
+		"import {",
+		"  readFile,",
+		"  writeFile,",
+		"} from 'fs/promises';",
+		"export * from './types';",
+		"const path = require('path');",
+		"",
+		"/**",
+		" * Banner.",
+		" */",
+		"// ------------",
+		"export function run() {",
+		"  return 1;",
+		"}",
+	}
+	want := []int{classImport, classImport, classImport, classImport, classImport, classImport, classImport,
+		classBlank, classComment, classComment, classComment, classComment, classCode, classCode, classCode}
+	got := classifyLines(lines)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("unexpected classes:\n%v\n%v", got, want)
+	}
+}
+
+func TestApplyBudgetDropsLeastRelevantLinesFirst(t *testing.T) {
+	w := testWindow(1,
+		"import { a } from './a';",
+		"",
+		"// helper banner",
+		"export function run() {",
+		"",
+		"  return a;",
+		"}",
+	)
+	full := windowCost(w)
+
+	got := applyBudget([]lineWindow{w}, full)[0]
+	if len(got.lines) != 7 || got.omitted != nil {
+		t.Fatalf("a request within budget should be unchanged: %+v", got)
+	}
+
+	// Dropping both blank lines is enough.
+	got = applyBudget([]lineWindow{w}, full-len("2| ")-len("5| ")-2)[0]
+	if fmt.Sprint(got.omitted) != "[{2 2} {5 5}]" || len(got.lines) != 5 || got.lines[0] != "1| import { a } from './a';" {
+		t.Fatalf("expected only blank lines omitted: %+v", got)
+	}
+
+	// Then the import and the comment go, before any code.
+	code := len("4| export function run() {") + len("6|   return a;") + len("7| }") + 3
+	got = applyBudget([]lineWindow{w}, code)[0]
+	if fmt.Sprint(got.omitted) != "[{1 3} {5 5}]" || strings.Join(got.lines, "\n") != "4| export function run() {\n6|   return a;\n7| }" {
+		t.Fatalf("expected imports and comments omitted before code: %+v", got)
+	}
+}
+
+func TestApplyBudgetSharesAcrossWindows(t *testing.T) {
+	var long []string
+	for i := 0; i < 40; i++ {
+		long = append(long, fmt.Sprintf("  total += values[%d];", i))
+	}
+	small := testWindow(10, "export const a = 1;", "export const b = 2;")
+	big := testWindow(100, long...)
+	budget := windowCost(small) + 200
+
+	got := applyBudget([]lineWindow{big, small}, budget)
+	if len(got[1].lines) != 2 || got[1].omitted != nil {
+		t.Fatalf("small window should keep all lines: %+v", got[1])
+	}
+	used := 0
+	for _, l := range got[0].lines {
+		used += len(l) + 1
+	}
+	if used > 200 || len(got[0].lines) == 0 || got[0].lines[0] != "100|   total += values[0];" {
+		t.Fatalf("big window should keep its top lines within the rest of the budget: %d bytes %+v", used, got[0].lines)
+	}
+	kept := len(got[0].lines)
+	if fmt.Sprint(got[0].omitted) != fmt.Sprintf("[{%d 139}]", 100+kept) {
+		t.Fatalf("expected the tail reported as omitted: %+v", got[0].omitted)
+	}
+}
+
+func TestApplyBudgetCropsMinifiedLines(t *testing.T) {
+	minified := "var a=1;" + strings.Repeat("b();", 5000)
+	got := applyBudget([]lineWindow{testWindow(1, minified, "c();")}, 100)[0]
+	if len(got.lines) != 1 || len(got.lines[0])+1 > 100 || !strings.HasPrefix(got.lines[0], "1| var a=1;b();") {
+		t.Fatalf("expected a cropped first line: %+v", got.lines)
+	}
+	if fmt.Sprint(got.cropped) != "[1]" || fmt.Sprint(got.omitted) != "[{2 2}]" {
+		t.Fatalf("unexpected cropped/omitted report: %+v", got)
+	}
+}
+
+func TestFetchMaxTokensSharedAcrossIDs(t *testing.T) {
+	root := t.TempDir()
+	var lines []string
+	for i := 1; i <= 100; i++ {
+		lines = append(lines, fmt.Sprintf("export const value%d = %d;", i, i))
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.ts"), []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	chunkMap := map[uint32]index.ChunkEntry{
+		1: {ChunkID: 1, FileID: 1, Path: "a.ts", StartLine: 1, EndLine: 50},
+		2: {ChunkID: 2, FileID: 1, Path: "a.ts", StartLine: 51, EndLine: 100},
+	}
+	results, err := FetchWithChunkMap(root, chunkMap, nil, nil, []uint32{1, 2}, Options{MaxTokens: 250, MaxBytes: 5000}, config.DefaultConfig().Limits)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	total := 0
+	for _, res := range results {
+		if len(res.Lines) == 0 || len(res.Omitted) != 1 || res.Omitted[0].EndLine != res.ReturnedTo {
+			t.Fatalf("expected each chunk to keep its head and report its tail: %+v", res)
+		}
+		for _, l := range res.Lines {
+			total += len(l) + 1
+		}
+	}
+	if total > 1000 {
+		t.Fatalf("expected the 250-token budget (1000 bytes) to win over max_bytes, used %d", total)
+	}
+}
@@ -0,0 +1,199 @@
+package fetch
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/gitx"
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/store"
+)
+
+// Diff lists the changes between Rev and the working tree that touch a line
+// range of a file.
+type Diff struct {
+	ChunkID   uint32      `json:"chunk_id,omitempty"`
+	Path      string      `json:"path"`
+	StartLine uint32      `json:"start_line"`
+	EndLine   uint32      `json:"end_line"`
+	Rev       string      `json:"rev"`
+	Hunks     []gitx.Hunk `json:"hunks"`
+}
+
+// BaseRev returns the commit the index was built at (meta.RepoHead), the
+// default revision for diffs.
+func BaseRev(root string) (string, error) {
+	meta, err := store.LoadMeta(store.MetaPath(root))
+	if err != nil {
+		return "", err
+	}
+	if meta.RepoHead == "" {
+		return "", fmt.Errorf("index has no base revision; pass rev explicitly")
+	}
+	return meta.RepoHead, nil
+}
+
+// DiffChunks loads the index and runs DiffChunksWithChunkMap; an empty rev
+// means BaseRev.
+func DiffChunks(root string, ids []uint32, rev string) ([]Diff, error) {
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		return nil, err
+	}
+	defer lease.Release()
+
+	cfg, _, err := config.Load(store.ConfigPath(root))
+	if err != nil {
+		return nil, err
+	}
+	if rev == "" {
+		if rev, err = BaseRev(root); err != nil {
+			return nil, err
+		}
+	}
+	chunks, err := index.LoadChunkEntries(gen.ChunksPath())
+	if err != nil {
+		return nil, err
+	}
+	chunkMap := make(map[uint32]index.ChunkEntry, len(chunks))
+	for _, ch := range chunks {
+		chunkMap[ch.ChunkID] = ch
+	}
+	return DiffChunksWithChunkMap(root, chunkMap, ids, rev, cfg.Limits)
+}
+
+// DiffChunksWithChunkMap returns, for each chunk id, the hunks between rev and the working
+// tree whose old-side lines touch the chunk's indexed line range. Only the
+// first limits.FetchMaxIDs ids are processed.
+func DiffChunksWithChunkMap(root string, chunkMap map[uint32]index.ChunkEntry, ids []uint32, rev string, limits config.LimitsConfig) ([]Diff, error) {
+	maxIDs := limits.FetchMaxIDs
+	if maxIDs <= 0 {
+		maxIDs = config.DefaultFetchMaxIDs
+	}
+	if len(ids) > maxIDs {
+		ids = ids[:maxIDs]
+	}
+	if err := gitx.ValidateRev(rev); err != nil {
+		return nil, fmt.Errorf("invalid rev: %w", err)
+	}
+	rootReal, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root: %w", err)
+	}
+
+	byPath := make(map[string][]gitx.Hunk)
+	results := make([]Diff, 0, len(ids))
+	for _, id := range ids {
+		ch, ok := chunkMap[id]
+		if !ok {
+			return nil, fmt.Errorf("chunk %d not found in index", id)
+		}
+		hunks, ok := byPath[ch.Path]
+		if !ok {
+			hunks, err = fileHunks(rootReal, ch.Path, rev)
+			if err != nil {
+				return nil, fmt.Errorf("chunk %d: %w", id, err)
+			}
+			byPath[ch.Path] = hunks
+		}
+		results = append(results, Diff{
+			ChunkID:   ch.ChunkID,
+			Path:      ch.Path,
+			StartLine: ch.StartLine,
+			EndLine:   ch.EndLine,
+			Rev:       rev,
+			Hunks:     hunksTouching(hunks, int(ch.StartLine), int(ch.EndLine)),
+		})
+	}
+	return results, nil
+}
+
+// DiffRange loads the file list and runs DiffRangeWithFiles; an empty rev
+// means BaseRev.
+func DiffRange(root string, path string, startLine, endLine int, rev string) (Diff, error) {
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		return Diff{}, err
+	}
+	defer lease.Release()
+
+	files, err := index.LoadFileEntries(gen.FilesPath())
+	if err != nil {
+		return Diff{}, err
+	}
+	if rev == "" {
+		if rev, err = BaseRev(root); err != nil {
+			return Diff{}, err
+		}
+	}
+	return DiffRangeWithFiles(root, files, path, startLine, endLine, rev)
+}
+
+// DiffRangeWithFiles is DiffChunksWithChunkMap for a line range of an indexed
+// file; endLine 0 means startLine. Paths are checked as for FetchRangeWithFiles.
+func DiffRangeWithFiles(root string, files []index.FileEntry, path string, startLine, endLine int, rev string) (Diff, error) {
+	if _, err := cleanRelPath(path); err != nil {
+		return Diff{}, fmt.Errorf("path %s rejected: %w", path, err)
+	}
+	fe, ok := index.FileByPath(files, path)
+	if !ok {
+		return Diff{}, fmt.Errorf("path not indexed: %s", path)
+	}
+	if startLine < 1 {
+		return Diff{}, fmt.Errorf("start_line must be at least 1")
+	}
+	if endLine == 0 {
+		endLine = startLine
+	}
+	if endLine < startLine {
+		return Diff{}, fmt.Errorf("end_line %d is before start_line %d", endLine, startLine)
+	}
+	if err := gitx.ValidateRev(rev); err != nil {
+		return Diff{}, fmt.Errorf("invalid rev: %w", err)
+	}
+	rootReal, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return Diff{}, fmt.Errorf("resolve root: %w", err)
+	}
+	hunks, err := fileHunks(rootReal, fe.Path, rev)
+	if err != nil {
+		return Diff{}, err
+	}
+	return Diff{
+		Path:      fe.Path,
+		StartLine: uint32(startLine),
+		EndLine:   uint32(endLine),
+		Rev:       rev,
+		Hunks:     hunksTouching(hunks, startLine, endLine),
+	}, nil
+}
+
+func fileHunks(rootReal, path, rev string) ([]gitx.Hunk, error) {
+	clean, err := cleanRelPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("path %s rejected: %w", path, err)
+	}
+	hunks, err := gitx.DiffHunks(rootReal, rev, filepath.ToSlash(clean))
+	if err != nil {
+		return nil, fmt.Errorf("path %s: %w", path, err)
+	}
+	return hunks, nil
+}
+
+// hunksTouching keeps hunks whose old-side lines overlap start..end. A pure
+// insertion (OldLines 0) sits after line OldStart and touches the range when
+// that gap is inside it or on its edges.
+func hunksTouching(hunks []gitx.Hunk, start, end int) []gitx.Hunk {
+	out := []gitx.Hunk{}
+	for _, h := range hunks {
+		from, to := h.OldStart, h.OldStart+h.OldLines-1
+		if h.OldLines == 0 {
+			from, to = h.OldStart, h.OldStart+1
+		}
+		if from <= end && to >= start {
+			out = append(out, h)
+		}
+	}
+	return out
+}
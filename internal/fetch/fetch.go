@@ -6,8 +6,13 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/gitx"
+	"github.com/memkit/repodex/internal/hash"
 	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/lang"
 	"github.com/memkit/repodex/internal/store"
+	"github.com/memkit/repodex/internal/textutil"
 )
 
 // Request describes a fetch request.
@@ -25,11 +30,116 @@ type ChunkText struct {
 	ReturnedFrom uint32   `json:"returned_from"`
 	ReturnedTo   uint32   `json:"returned_to"`
 	Lines        []string `json:"lines"`
+	// Enclosing is set when ModeEnclosing widened the chunk to a declaration;
+	// StartLine and EndLine then describe the declaration's span.
+	Enclosing *Enclosing `json:"enclosing,omitempty"`
+	// Truncated reports that lines past ReturnedTo were cut by MaxLines; Next
+	// is the rest of the range, ready for a range fetch.
+	Truncated bool       `json:"truncated,omitempty"`
+	Next      *LineRange `json:"next,omitempty"`
+	// Stale reports that the file changed since the last sync, so the chunk's
+	// line range may no longer match it. Source is SourceSnapshot when the
+	// lines were served from snapshots.dat instead of the working tree.
+	Stale  bool   `json:"stale,omitempty"`
+	Source string `json:"source,omitempty"`
+	// Rev is the revision the lines were read at when Options.Rev was set.
+	Rev string `json:"rev,omitempty"`
+	// Omitted lists lines inside ReturnedFrom..ReturnedTo dropped to fit a
+	// byte or token budget; Cropped lists kept lines whose text was shortened.
+	Omitted []LineRange `json:"omitted,omitempty"`
+	Cropped []uint32    `json:"cropped,omitempty"`
+	// ChunkIDs lists, in line order, the chunks merged into this block when
+	// requested ids overlap or touch in the same file; ChunkID is then the
+	// first of them in request order.
+	ChunkIDs []uint32 `json:"chunk_ids,omitempty"`
 }
 
-// Fetch returns chunk text constrained by limits.
-func Fetch(root string, ids []uint32, maxLines int) ([]ChunkText, error) {
-	chunks, err := index.LoadChunkEntries(store.ChunksPath(root))
+const (
+	// SourceSnapshot marks lines read from the indexed snapshot of a file.
+	SourceSnapshot = "snapshot"
+	// SourceGit marks lines read from a git revision.
+	SourceGit = "git"
+)
+
+// Enclosing names the declaration a chunk was widened to.
+type Enclosing struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Parent string `json:"parent,omitempty"`
+}
+
+// LineRange is an inclusive, 1-based line range.
+type LineRange struct {
+	StartLine uint32 `json:"start_line"`
+	EndLine   uint32 `json:"end_line"`
+}
+
+const (
+	// ModeChunk returns each chunk's own lines. It is the default.
+	ModeChunk = "chunk"
+	// ModeEnclosing widens each chunk to the innermost function, class or
+	// method whose span contains it.
+	ModeEnclosing = "enclosing"
+)
+
+// Options controls how many lines a fetch returns. MaxLines defaults to and is
+// capped at Limits.FetchMaxLines; Before and After add context lines around the
+// requested range and count towards MaxLines.
+type Options struct {
+	MaxLines int
+	Before   int
+	After    int
+	// Mode is ModeChunk (or empty) or ModeEnclosing; range fetches ignore it.
+	Mode string
+	// Rev reads content at a git revision instead of the working tree.
+	Rev string
+	// MaxBytes and MaxTokens (about 4 bytes each) cap the formatted lines of
+	// the whole request, shared across all ids; the smaller one applies and
+	// 0 means no budget.
+	MaxBytes  int
+	MaxTokens int
+	// SnapshotsPath is the snapshots.dat of the generation the chunk map and
+	// files came from; empty means the current generation.
+	SnapshotsPath string
+}
+
+// RangeText contains extracted lines for a path and line range.
+type RangeText struct {
+	Path         string     `json:"path"`
+	StartLine    uint32     `json:"start_line"`
+	EndLine      uint32     `json:"end_line"`
+	ReturnedFrom uint32     `json:"returned_from"`
+	ReturnedTo   uint32     `json:"returned_to"`
+	Lines        []string   `json:"lines"`
+	Truncated    bool       `json:"truncated,omitempty"`
+	Next         *LineRange `json:"next,omitempty"`
+	// Stale reports that the file changed since the last sync. Range fetches
+	// never use snapshots.
+	Stale bool `json:"stale,omitempty"`
+	// Rev is the revision the lines were read at when Options.Rev was set.
+	Rev string `json:"rev,omitempty"`
+	// Omitted and Cropped report budget trimming as for ChunkText.
+	Omitted []LineRange `json:"omitted,omitempty"`
+	Cropped []uint32    `json:"cropped,omitempty"`
+}
+
+// Fetch returns chunk text constrained by the configured limits.
+func Fetch(root string, ids []uint32, opts Options) ([]ChunkText, error) {
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		return nil, err
+	}
+	defer lease.Release()
+
+	cfg, _, err := config.Load(store.ConfigPath(root))
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := index.LoadChunkEntries(gen.ChunksPath())
+	if err != nil {
+		return nil, err
+	}
+	files, err := index.LoadFileEntries(gen.FilesPath())
 	if err != nil {
 		return nil, err
 	}
@@ -37,107 +147,412 @@ func Fetch(root string, ids []uint32, maxLines int) ([]ChunkText, error) {
 	for _, ch := range chunks {
 		chunkMap[ch.ChunkID] = ch
 	}
+	var symbols []index.SymbolEntry
+	if opts.Mode == ModeEnclosing {
+		symbols, err = index.LoadSymbols(gen.SymbolsPath())
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return FetchWithChunkMap(root, chunkMap, ids, maxLines)
+	opts.SnapshotsPath = gen.SnapshotsPath()
+	return FetchWithChunkMap(root, chunkMap, files, symbols, ids, opts, cfg.Limits)
 }
 
 // FetchWithChunkMap returns chunk text constrained by limits using a preloaded chunk map.
-func FetchWithChunkMap(root string, chunkMap map[uint32]index.ChunkEntry, ids []uint32, maxLines int) ([]ChunkText, error) {
-	if len(ids) > 5 {
-		ids = ids[:5]
+// Only the first limits.FetchMaxIDs ids are processed. files provides the indexed
+// hashes used to detect stale chunks; symbols is only consulted in ModeEnclosing.
+// Stale chunks are served from snapshots.dat when the index has one. Chunks of
+// the same file whose ranges overlap or touch come back as one merged block.
+func FetchWithChunkMap(root string, chunkMap map[uint32]index.ChunkEntry, files []index.FileEntry, symbols []index.SymbolEntry, ids []uint32, opts Options, limits config.LimitsConfig) ([]ChunkText, error) {
+	if opts.Mode != "" && opts.Mode != ModeChunk && opts.Mode != ModeEnclosing {
+		return nil, fmt.Errorf("unknown fetch mode %q", opts.Mode)
 	}
-	if maxLines <= 0 || maxLines > 120 {
-		maxLines = 120
+	if opts.Rev != "" {
+		if err := gitx.ValidateRev(opts.Rev); err != nil {
+			return nil, fmt.Errorf("invalid rev: %w", err)
+		}
+	}
+	maxIDs := limits.FetchMaxIDs
+	if maxIDs <= 0 {
+		maxIDs = config.DefaultFetchMaxIDs
+	}
+	if len(ids) > maxIDs {
+		ids = ids[:maxIDs]
 	}
+	opts = clampOptions(opts, limits)
 
 	rootReal, err := filepath.EvalSymlinks(root)
 	if err != nil {
 		return nil, fmt.Errorf("resolve root: %w", err)
 	}
+	snapshotsPath := opts.SnapshotsPath
+	if snapshotsPath == "" {
+		snapshotsPath = store.SnapshotsPath(root)
+	}
 
-	var results []ChunkText
+	var items []fetched
 	for _, id := range ids {
 		ch, ok := chunkMap[id]
 		if !ok {
 			return nil, fmt.Errorf("chunk %d not found in index", id)
 		}
-		fullPath, err := resolvePath(rootReal, ch.Path)
-		if err != nil {
-			return nil, fmt.Errorf("chunk %d path %s rejected: %w", id, ch.Path, err)
+		var lines []string
+		var stale bool
+		var source string
+		if opts.Rev != "" {
+			data, err := readSource(rootReal, ch.Path, opts.Rev)
+			if err != nil {
+				return nil, fmt.Errorf("chunk %d: %w", id, err)
+			}
+			lines, stale, source = splitLines(data), isStale(files, ch.FileID, data), SourceGit
+		} else if lines, stale, source, err = IndexedLines(rootReal, files, snapshotsPath, ch.FileID, ch.Path); err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", id, err)
 		}
-		data, err := os.ReadFile(fullPath)
-		if err != nil {
-			return nil, fmt.Errorf("chunk %d path %s: %w", id, ch.Path, err)
+
+		res := ChunkText{
+			ChunkID:   ch.ChunkID,
+			Path:      ch.Path,
+			StartLine: ch.StartLine,
+			EndLine:   ch.EndLine,
+			Stale:     stale,
+			Source:    source,
+			Rev:       opts.Rev,
 		}
+		if opts.Mode == ModeEnclosing {
+			if sym, ok := enclosingSymbol(symbols, ch, lines); ok {
+				// The declaration may start below the chunk or end above it;
+				// the widened range keeps all of the chunk either way.
+				if sym.StartLine < res.StartLine {
+					res.StartLine = sym.StartLine
+				}
+				if sym.EndLine > res.EndLine {
+					res.EndLine = sym.EndLine
+				}
+				res.Enclosing = &Enclosing{Name: sym.Name, Kind: sym.Kind, Parent: sym.Parent}
+			}
+		}
+		w := window(lines, int(res.StartLine), int(res.EndLine), opts)
+		res.ReturnedFrom, res.ReturnedTo, res.Lines = uint32(w.from), uint32(w.to), w.lines
+		res.Truncated, res.Next = w.next()
+		items = append(items, fetched{res: res, w: w, lines: lines})
+	}
 
-		text := string(data)
-		text = strings.ReplaceAll(text, "\r\n", "\n")
-		text = strings.ReplaceAll(text, "\r", "\n")
-		text = strings.TrimRight(text, "\n")
+	items = mergeOverlapping(items, opts.MaxLines)
+	results := make([]ChunkText, len(items))
+	windows := make([]lineWindow, len(items))
+	for i, it := range items {
+		results[i], windows[i] = it.res, it.w
+	}
 
-		var lines []string
-		if text != "" {
-			lines = strings.Split(text, "\n")
+	if budget := opts.budget(); budget > 0 {
+		for i, b := range applyBudget(windows, budget) {
+			results[i].Lines, results[i].Omitted, results[i].Cropped = b.lines, b.omitted, b.cropped
 		}
+	}
+	return results, nil
+}
 
-		if len(lines) == 0 {
-			results = append(results, ChunkText{
-				ChunkID:      ch.ChunkID,
-				Path:         ch.Path,
-				StartLine:    ch.StartLine,
-				EndLine:      ch.EndLine,
-				ReturnedFrom: 0,
-				ReturnedTo:   0,
-				Lines:        []string{},
-			})
-			continue
-		}
+// FetchRange returns lines startLine..endLine of an indexed file.
+func FetchRange(root string, path string, startLine, endLine int, opts Options) (RangeText, error) {
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		return RangeText{}, err
+	}
+	defer lease.Release()
 
-		start := int(ch.StartLine)
-		if start < 1 {
-			start = 1
-		}
-		if start > len(lines) {
-			start = len(lines)
+	cfg, _, err := config.Load(store.ConfigPath(root))
+	if err != nil {
+		return RangeText{}, err
+	}
+	files, err := index.LoadFileEntries(gen.FilesPath())
+	if err != nil {
+		return RangeText{}, err
+	}
+	return FetchRangeWithFiles(root, files, path, startLine, endLine, opts, cfg.Limits)
+}
+
+// FetchRangeWithFiles returns lines startLine..endLine (endLine 0 means
+// startLine) of a file listed in files. Absolute paths and ".." segments are
+// rejected before the lookup, and the file is read through the same
+// root-confined resolution as chunk fetches.
+func FetchRangeWithFiles(root string, files []index.FileEntry, path string, startLine, endLine int, opts Options, limits config.LimitsConfig) (RangeText, error) {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "/") {
+		return RangeText{}, fmt.Errorf("path %s rejected: absolute paths are not allowed", path)
+	}
+	if hasDotDotSegment(path) {
+		return RangeText{}, fmt.Errorf("path %s rejected: path traversal detected", path)
+	}
+	fe, ok := index.FileByPath(files, path)
+	if !ok {
+		return RangeText{}, fmt.Errorf("path not indexed: %s", path)
+	}
+	if startLine < 1 {
+		return RangeText{}, fmt.Errorf("start_line must be at least 1")
+	}
+	if endLine == 0 {
+		endLine = startLine
+	}
+	if endLine < startLine {
+		return RangeText{}, fmt.Errorf("end_line %d is before start_line %d", endLine, startLine)
+	}
+	if opts.Rev != "" {
+		if err := gitx.ValidateRev(opts.Rev); err != nil {
+			return RangeText{}, fmt.Errorf("invalid rev: %w", err)
 		}
+	}
+	opts = clampOptions(opts, limits)
 
-		end := int(ch.EndLine)
-		if end < start {
-			end = start
+	rootReal, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return RangeText{}, fmt.Errorf("resolve root: %w", err)
+	}
+	data, err := readSource(rootReal, fe.Path, opts.Rev)
+	if err != nil {
+		return RangeText{}, err
+	}
+	lines := splitLines(data)
+	if startLine > len(lines) {
+		return RangeText{}, fmt.Errorf("start_line %d is past the end of %s (%d lines)", startLine, fe.Path, len(lines))
+	}
+	w := window(lines, startLine, endLine, opts)
+	res := RangeText{
+		Path:         fe.Path,
+		StartLine:    uint32(startLine),
+		EndLine:      uint32(endLine),
+		ReturnedFrom: uint32(w.from),
+		ReturnedTo:   uint32(w.to),
+		Lines:        w.lines,
+		Stale:        hash.Sum64(textutil.NormalizeNewlinesBytes(data)) != fe.Hash64,
+		Rev:          opts.Rev,
+	}
+	res.Truncated, res.Next = w.next()
+	if budget := opts.budget(); budget > 0 {
+		b := applyBudget([]lineWindow{w}, budget)[0]
+		res.Lines, res.Omitted, res.Cropped = b.lines, b.omitted, b.cropped
+	}
+	return res, nil
+}
+
+// IndexedLines reads the lines of path as it was indexed. When the working
+// tree copy still hashes to the indexed Hash64 of fileID its lines are
+// returned; otherwise stale is set and the file's snapshot from snapshotsPath is
+// returned with SourceSnapshot, or the changed working tree copy with an
+// empty source when the index has no snapshot of it.
+func IndexedLines(rootReal string, files []index.FileEntry, snapshotsPath string, fileID uint32, path string) (lines []string, stale bool, source string, err error) {
+	data, err := readFile(rootReal, path)
+	if err != nil {
+		return nil, false, "", err
+	}
+	if !isStale(files, fileID, data) {
+		return splitLines(data), false, "", nil
+	}
+	snapshot, ok, err := index.ReadSnapshot(snapshotsPath, fileID)
+	if err != nil {
+		return nil, true, "", fmt.Errorf("path %s: %w", path, err)
+	}
+	if ok {
+		return splitLines(snapshot), true, SourceSnapshot, nil
+	}
+	return splitLines(data), true, "", nil
+}
+
+// isStale reports whether data no longer hashes to the indexed Hash64 of fileID.
+// Files missing from files are not reported.
+func isStale(files []index.FileEntry, fileID uint32, data []byte) bool {
+	fe, ok := index.FileByID(files, fileID)
+	if !ok {
+		return false
+	}
+	return hash.Sum64(textutil.NormalizeNewlinesBytes(data)) != fe.Hash64
+}
+
+// enclosingSymbol returns the function, class or method in the chunk's file
+// that the chunk belongs to: the smallest one containing the chunk's first
+// code line, which skips the blank and comment lines a chunk often starts
+// with, or else the first declaration overlapping the chunk (the outermost
+// when several start on one line), e.g. a class under its decorators.
+func enclosingSymbol(symbols []index.SymbolEntry, ch index.ChunkEntry, lines []string) (index.SymbolEntry, bool) {
+	first := ch.StartLine
+	for first < ch.EndLine && int(first) <= len(lines) && isCommentOrBlank(lines[first-1]) {
+		first++
+	}
+	var best, overlap index.SymbolEntry
+	found, overlaps := false, false
+	for _, sym := range symbols {
+		if sym.FileID != ch.FileID {
+			continue
 		}
-		if end > len(lines) {
-			end = len(lines)
+		switch sym.Kind {
+		case lang.KindFunction, lang.KindClass, lang.KindMethod:
+		default:
+			continue
 		}
-
-		returnedTo := end
-		if (end - start + 1) > maxLines {
-			returnedTo = start + maxLines - 1
+		if sym.StartLine > ch.EndLine || sym.EndLine < ch.StartLine {
+			continue
 		}
-		if returnedTo > end {
-			returnedTo = end
+		if sym.StartLine <= first && sym.EndLine >= first {
+			if !found || sym.EndLine-sym.StartLine < best.EndLine-best.StartLine {
+				best, found = sym, true
+			}
 		}
-
-		var formatted []string
-		for i := start; i <= returnedTo; i++ {
-			lineText := lines[i-1]
-			formatted = append(formatted, fmt.Sprintf("%d| %s", i, lineText))
+		if !overlaps || sym.StartLine < overlap.StartLine ||
+			(sym.StartLine == overlap.StartLine && sym.EndLine > overlap.EndLine) {
+			overlap, overlaps = sym, true
 		}
+	}
+	if found {
+		return best, true
+	}
+	return overlap, overlaps
+}
+
+// isCommentOrBlank reports lines holding nothing but whitespace or a comment.
+func isCommentOrBlank(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*") || strings.HasPrefix(trimmed, "*")
+}
+
+func clampOptions(opts Options, limits config.LimitsConfig) Options {
+	lineCap := limits.FetchMaxLines
+	if lineCap <= 0 {
+		lineCap = config.DefaultFetchMaxLines
+	}
+	if opts.MaxLines <= 0 || opts.MaxLines > lineCap {
+		opts.MaxLines = lineCap
+	}
+	if opts.Before < 0 {
+		opts.Before = 0
+	}
+	if opts.After < 0 {
+		opts.After = 0
+	}
+	if opts.MaxBytes < 0 {
+		opts.MaxBytes = 0
+	}
+	if opts.MaxTokens < 0 {
+		opts.MaxTokens = 0
+	}
+	return opts
+}
 
-		results = append(results, ChunkText{
-			ChunkID:      ch.ChunkID,
-			Path:         ch.Path,
-			StartLine:    ch.StartLine,
-			EndLine:      ch.EndLine,
-			ReturnedFrom: uint32(start),
-			ReturnedTo:   uint32(returnedTo),
-			Lines:        formatted,
-		})
+// lineWindow is the part of a file returned by a fetch. last is the end of
+// the requested range, including context, before MaxLines was applied.
+type lineWindow struct {
+	from, to, last int
+	// text holds the raw lines from..to; lines holds them formatted.
+	text  []string
+	lines []string
+}
+
+// next reports whether MaxLines cut the window and, if so, the remaining range.
+func (w lineWindow) next() (bool, *LineRange) {
+	if w.to >= w.last {
+		return false, nil
 	}
+	return true, &LineRange{StartLine: uint32(w.to + 1), EndLine: uint32(w.last)}
+}
 
-	return results, nil
+// window widens start..end by the context lines, clamps it to the file, caps
+// it at opts.MaxLines from the top, and formats each line as "N| text".
+// An empty file yields 0, 0 and no lines.
+func window(lines []string, start, end int, opts Options) lineWindow {
+	if len(lines) == 0 {
+		return lineWindow{lines: []string{}}
+	}
+	start -= opts.Before
+	if start < 1 {
+		start = 1
+	}
+	if start > len(lines) {
+		start = len(lines)
+	}
+	end += opts.After
+	if end < start {
+		end = start
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	w := lineWindow{from: start, to: end, last: end}
+	if end-start+1 > opts.MaxLines {
+		w.to = start + opts.MaxLines - 1
+	}
+
+	w.text = lines[start-1 : w.to]
+	w.lines = make([]string, 0, w.to-start+1)
+	for i := start; i <= w.to; i++ {
+		w.lines = append(w.lines, formatLine(i, lines[i-1]))
+	}
+	return w
+}
+
+// formatLine renders a fetched line as "N| text".
+func formatLine(num int, text string) string {
+	return fmt.Sprintf("%d| %s", num, text)
+}
+
+// readSource reads path from the working tree, or at rev through git when rev
+// is set. Revision reads only need the lexical path checks: git resolves the
+// path inside the revision's tree, and the file may no longer exist on disk.
+func readSource(rootReal string, path string, rev string) ([]byte, error) {
+	if rev == "" {
+		return readFile(rootReal, path)
+	}
+	clean, err := cleanRelPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("path %s rejected: %w", path, err)
+	}
+	data, err := gitx.Show(rootReal, rev, filepath.ToSlash(clean))
+	if err != nil {
+		return nil, fmt.Errorf("path %s at %s: %w", path, rev, err)
+	}
+	return data, nil
+}
+
+func readFile(rootReal string, path string) ([]byte, error) {
+	fullPath, err := resolvePath(rootReal, path)
+	if err != nil {
+		return nil, fmt.Errorf("path %s rejected: %w", path, err)
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("path %s: %w", path, err)
+	}
+	return data, nil
+}
+
+func splitLines(data []byte) []string {
+	text := string(data)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
 }
 
 func resolvePath(rootReal string, chunkPath string) (string, error) {
+	clean, err := cleanRelPath(chunkPath)
+	if err != nil {
+		return "", err
+	}
+	full := filepath.Join(rootReal, clean)
+	fullReal, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		return "", err
+	}
+	separator := string(os.PathSeparator)
+	if fullReal != rootReal && !strings.HasPrefix(fullReal, rootReal+separator) {
+		return "", fmt.Errorf("path escapes root")
+	}
+	return fullReal, nil
+}
+
+// cleanRelPath applies the lexical checks of resolvePath and returns the
+// cleaned path without touching the filesystem.
+func cleanRelPath(chunkPath string) (string, error) {
 	if filepath.IsAbs(chunkPath) {
 		return "", fmt.Errorf("absolute paths are not allowed")
 	}
@@ -154,16 +569,7 @@ func resolvePath(rootReal string, chunkPath string) (string, error) {
 	if clean == ".." || strings.HasPrefix(clean, ".."+string(os.PathSeparator)) {
 		return "", fmt.Errorf("path traversal detected")
 	}
-	full := filepath.Join(rootReal, clean)
-	fullReal, err := filepath.EvalSymlinks(full)
-	if err != nil {
-		return "", err
-	}
-	separator := string(os.PathSeparator)
-	if fullReal != rootReal && !strings.HasPrefix(fullReal, rootReal+separator) {
-		return "", fmt.Errorf("path escapes root")
-	}
-	return fullReal, nil
+	return clean, nil
 }
 
 func hasDotDotSegment(p string) bool {
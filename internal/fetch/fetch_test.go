@@ -3,11 +3,13 @@ package fetch
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/hash"
 	"github.com/memkit/repodex/internal/index"
 	"github.com/memkit/repodex/internal/store"
 )
@@ -35,11 +37,11 @@ func TestFetchRespectsMaxLines(t *testing.T) {
 	chunks := []index.ChunkEntry{
 		{ChunkID: 1, FileID: 1, Path: "file.ts", StartLine: 1, EndLine: 150, Snippet: "long chunk"},
 	}
-	if err := index.Serialize(root, files, chunks, map[string][]uint32{}); err != nil {
+	if err := index.Serialize(root, files, chunks, map[string][]index.Posting{}); err != nil {
 		t.Fatalf("serialize failed: %v", err)
 	}
 
-	results, err := Fetch(root, []uint32{1}, 10)
+	results, err := Fetch(root, []uint32{1}, Options{MaxLines: 10})
 	if err != nil {
 		t.Fatalf("fetch failed: %v", err)
 	}
@@ -77,11 +79,11 @@ func TestFetchEmptyFile(t *testing.T) {
 	chunks := []index.ChunkEntry{
 		{ChunkID: 1, FileID: 1, Path: "empty.ts", StartLine: 1, EndLine: 5, Snippet: "empty"},
 	}
-	if err := index.Serialize(root, files, chunks, map[string][]uint32{}); err != nil {
+	if err := index.Serialize(root, files, chunks, map[string][]index.Posting{}); err != nil {
 		t.Fatalf("serialize failed: %v", err)
 	}
 
-	results, err := Fetch(root, []uint32{1}, 10)
+	results, err := Fetch(root, []uint32{1}, Options{MaxLines: 10})
 	if err != nil {
 		t.Fatalf("fetch failed: %v", err)
 	}
@@ -117,11 +119,11 @@ func TestFetchTrailingNewline(t *testing.T) {
 	chunks := []index.ChunkEntry{
 		{ChunkID: 1, FileID: 1, Path: "with_newline.ts", StartLine: 0, EndLine: 10, Snippet: "with newline"},
 	}
-	if err := index.Serialize(root, files, chunks, map[string][]uint32{}); err != nil {
+	if err := index.Serialize(root, files, chunks, map[string][]index.Posting{}); err != nil {
 		t.Fatalf("serialize failed: %v", err)
 	}
 
-	results, err := Fetch(root, []uint32{1}, 120)
+	results, err := Fetch(root, []uint32{1}, Options{MaxLines: 120})
 	if err != nil {
 		t.Fatalf("fetch failed: %v", err)
 	}
@@ -160,11 +162,11 @@ func TestFetchCRLF(t *testing.T) {
 	chunks := []index.ChunkEntry{
 		{ChunkID: 1, FileID: 1, Path: "crlf.ts", StartLine: 1, EndLine: 10, Snippet: "crlf file"},
 	}
-	if err := index.Serialize(root, files, chunks, map[string][]uint32{}); err != nil {
+	if err := index.Serialize(root, files, chunks, map[string][]index.Posting{}); err != nil {
 		t.Fatalf("serialize failed: %v", err)
 	}
 
-	results, err := Fetch(root, []uint32{1}, 120)
+	results, err := Fetch(root, []uint32{1}, Options{MaxLines: 120})
 	if err != nil {
 		t.Fatalf("fetch failed: %v", err)
 	}
@@ -229,3 +231,367 @@ func TestResolvePathRejectsVariousDotDotForms(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchConfiguredLimits(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(store.Dir(root), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Limits.FetchMaxIDs = 2
+	cfg.Limits.FetchMaxLines = 300
+	if err := config.Save(store.ConfigPath(root), cfg); err != nil {
+		t.Fatalf("config save failed: %v", err)
+	}
+
+	lines := make([]string, 400)
+	for i := range lines {
+		lines[i] = "line"
+	}
+	if err := os.WriteFile(filepath.Join(root, "long.ts"), []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	files := []index.FileEntry{{FileID: 1, Path: "long.ts"}}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "long.ts", StartLine: 1, EndLine: 400},
+		{ChunkID: 2, FileID: 1, Path: "long.ts", StartLine: 1, EndLine: 10},
+		{ChunkID: 3, FileID: 1, Path: "long.ts", StartLine: 11, EndLine: 20},
+	}
+	if err := index.Serialize(root, files, chunks, map[string][]index.Posting{}); err != nil {
+		t.Fatalf("serialize failed: %v", err)
+	}
+
+	results, err := Fetch(root, []uint32{1, 2, 3}, Options{MaxLines: 0})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if len(results) != 1 || !reflect.DeepEqual(results[0].ChunkIDs, []uint32{1, 2}) {
+		t.Fatalf("expected ids trimmed to FetchMaxIDs 2 and merged, got %+v", results)
+	}
+	if len(results[0].Lines) != 300 || results[0].ReturnedTo != 300 {
+		t.Fatalf("expected 300 lines from configured FetchMaxLines, got %d", len(results[0].Lines))
+	}
+	results, err = Fetch(root, []uint32{1}, Options{MaxLines: 1000})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if len(results[0].Lines) != 300 {
+		t.Fatalf("expected max_lines clamped to 300, got %d", len(results[0].Lines))
+	}
+}
+
+func TestFetchContextLines(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(store.Dir(root), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := config.Save(store.ConfigPath(root), config.DefaultConfig()); err != nil {
+		t.Fatalf("config save failed: %v", err)
+	}
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, "line "+strconv.Itoa(i))
+	}
+	if err := os.WriteFile(filepath.Join(root, "file.ts"), []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	files := []index.FileEntry{{FileID: 1, Path: "file.ts"}}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "file.ts", StartLine: 2, EndLine: 4},
+		{ChunkID: 2, FileID: 1, Path: "file.ts", StartLine: 12, EndLine: 14},
+	}
+	if err := index.Serialize(root, files, chunks, map[string][]index.Posting{}); err != nil {
+		t.Fatalf("serialize failed: %v", err)
+	}
+
+	results, err := Fetch(root, []uint32{1, 2}, Options{Before: 3, After: 2})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if results[0].ReturnedFrom != 1 || results[0].ReturnedTo != 6 {
+		t.Fatalf("expected context clamped to 1-6, got %d-%d", results[0].ReturnedFrom, results[0].ReturnedTo)
+	}
+	if results[0].StartLine != 2 || results[0].EndLine != 4 {
+		t.Fatalf("chunk range should be unchanged, got %d-%d", results[0].StartLine, results[0].EndLine)
+	}
+	if results[1].ReturnedFrom != 9 || results[1].ReturnedTo != 16 || results[1].Lines[0] != "9| line 9" {
+		t.Fatalf("unexpected context window %d-%d %q", results[1].ReturnedFrom, results[1].ReturnedTo, results[1].Lines[0])
+	}
+
+	results, err = Fetch(root, []uint32{2}, Options{MaxLines: 4, Before: 3, After: 3})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if results[0].ReturnedFrom != 9 || results[0].ReturnedTo != 12 {
+		t.Fatalf("expected context to count towards max_lines, got %d-%d", results[0].ReturnedFrom, results[0].ReturnedTo)
+	}
+}
+
+func TestFetchMergesOverlappingChunks(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(store.Dir(root), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := config.Save(store.ConfigPath(root), config.DefaultConfig()); err != nil {
+		t.Fatalf("config save failed: %v", err)
+	}
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, "line "+strconv.Itoa(i))
+	}
+	for _, name := range []string{"a.ts", "b.ts"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+			t.Fatalf("write file failed: %v", err)
+		}
+	}
+	files := []index.FileEntry{{FileID: 1, Path: "a.ts"}, {FileID: 2, Path: "b.ts"}}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "a.ts", StartLine: 1, EndLine: 5},
+		{ChunkID: 2, FileID: 1, Path: "a.ts", StartLine: 4, EndLine: 8},
+		{ChunkID: 3, FileID: 1, Path: "a.ts", StartLine: 9, EndLine: 12},
+		{ChunkID: 4, FileID: 1, Path: "a.ts", StartLine: 20, EndLine: 22},
+		{ChunkID: 5, FileID: 2, Path: "b.ts", StartLine: 9, EndLine: 12},
+	}
+	if err := index.Serialize(root, files, chunks, map[string][]index.Posting{}); err != nil {
+		t.Fatalf("serialize failed: %v", err)
+	}
+
+	results, err := Fetch(root, []uint32{3, 5, 1, 4, 2}, Options{})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 blocks, got %+v", results)
+	}
+	merged := results[0]
+	if merged.ChunkID != 3 || !reflect.DeepEqual(merged.ChunkIDs, []uint32{1, 2, 3}) {
+		t.Fatalf("expected chunks 1-3 merged at the first requested id, got %d %v", merged.ChunkID, merged.ChunkIDs)
+	}
+	if merged.StartLine != 1 || merged.EndLine != 12 || merged.ReturnedFrom != 1 || merged.ReturnedTo != 12 || len(merged.Lines) != 12 {
+		t.Fatalf("unexpected merged block %d-%d returned %d-%d", merged.StartLine, merged.EndLine, merged.ReturnedFrom, merged.ReturnedTo)
+	}
+	if results[1].ChunkID != 5 || results[1].ChunkIDs != nil || results[2].ChunkID != 4 || results[2].ChunkIDs != nil {
+		t.Fatalf("other files and distant chunks should stay separate: %+v", results[1:])
+	}
+
+	results, err = Fetch(root, []uint32{4, 4}, Options{})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ChunkIDs != nil || len(results[0].Lines) != 3 {
+		t.Fatalf("a repeated id should collapse to one block, got %+v", results)
+	}
+
+	// Context makes chunk 4 touch the merged block; MaxLines cuts the whole block.
+	results, err = Fetch(root, []uint32{1, 4}, Options{MaxLines: 10, After: 14})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if len(results) != 1 || !reflect.DeepEqual(results[0].ChunkIDs, []uint32{1, 4}) {
+		t.Fatalf("expected context windows to merge, got %+v", results)
+	}
+	if !results[0].Truncated || results[0].Next == nil || results[0].Next.StartLine != 11 || results[0].Next.EndLine != 30 {
+		t.Fatalf("expected merged block truncated with next 11-30, got %+v", results[0].Next)
+	}
+}
+
+func TestFetchRange(t *testing.T) {
+	root := t.TempDir()
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, "line "+strconv.Itoa(i))
+	}
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "src", "a.ts"), []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	files := []index.FileEntry{{FileID: 1, Path: "src/a.ts"}}
+	limits := config.DefaultConfig().Limits
+
+	got, err := FetchRangeWithFiles(root, files, "./src/a.ts", 5, 7, Options{Before: 1, After: 1}, limits)
+	if err != nil {
+		t.Fatalf("fetch range failed: %v", err)
+	}
+	if got.Path != "src/a.ts" || got.StartLine != 5 || got.EndLine != 7 {
+		t.Fatalf("unexpected range %+v", got)
+	}
+	if got.ReturnedFrom != 4 || got.ReturnedTo != 8 || len(got.Lines) != 5 || got.Lines[0] != "4| line 4" {
+		t.Fatalf("unexpected returned lines %d-%d %v", got.ReturnedFrom, got.ReturnedTo, got.Lines)
+	}
+
+	got, err = FetchRangeWithFiles(root, files, "src/a.ts", 29, 0, Options{After: 5}, limits)
+	if err != nil {
+		t.Fatalf("fetch range failed: %v", err)
+	}
+	if got.EndLine != 29 || got.ReturnedTo != 30 {
+		t.Fatalf("expected end_line to default to start_line and clamp to EOF, got %+v", got)
+	}
+
+	cases := []struct {
+		name  string
+		path  string
+		start int
+		end   int
+		want  string
+	}{
+		{"absolute", filepath.Join(root, "src", "a.ts"), 1, 1, "absolute paths are not allowed"},
+		{"traversal", "src/../secret.txt", 1, 1, "path traversal detected"},
+		{"not indexed", "secret.txt", 1, 1, "path not indexed"},
+		{"zero start", "src/a.ts", 0, 1, "start_line must be at least 1"},
+		{"reversed", "src/a.ts", 10, 5, "end_line 5 is before start_line 10"},
+		{"past end", "src/a.ts", 31, 40, "past the end"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := FetchRangeWithFiles(root, files, tc.path, tc.start, tc.end, Options{}, limits)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestFetchEnclosingMode(t *testing.T) {
+	root := t.TempDir()
+	var lines []string
+	for i := 1; i <= 400; i++ {
+		lines = append(lines, "line "+strconv.Itoa(i))
+	}
+	if err := os.WriteFile(filepath.Join(root, "big.ts"), []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	chunkMap := map[uint32]index.ChunkEntry{
+		1: {ChunkID: 1, FileID: 1, Path: "big.ts", StartLine: 150, EndLine: 250},
+		2: {ChunkID: 2, FileID: 1, Path: "big.ts", StartLine: 320, EndLine: 330},
+		3: {ChunkID: 3, FileID: 1, Path: "big.ts", StartLine: 390, EndLine: 400},
+	}
+	symbols := []index.SymbolEntry{
+		{FileID: 1, Path: "big.ts", Name: "Service", Kind: "class", StartLine: 1, EndLine: 380},
+		{FileID: 1, Path: "big.ts", Name: "run", Kind: "method", Parent: "Service", StartLine: 100, EndLine: 300},
+		{FileID: 1, Path: "big.ts", Name: "stop", Kind: "method", Parent: "Service", StartLine: 310, EndLine: 340},
+		{FileID: 1, Path: "big.ts", Name: "LIMIT", Kind: "variable", StartLine: 385, EndLine: 400},
+		{FileID: 2, Path: "other.ts", Name: "wide", Kind: "function", StartLine: 1, EndLine: 500},
+	}
+	limits := config.LimitsConfig{FetchMaxLines: 120}
+
+	results, err := FetchWithChunkMap(root, chunkMap, nil, symbols, []uint32{1, 2, 3}, Options{Mode: ModeEnclosing}, limits)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	big := results[0]
+	if big.Enclosing == nil || big.Enclosing.Name != "run" || big.Enclosing.Parent != "Service" {
+		t.Fatalf("expected innermost enclosing method, got %+v", big.Enclosing)
+	}
+	if big.StartLine != 100 || big.EndLine != 300 || big.ReturnedFrom != 100 || big.ReturnedTo != 219 {
+		t.Fatalf("unexpected widened range %d-%d returned %d-%d", big.StartLine, big.EndLine, big.ReturnedFrom, big.ReturnedTo)
+	}
+	if !big.Truncated || big.Next == nil || big.Next.StartLine != 220 || big.Next.EndLine != 300 {
+		t.Fatalf("expected continuation 220-300, got truncated=%v next=%+v", big.Truncated, big.Next)
+	}
+	small := results[1]
+	if small.Enclosing == nil || small.Enclosing.Name != "stop" || small.ReturnedFrom != 310 || small.ReturnedTo != 340 || small.Truncated || small.Next != nil {
+		t.Fatalf("unexpected small method fetch: %+v", small)
+	}
+	plain := results[2]
+	if plain.Enclosing != nil || plain.StartLine != 390 || plain.ReturnedTo != 400 {
+		t.Fatalf("variables should not widen a chunk: %+v", plain)
+	}
+
+	results, err = FetchWithChunkMap(root, chunkMap, nil, symbols, []uint32{1}, Options{}, limits)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if results[0].Enclosing != nil || results[0].ReturnedFrom != 150 || results[0].ReturnedTo != 250 || results[0].Truncated {
+		t.Fatalf("chunk mode should keep the chunk range: %+v", results[0])
+	}
+
+	if _, err := FetchWithChunkMap(root, chunkMap, nil, symbols, []uint32{1}, Options{Mode: "file"}, limits); err == nil || !strings.Contains(err.Error(), `unknown fetch mode "file"`) {
+		t.Fatalf("expected unknown mode error, got %v", err)
+	}
+}
+
+func TestFetchEnclosingModeLeadingComment(t *testing.T) {
+	root := t.TempDir()
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = "  work();"
+	}
+	lines[0] = "export class Service {"
+	lines[48], lines[49], lines[50] = "", "  /**", "   * Stops the service."
+	lines[51], lines[52] = "   */", "  stop() {"
+	lines[79] = "  }"
+	lines[99] = "}"
+	if err := os.WriteFile(filepath.Join(root, "svc.ts"), []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	chunkMap := map[uint32]index.ChunkEntry{
+		1: {ChunkID: 1, FileID: 1, Path: "svc.ts", StartLine: 49, EndLine: 60},
+	}
+	symbols := []index.SymbolEntry{
+		{FileID: 1, Path: "svc.ts", Name: "Service", Kind: "class", StartLine: 1, EndLine: 100},
+		{FileID: 1, Path: "svc.ts", Name: "stop", Kind: "method", Parent: "Service", StartLine: 53, EndLine: 80},
+	}
+	limits := config.LimitsConfig{FetchMaxLines: 120}
+
+	results, err := FetchWithChunkMap(root, chunkMap, nil, symbols, []uint32{1}, Options{Mode: ModeEnclosing}, limits)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	got := results[0]
+	if got.Enclosing == nil || got.Enclosing.Name != "stop" || got.StartLine != 49 || got.EndLine != 80 {
+		t.Fatalf("expected the commented method with its comment, got %+v at %d-%d", got.Enclosing, got.StartLine, got.EndLine)
+	}
+
+	// Outside a class, only the overlapping declaration can own the comment.
+	symbols = symbols[1:]
+	symbols[0].Kind, symbols[0].Parent = "function", ""
+	results, err = FetchWithChunkMap(root, chunkMap, nil, symbols, []uint32{1}, Options{Mode: ModeEnclosing}, limits)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if got := results[0]; got.Enclosing == nil || got.Enclosing.Name != "stop" || got.StartLine != 49 || got.EndLine != 80 {
+		t.Fatalf("expected the function below the comment, got %+v at %d-%d", got.Enclosing, got.StartLine, got.EndLine)
+	}
+}
+
+func TestFetchFlagsStaleFiles(t *testing.T) {
+	root := t.TempDir()
+	indexed := "export const a = 1;\r\nexport const b = 2;\r\n"
+	if err := os.WriteFile(filepath.Join(root, "a.ts"), []byte(indexed), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	files := []index.FileEntry{{FileID: 1, Path: "a.ts", Hash64: hash.Sum64([]byte("export const a = 1;\nexport const b = 2;\n"))}}
+	chunkMap := map[uint32]index.ChunkEntry{1: {ChunkID: 1, FileID: 1, Path: "a.ts", StartLine: 1, EndLine: 2}}
+	limits := config.DefaultConfig().Limits
+
+	results, err := FetchWithChunkMap(root, chunkMap, files, nil, []uint32{1}, Options{}, limits)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if results[0].Stale {
+		t.Fatalf("CRLF-only differences should not be stale: %+v", results[0])
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "a.ts"), []byte("export const a = 10;\n"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	results, err = FetchWithChunkMap(root, chunkMap, files, nil, []uint32{1}, Options{}, limits)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if !results[0].Stale || results[0].Source != "" || results[0].Lines[0] != "1| export const a = 10;" {
+		t.Fatalf("expected stale working-tree lines without a snapshot: %+v", results[0])
+	}
+	rng, err := FetchRangeWithFiles(root, files, "a.ts", 1, 1, Options{}, limits)
+	if err != nil {
+		t.Fatalf("fetch range failed: %v", err)
+	}
+	if !rng.Stale {
+		t.Fatalf("expected stale range: %+v", rng)
+	}
+}
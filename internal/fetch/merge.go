@@ -0,0 +1,123 @@
+package fetch
+
+import "sort"
+
+// fetched is one chunk result together with the window it was cut from and
+// the file lines behind it, so overlapping results can be re-cut as a block.
+type fetched struct {
+	res   ChunkText
+	w     lineWindow
+	lines []string
+}
+
+// mergeOverlapping joins results from the same file whose requested ranges,
+// context included, overlap or touch. A merged block keeps the position and
+// chunk id of its first member, lists distinct members in ChunkIDs in line order,
+// spans all members' StartLine..EndLine and is re-cut by MaxLines as a whole.
+// Enclosing survives only when every member was widened to the same symbol.
+func mergeOverlapping(items []fetched, maxLines int) []fetched {
+	byPath := make(map[string][]int)
+	for i, it := range items {
+		if len(it.lines) == 0 {
+			continue
+		}
+		byPath[it.res.Path] = append(byPath[it.res.Path], i)
+	}
+	// head[i] is the first requested member of i's block.
+	head := make([]int, len(items))
+	for i := range head {
+		head[i] = i
+	}
+	for _, idx := range byPath {
+		if len(idx) < 2 {
+			continue
+		}
+		sort.SliceStable(idx, func(a, b int) bool { return items[idx[a]].w.from < items[idx[b]].w.from })
+		runStart, runLast := 0, items[idx[0]].w.last
+		for k := 1; k <= len(idx); k++ {
+			if k < len(idx) && items[idx[k]].w.from <= runLast+1 {
+				if items[idx[k]].w.last > runLast {
+					runLast = items[idx[k]].w.last
+				}
+				continue
+			}
+			first := idx[runStart]
+			for _, i := range idx[runStart:k] {
+				if i < first {
+					first = i
+				}
+			}
+			for _, i := range idx[runStart:k] {
+				head[i] = first
+			}
+			if k < len(idx) {
+				runStart, runLast = k, items[idx[k]].w.last
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	for i, h := range head {
+		members[h] = append(members[h], i)
+	}
+	out := make([]fetched, 0, len(items))
+	for i, it := range items {
+		if head[i] != i {
+			continue
+		}
+		if group := members[i]; len(group) > 1 {
+			it = mergeBlock(items, group, maxLines)
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+func mergeBlock(items []fetched, group []int, maxLines int) fetched {
+	sorted := append([]int(nil), group...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		ra, rb := items[sorted[a]].res, items[sorted[b]].res
+		if ra.StartLine != rb.StartLine {
+			return ra.StartLine < rb.StartLine
+		}
+		return ra.ChunkID < rb.ChunkID
+	})
+	first := items[group[0]]
+	res := first.res
+	from, last := first.w.from, first.w.last
+	for _, i := range group {
+		it := items[i]
+		if it.res.StartLine < res.StartLine {
+			res.StartLine = it.res.StartLine
+		}
+		if it.res.EndLine > res.EndLine {
+			res.EndLine = it.res.EndLine
+		}
+		if it.w.from < from {
+			from = it.w.from
+		}
+		if it.w.last > last {
+			last = it.w.last
+		}
+		if res.Enclosing != nil && (it.res.Enclosing == nil || *it.res.Enclosing != *res.Enclosing) {
+			res.Enclosing = nil
+		}
+	}
+	seen := make(map[uint32]struct{}, len(sorted))
+	for _, i := range sorted {
+		id := items[i].res.ChunkID
+		if _, dup := seen[id]; !dup {
+			seen[id] = struct{}{}
+			res.ChunkIDs = append(res.ChunkIDs, id)
+		}
+	}
+	if len(res.ChunkIDs) == 1 {
+		// A chunk requested twice is still a single chunk.
+		res.ChunkIDs = nil
+	}
+
+	w := window(first.lines, from, last, Options{MaxLines: maxLines})
+	res.ReturnedFrom, res.ReturnedTo, res.Lines = uint32(w.from), uint32(w.to), w.lines
+	res.Truncated, res.Next = w.next()
+	return fetched{res: res, w: w, lines: first.lines}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -142,3 +143,122 @@ func DiffNameOnly(root, a, b string) ([]string, error) {
 	}
 	return paths, nil
 }
+
+// runGitStdout is runGit for commands whose stdout is data: stderr is kept out
+// of the result and only reported on failure.
+func runGitStdout(root string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// ValidateRev rejects revisions that git could parse as an option or that
+// would change the meaning of a <rev>:<path> object name.
+func ValidateRev(rev string) error {
+	if rev == "" {
+		return fmt.Errorf("revision is empty")
+	}
+	if strings.HasPrefix(rev, "-") {
+		return fmt.Errorf("revision %q must not start with '-'", rev)
+	}
+	if strings.ContainsAny(rev, ": \t\r\n\x00") {
+		return fmt.Errorf("revision %q contains invalid characters", rev)
+	}
+	return nil
+}
+
+// Show returns the content of a repo-relative, forward-slash path at rev, as
+// printed by `git show <rev>:<path>`.
+func Show(root, rev, path string) ([]byte, error) {
+	if err := ValidateRev(rev); err != nil {
+		return nil, err
+	}
+	return runGitStdout(root, "show", "--no-color", rev+":"+path)
+}
+
+// Hunk is one change from a zero-context unified diff. OldLines or NewLines
+// is 0 for pure insertions or deletions; Lines keep their "+"/"-" prefixes.
+type Hunk struct {
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Lines    []string `json:"lines"`
+}
+
+// DiffHunks returns the changes to path between rev and the working tree.
+func DiffHunks(root, rev, path string) ([]Hunk, error) {
+	if err := ValidateRev(rev); err != nil {
+		return nil, err
+	}
+	out, err := runGitStdout(root, "diff", "-U0", "--no-color", "--no-ext-diff", rev, "--", path)
+	if err != nil {
+		return nil, err
+	}
+	return parseHunks(out)
+}
+
+func parseHunks(out []byte) ([]Hunk, error) {
+	var hunks []Hunk
+	var current *Hunk
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "@@ "):
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			hunks = append(hunks, h)
+			current = &hunks[len(hunks)-1]
+		case strings.HasPrefix(line, "diff --git "):
+			current = nil
+		case current != nil && (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")):
+			current.Lines = append(current.Lines, line)
+		}
+	}
+	return hunks, nil
+}
+
+// parseHunkHeader parses "@@ -a[,b] +c[,d] @@ ...".
+func parseHunkHeader(line string) (Hunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[3] != "@@" {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q", line)
+	}
+	var h Hunk
+	var err error
+	if h.OldStart, h.OldLines, err = parseHunkRange(fields[1], "-"); err != nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q: %w", line, err)
+	}
+	if h.NewStart, h.NewLines, err = parseHunkRange(fields[2], "+"); err != nil {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q: %w", line, err)
+	}
+	return h, nil
+}
+
+func parseHunkRange(field, prefix string) (int, int, error) {
+	if !strings.HasPrefix(field, prefix) {
+		return 0, 0, fmt.Errorf("expected %s range", prefix)
+	}
+	field = field[len(prefix):]
+	count := 1
+	if i := strings.IndexByte(field, ','); i >= 0 {
+		n, err := strconv.Atoi(field[i+1:])
+		if err != nil {
+			return 0, 0, err
+		}
+		count = n
+		field = field[:i]
+	}
+	start, err := strconv.Atoi(field)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, count, nil
+}
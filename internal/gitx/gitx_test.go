@@ -0,0 +1,53 @@
+package gitx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHunks(t *testing.T) {
+	out := "diff --git a/a.ts b/a.ts\n" +
+		"index 1111111..2222222 100644\n" +
+		"--- a/a.ts\n" +
+		"+++ b/a.ts\n" +
+		"@@ -3 +3 @@ export function a() {\n" +
+		"-  return 1;\n" +
+		"+  return 2;\n" +
+		"@@ -10,2 +9,0 @@\n" +
+		"-old line\n" +
+		"-another\n" +
+		"@@ -20,0 +19,3 @@ class B {\n" +
+		"+one\n" +
+		"+two\n" +
+		"+three\n" +
+		"\\ No newline at end of file\n"
+	hunks, err := parseHunks([]byte(out))
+	if err != nil {
+		t.Fatalf("parse hunks: %v", err)
+	}
+	want := []Hunk{
+		{OldStart: 3, OldLines: 1, NewStart: 3, NewLines: 1, Lines: []string{"-  return 1;", "+  return 2;"}},
+		{OldStart: 10, OldLines: 2, NewStart: 9, NewLines: 0, Lines: []string{"-old line", "-another"}},
+		{OldStart: 20, OldLines: 0, NewStart: 19, NewLines: 3, Lines: []string{"+one", "+two", "+three"}},
+	}
+	if !reflect.DeepEqual(hunks, want) {
+		t.Fatalf("unexpected hunks:\n%+v\n%+v", hunks, want)
+	}
+
+	if _, err := parseHunks([]byte("@@ -x +1 @@\n")); err == nil {
+		t.Fatalf("expected malformed header error")
+	}
+}
+
+func TestValidateRev(t *testing.T) {
+	for _, rev := range []string{"HEAD", "HEAD~2", "main", "origin/feature-x", "v1.2.0", "a1b2c3d"} {
+		if err := ValidateRev(rev); err != nil {
+			t.Fatalf("rev %q rejected: %v", rev, err)
+		}
+	}
+	for _, rev := range []string{"", "-p", "--output=/tmp/x", "HEAD:secret.ts", "HEAD other"} {
+		if err := ValidateRev(rev); err == nil {
+			t.Fatalf("rev %q accepted", rev)
+		}
+	}
+}
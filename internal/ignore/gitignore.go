@@ -0,0 +1,314 @@
+package ignore
+
+import (
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Pattern is one compiled gitignore pattern. Patterns read from a nested
+// .gitignore only apply below the directory holding it.
+type Pattern struct {
+	// base is the slash-separated directory the pattern is relative to; empty
+	// for the repository root.
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// ParsePattern compiles a gitignore line relative to base. It reports false
+// for blank lines and comments. The syntax follows gitignore(5): "#" starts a
+// comment, "!" negates, a trailing "/" matches directories only, a "/" at the
+// start or in the middle anchors the pattern to base (otherwise it matches at
+// any depth), "**" spans directories, and "\" escapes the next character,
+// including leading "#"/"!" and trailing spaces.
+func ParsePattern(line, base string) (Pattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpaces(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Pattern{}, false
+	}
+	p := Pattern{base: strings.Trim(base, "/")}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return Pattern{}, false
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return Pattern{}, false
+	}
+
+	var segments []string
+	if !anchored {
+		segments = append(segments, "**")
+	}
+	for _, seg := range strings.Split(line, "/") {
+		if seg == "" {
+			continue
+		}
+		if seg == "**" && len(segments) > 0 && segments[len(segments)-1] == "**" {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	p.segments = segments
+	return p, true
+}
+
+// trimTrailingSpaces drops trailing spaces that are not escaped with "\".
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		backslashes := 0
+		for i := end - 2; i >= 0 && line[i] == '\\'; i-- {
+			backslashes++
+		}
+		if backslashes%2 == 1 {
+			break
+		}
+		end--
+	}
+	return line[:end]
+}
+
+// Match reports whether the slash-separated, root-relative path matches the
+// pattern. Parent directories are not consulted: callers walking a tree skip
+// ignored directories before reaching their contents, as git does.
+func (p Pattern) Match(path string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	rel := path
+	if p.base != "" {
+		if !strings.HasPrefix(path, p.base+"/") {
+			return false
+		}
+		rel = path[len(p.base)+1:]
+	}
+	if rel == "" {
+		return false
+	}
+	return matchSegments(p.segments, strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				// A trailing "/**" matches everything inside, not the directory itself.
+				return len(parts) > 0
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(rest, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 || !wildmatch(pattern[0], parts[0]) {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// wildmatch matches a single path segment against a pattern with "*", "?",
+// bracket expressions ("[a-z]", "[!0-9]", "[^x]", "[[:digit:]]") and "\"
+// escapes. A bracket without a closing "]" matches a literal "[".
+func wildmatch(pattern, name string) bool {
+	px, nx := 0, 0
+	starPx, starNx := -1, -1
+	for px < len(pattern) || nx < len(name) {
+		if px < len(pattern) {
+			switch c := pattern[px]; c {
+			case '*':
+				starPx, starNx = px, nx
+				px++
+				continue
+			case '?':
+				if nx < len(name) {
+					_, w := utf8.DecodeRuneInString(name[nx:])
+					px++
+					nx += w
+					continue
+				}
+			case '[':
+				if nx < len(name) {
+					r, w := utf8.DecodeRuneInString(name[nx:])
+					matched, n, valid := matchClass(pattern[px:], r)
+					if !valid {
+						if name[nx] == '[' {
+							px++
+							nx++
+							continue
+						}
+					} else if matched {
+						px += n
+						nx += w
+						continue
+					}
+				}
+			case '\\':
+				lit := byte('\\')
+				step := 1
+				if px+1 < len(pattern) {
+					lit, step = pattern[px+1], 2
+				}
+				if nx < len(name) && name[nx] == lit {
+					px += step
+					nx++
+					continue
+				}
+			default:
+				if nx < len(name) && name[nx] == c {
+					px++
+					nx++
+					continue
+				}
+			}
+		}
+		// Mismatch: let the last "*" swallow one more rune and retry.
+		if starPx >= 0 && starNx < len(name) {
+			_, w := utf8.DecodeRuneInString(name[starNx:])
+			starNx += w
+			px, nx = starPx+1, starNx
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+var charClasses = map[string]func(rune) bool{
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"alpha":  unicode.IsLetter,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl":  unicode.IsControl,
+	"digit":  unicode.IsDigit,
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"lower":  unicode.IsLower,
+	"print":  unicode.IsPrint,
+	"punct":  unicode.IsPunct,
+	"space":  unicode.IsSpace,
+	"upper":  unicode.IsUpper,
+	"xdigit": func(r rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", r) },
+}
+
+// matchClass matches r against the bracket expression at the start of
+// pattern and returns the expression's length. valid is false when the
+// expression is not terminated.
+func matchClass(pattern string, r rune) (matched bool, n int, valid bool) {
+	i := 1
+	negate := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+	first := true
+	for i < len(pattern) {
+		if pattern[i] == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+		first = false
+		if strings.HasPrefix(pattern[i:], "[:") {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				if fn, ok := charClasses[pattern[i+2:i+2+end]]; ok {
+					if fn(r) {
+						matched = true
+					}
+					i += end + 4
+					continue
+				}
+			}
+		}
+		lo, w := classChar(pattern[i:])
+		if w == 0 {
+			break
+		}
+		i += w
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			var w2 int
+			hi, w2 = classChar(pattern[i+1:])
+			if w2 == 0 {
+				break
+			}
+			i += 1 + w2
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	return false, 0, false
+}
+
+// classChar decodes one possibly escaped rune of a bracket expression.
+func classChar(s string) (rune, int) {
+	if s == "" {
+		return 0, 0
+	}
+	if s[0] == '\\' && len(s) > 1 {
+		r, w := utf8.DecodeRuneInString(s[1:])
+		return r, w + 1
+	}
+	return utf8.DecodeRuneInString(s)
+}
+
+// Matcher applies patterns in the order they were added; the last pattern
+// matching a path decides whether it is ignored.
+type Matcher struct {
+	patterns []Pattern
+}
+
+// NewMatcher compiles root-relative pattern lines.
+func NewMatcher(lines []string) *Matcher {
+	m := &Matcher{}
+	m.Add("", lines)
+	return m
+}
+
+// Add compiles lines relative to base and appends them after the existing
+// patterns, so they take precedence.
+func (m *Matcher) Add(base string, lines []string) {
+	for _, line := range lines {
+		if p, ok := ParsePattern(line, base); ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+}
+
+// Match reports whether path is ignored and whether any pattern matched it.
+func (m *Matcher) Match(path string, isDir bool) (ignored, matched bool) {
+	if m == nil {
+		return false, false
+	}
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		if m.patterns[i].Match(path, isDir) {
+			return !m.patterns[i].negate, true
+		}
+	}
+	return false, false
+}
+
+// ReadPatterns returns the raw lines of a gitignore-style file; ParsePattern
+// skips blanks and comments.
+func ReadPatterns(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	return strings.Split(text, "\n"), nil
+}
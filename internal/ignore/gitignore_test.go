@@ -0,0 +1,85 @@
+package ignore
+
+import "testing"
+
+func TestPatternMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		base    string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"package-lock.json", "", "package-lock.json", false, true},
+		{"package-lock.json", "", "apps/web/package-lock.json", false, true},
+		{"/package-lock.json", "", "apps/web/package-lock.json", false, false},
+		{"/package-lock.json", "", "package-lock.json", false, true},
+		{"build/", "", "packages/a/build", true, true},
+		{"build/", "", "packages/a/build", false, false},
+		{"src/gen", "", "src/gen", true, true},
+		{"src/gen", "", "lib/src/gen", true, false},
+		{"*.generated.ts", "", "src/api/client.generated.ts", false, true},
+		{"doc/*.txt", "", "doc/notes.txt", false, true},
+		{"doc/*.txt", "", "doc/server/arch.txt", false, false},
+		{"**/foo", "", "a/b/foo", false, true},
+		{"**/foo/bar", "", "foo/bar", false, true},
+		{"a/**/b", "", "a/b", false, true},
+		{"a/**/b", "", "a/x/y/b", false, true},
+		{"abc/**", "", "abc/d/e", false, true},
+		{"abc/**", "", "abc", true, false},
+		{"file?.ts", "", "file1.ts", false, true},
+		{"file?.ts", "", "file10.ts", false, false},
+		{"[a-c]*.ts", "", "beta.ts", false, true},
+		{"[!a-c]*.ts", "", "beta.ts", false, false},
+		{"[[:digit:]]x", "", "7x", false, true},
+		{`\#notes`, "", "#notes", false, true},
+		{`\!keep`, "", "!keep", false, true},
+		{`trailing\ `, "", "trailing ", false, true},
+		{"trailing  ", "", "trailing", false, true},
+		{`\*.ts`, "", "a.ts", false, false},
+		{`\*.ts`, "", "*.ts", false, true},
+		{"[unclosed", "", "[unclosed", false, true},
+		{"out", "packages/a", "packages/a/out", true, true},
+		{"out", "packages/a", "packages/b/out", true, false},
+		{"/dist", "packages/a", "packages/a/dist", true, true},
+		{"/dist", "packages/a", "packages/a/src/dist", true, false},
+	}
+	for _, tc := range cases {
+		p, ok := ParsePattern(tc.pattern, tc.base)
+		if !ok {
+			t.Fatalf("pattern %q was not parsed", tc.pattern)
+		}
+		if got := p.Match(tc.path, tc.isDir); got != tc.want {
+			t.Errorf("pattern %q (base %q) on %q dir=%v: got %v, want %v", tc.pattern, tc.base, tc.path, tc.isDir, got, tc.want)
+		}
+	}
+}
+
+func TestParsePatternSkipsBlanksAndComments(t *testing.T) {
+	for _, line := range []string{"", "   ", "# comment", "/", "!"} {
+		if _, ok := ParsePattern(line, ""); ok {
+			t.Errorf("expected %q to be skipped", line)
+		}
+	}
+}
+
+func TestMatcherLastMatchWins(t *testing.T) {
+	m := NewMatcher([]string{"*.log", "!important.log"})
+	m.Add("logs", []string{"important.log"})
+	cases := []struct {
+		path        string
+		wantIgnored bool
+		wantMatched bool
+	}{
+		{"debug.log", true, true},
+		{"important.log", false, true},
+		{"logs/important.log", true, true},
+		{"main.ts", false, false},
+	}
+	for _, tc := range cases {
+		ignored, matched := m.Match(tc.path, false)
+		if ignored != tc.wantIgnored || matched != tc.wantMatched {
+			t.Errorf("%s: got ignored=%v matched=%v", tc.path, ignored, matched)
+		}
+	}
+}
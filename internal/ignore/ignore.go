@@ -31,6 +31,7 @@ func WriteDefaultIgnore(path string) error {
 }
 
 // LoadDirs returns ignore directories from a file, trimming trailing slashes.
+// profile.BuildEffectiveRules turns them into directory scan patterns.
 func LoadDirs(path string) ([]string, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -56,16 +57,6 @@ func LoadDirs(path string) ([]string, error) {
 	return dirs, nil
 }
 
-// IsIgnoredDir reports whether the path should be skipped based on ignore lists.
-func IsIgnoredDir(path string, ignoreDirs []string) bool {
-	for _, dir := range ignoreDirs {
-		if strings.HasPrefix(path, dir+"/") || strings.Contains(path, "/"+dir+"/") || path == dir {
-			return true
-		}
-	}
-	return false
-}
-
 // NormalizePath converts platform-specific separators to forward slashes for matching.
 func NormalizePath(path string) string {
 	return filepath.ToSlash(path)
@@ -9,13 +9,15 @@ import (
 	"github.com/memkit/repodex/internal/lang"
 	"github.com/memkit/repodex/internal/scan"
 	"github.com/memkit/repodex/internal/textutil"
+	"github.com/memkit/repodex/internal/tokenize"
 )
 
 // Build constructs in-memory index structures.
-func Build(files []scan.ScannedFile, plugin lang.LanguagePlugin, cfg config.Config) ([]FileEntry, []ChunkEntry, map[string][]uint32, error) {
+func Build(files []scan.ScannedFile, plugin lang.LanguagePlugin, cfg config.Config) ([]FileEntry, []ChunkEntry, map[string][]Posting, error) {
 	var fileEntries []FileEntry
 	var chunkEntries []ChunkEntry
-	postings := make(map[string][]uint32)
+	postings := make(map[string][]Posting)
+	tokenizer := tokenize.New(cfg.Token)
 
 	sortedFiles := make([]scan.ScannedFile, len(files))
 	copy(sortedFiles, files)
@@ -46,33 +48,30 @@ func Build(files []scan.ScannedFile, plugin lang.LanguagePlugin, cfg config.Conf
 		}
 
 		lines := strings.Split(string(normalizedContent), "\n")
+		pathTokens := tokenizer.Path(path)
 
 		for _, ch := range chunks {
 			chunkText := extractText(lines, int(ch.StartLine), int(ch.EndLine))
-			tokens := plugin.TokenizeChunk(path, chunkText, cfg.Token)
-			unique := make(map[string]struct{})
-			for _, t := range tokens {
-				unique[t] = struct{}{}
-			}
+			terms, freqs, positions, total := TermCounts(pathTokens, tokenizer.Sequence(chunkText))
 			chunkEntry := ChunkEntry{
-				ChunkID:   nextChunkID,
-				FileID:    fileEntry.FileID,
-				Path:      path,
-				StartLine: ch.StartLine,
-				EndLine:   ch.EndLine,
-				Snippet:   ch.Snippet,
+				ChunkID:    nextChunkID,
+				FileID:     fileEntry.FileID,
+				Path:       path,
+				StartLine:  ch.StartLine,
+				EndLine:    ch.EndLine,
+				TokenCount: total,
+				Snippet:    ch.Snippet,
 			}
 			chunkEntries = append(chunkEntries, chunkEntry)
-			for term := range unique {
-				postings[term] = append(postings[term], chunkEntry.ChunkID)
+			for i, term := range terms {
+				postings[term] = append(postings[term], Posting{ChunkID: chunkEntry.ChunkID, TF: freqs[i], Positions: positions[i]})
 			}
 			nextChunkID++
 		}
 	}
 
-	for term, ids := range postings {
-		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
-		postings[term] = dedupUint32(ids)
+	for term, list := range postings {
+		postings[term] = sortPostings(list)
 	}
 
 	return fileEntries, chunkEntries, postings, nil
@@ -90,16 +89,3 @@ func extractText(lines []string, start, end int) string {
 	}
 	return strings.Join(lines[start-1:end], "\n")
 }
-
-func dedupUint32(in []uint32) []uint32 {
-	if len(in) == 0 {
-		return in
-	}
-	out := []uint32{in[0]}
-	for i := 1; i < len(in); i++ {
-		if in[i] != in[i-1] {
-			out = append(out, in[i])
-		}
-	}
-	return out
-}
@@ -0,0 +1,177 @@
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// chunks.dat holds three sections: a table of fixed-width records in
+// ascending chunk id order, the strings they point into, and the uint64 sum
+// of the token counts. A record is nine uint32s:
+//
+//	chunkID fileID startLine endLine tokenCount
+//	pathOffset pathLen snippetOffset snippetLen
+//
+// Each path is stored once however many chunks share it. Fixed-width records
+// let a reader find a chunk by binary search on its id and read token counts
+// without decoding any strings, and the stored sum gives BM25 its average
+// chunk length without a pass over the table.
+const chunkRecordSize = 9 * 4
+
+// chunkTotalsSize is the size of the token count sum section.
+const chunkTotalsSize = 8
+
+// Chunks is read access to chunk metadata, either decoded into memory
+// (ChunkList) or read in place from chunks.dat (ChunkTable).
+type Chunks interface {
+	// Len returns the number of chunks.
+	Len() int
+	// ID returns the id of chunk i.
+	ID(i int) uint32
+	// FileID returns the file id of chunk i.
+	FileID(i int) uint32
+	// At returns chunk i.
+	At(i int) ChunkEntry
+	// TokenCount returns the token count of chunk i.
+	TokenCount(i int) uint32
+	// TotalTokenCount returns the sum of the token counts of all chunks.
+	TotalTokenCount() uint64
+	// Find returns the index of the chunk with the given id.
+	Find(id uint32) (int, bool)
+}
+
+// ChunkList adapts decoded chunk entries to Chunks. It does not copy them;
+// the entries must not change while the list is in use.
+type ChunkList struct {
+	entries []ChunkEntry
+	byID    map[uint32]int
+	total   uint64
+}
+
+// NewChunkList indexes entries by chunk id and sums their token counts.
+func NewChunkList(entries []ChunkEntry) *ChunkList {
+	byID := make(map[uint32]int, len(entries))
+	var total uint64
+	for i, ch := range entries {
+		byID[ch.ChunkID] = i
+		total += uint64(ch.TokenCount)
+	}
+	return &ChunkList{entries: entries, byID: byID, total: total}
+}
+
+func (l *ChunkList) Len() int                { return len(l.entries) }
+func (l *ChunkList) ID(i int) uint32         { return l.entries[i].ChunkID }
+func (l *ChunkList) FileID(i int) uint32     { return l.entries[i].FileID }
+func (l *ChunkList) At(i int) ChunkEntry     { return l.entries[i] }
+func (l *ChunkList) TokenCount(i int) uint32 { return l.entries[i].TokenCount }
+func (l *ChunkList) TotalTokenCount() uint64 { return l.total }
+
+func (l *ChunkList) Find(id uint32) (int, bool) {
+	i, ok := l.byID[id]
+	return i, ok
+}
+
+// ChunkTable reads chunks.dat in place, which may be mapped memory. It is
+// immutable and safe for concurrent use.
+type ChunkTable struct {
+	table   []byte
+	strings []byte
+	n       int
+	total   uint64
+}
+
+// encodeChunkTable encodes chunks, which must be in ascending chunk id order,
+// into the table, strings and totals sections of chunks.dat.
+func encodeChunkTable(chunks []ChunkEntry) ([]byte, []byte, []byte, error) {
+	table := make([]byte, 0, len(chunks)*chunkRecordSize)
+	var strs []byte
+	var total uint64
+	paths := make(map[string]uint32)
+	for i, ch := range chunks {
+		if i > 0 && ch.ChunkID <= chunks[i-1].ChunkID {
+			return nil, nil, nil, fmt.Errorf("chunk id %d follows %d; chunks must be in ascending chunk id order", ch.ChunkID, chunks[i-1].ChunkID)
+		}
+		pathOff, ok := paths[ch.Path]
+		if !ok {
+			pathOff = uint32(len(strs))
+			paths[ch.Path] = pathOff
+			strs = append(strs, ch.Path...)
+		}
+		snippetOff := uint32(len(strs))
+		strs = append(strs, ch.Snippet...)
+		for _, v := range []uint32{
+			ch.ChunkID, ch.FileID, ch.StartLine, ch.EndLine, ch.TokenCount,
+			pathOff, uint32(len(ch.Path)), snippetOff, uint32(len(ch.Snippet)),
+		} {
+			table = binary.LittleEndian.AppendUint32(table, v)
+		}
+		total += uint64(ch.TokenCount)
+	}
+	return table, strs, binary.LittleEndian.AppendUint64(nil, total), nil
+}
+
+// newChunkTable wraps the sections of chunks.dat read from path, checking
+// only their sizes so that opening costs nothing per chunk. Strings outside
+// the strings section read as empty; Verify reports them along with the id
+// order and the token count sum.
+func newChunkTable(path string, table, strs, totals []byte) (*ChunkTable, error) {
+	if len(table)%chunkRecordSize != 0 {
+		return nil, formatErrorf(path, "chunk table size %d is not a multiple of %d", len(table), chunkRecordSize)
+	}
+	if len(totals) != chunkTotalsSize {
+		return nil, formatErrorf(path, "totals section is %d bytes, expected %d", len(totals), chunkTotalsSize)
+	}
+	return &ChunkTable{table: table, strings: strs, n: len(table) / chunkRecordSize, total: binary.LittleEndian.Uint64(totals)}, nil
+}
+
+// loadChunkTable reads chunks.dat, checksum included, into a ChunkTable.
+func loadChunkTable(path string) (*ChunkTable, error) {
+	sections, err := readArtifact(path, kindChunks, 3)
+	if err != nil {
+		return nil, err
+	}
+	return newChunkTable(path, sections[0], sections[1], sections[2])
+}
+
+// field returns uint32 number f of record i.
+func (t *ChunkTable) field(i, f int) uint32 {
+	return binary.LittleEndian.Uint32(t.table[i*chunkRecordSize+4*f:])
+}
+
+// stringInBounds reports whether the string at fields f and f+1 of record i
+// lies inside the strings section.
+func (t *ChunkTable) stringInBounds(i, f int) bool {
+	return uint64(t.field(i, f))+uint64(t.field(i, f+1)) <= uint64(len(t.strings))
+}
+
+func (t *ChunkTable) string(i, f int) string {
+	if !t.stringInBounds(i, f) {
+		return ""
+	}
+	off := t.field(i, f)
+	return string(t.strings[off : off+t.field(i, f+1)])
+}
+
+func (t *ChunkTable) Len() int                { return t.n }
+func (t *ChunkTable) ID(i int) uint32         { return t.field(i, 0) }
+func (t *ChunkTable) FileID(i int) uint32     { return t.field(i, 1) }
+func (t *ChunkTable) TokenCount(i int) uint32 { return t.field(i, 4) }
+func (t *ChunkTable) TotalTokenCount() uint64 { return t.total }
+
+func (t *ChunkTable) At(i int) ChunkEntry {
+	return ChunkEntry{
+		ChunkID:    t.field(i, 0),
+		FileID:     t.field(i, 1),
+		Path:       t.string(i, 5),
+		StartLine:  t.field(i, 2),
+		EndLine:    t.field(i, 3),
+		TokenCount: t.field(i, 4),
+		Snippet:    t.string(i, 7),
+	}
+}
+
+func (t *ChunkTable) Find(id uint32) (int, bool) {
+	i := sort.Search(t.n, func(i int) bool { return t.field(i, 0) >= id })
+	return i, i < t.n && t.field(i, 0) == id
+}
@@ -0,0 +1,351 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/memkit/repodex/internal/mmapx"
+)
+
+// Every artifact is wrapped in the same container:
+//
+//	magic    [4]byte  "RPDX"
+//	kind     [4]byte  artifact kind, e.g. "CHNK"
+//	version  uint16   format version of that kind
+//	reserved uint16   zero
+//	sections uint32   number of sections
+//	lengths  [sections]uint64
+//	section data, back to back
+//	crc      uint32   CRC-32C of everything above
+//	magic    [4]byte  "RPDX" again, marking a complete write
+//
+// All integers are little-endian. The header lets a reader reject a foreign
+// or outdated file with a precise error, the lengths detect truncation
+// without reading the data, and the footer catches corruption.
+const (
+	artifactMagic      = "RPDX"
+	artifactHeaderSize = 16
+	artifactFooterSize = 8
+	// maxSections bounds the section table read from an untrusted header.
+	maxSections = 16
+)
+
+// Artifact kinds.
+const (
+	kindFiles     = "FILE"
+	kindChunks    = "CHNK"
+	kindTerms     = "TERM"
+	kindPostings  = "POST"
+	kindPositions = "POSN"
+	kindSymbols   = "SYMB"
+	kindImports   = "IMPT"
+	kindSnapshots = "SNAP"
+)
+
+// artifactVersions is the format version written, and the only one read,
+// for each kind. Bump a kind's version whenever its sections change.
+var artifactVersions = map[string]uint16{
+	kindFiles:     1,
+	kindChunks:    3,
+	kindTerms:     3,
+	kindPostings:  2,
+	kindPositions: 2,
+	kindSymbols:   1,
+	kindImports:   1,
+	kindSnapshots: 1,
+}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FormatError describes an artifact that is not a valid repodex artifact of
+// the expected kind and version, or whose contents are damaged.
+type FormatError struct {
+	Path   string
+	Reason string
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("%s: %s", filepath.Base(e.Path), e.Reason)
+}
+
+func formatErrorf(path, format string, args ...interface{}) error {
+	return &FormatError{Path: path, Reason: fmt.Sprintf(format, args...)}
+}
+
+// ArtifactInfo is the header of an artifact as found on disk.
+type ArtifactInfo struct {
+	Kind     string   `json:"kind"`
+	Version  uint16   `json:"version"`
+	Size     int64    `json:"size"`
+	Sections []uint64 `json:"sections"`
+	CRC      uint32   `json:"crc"`
+}
+
+// writeArtifact writes sections in the container format.
+func writeArtifact(path, kind string, sections ...[]byte) error {
+	header := make([]byte, artifactHeaderSize+8*len(sections))
+	copy(header[0:4], artifactMagic)
+	copy(header[4:8], kind)
+	binary.LittleEndian.PutUint16(header[8:10], artifactVersions[kind])
+	binary.LittleEndian.PutUint32(header[12:16], uint32(len(sections)))
+	for i, s := range sections {
+		binary.LittleEndian.PutUint64(header[artifactHeaderSize+8*i:], uint64(len(s)))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	crc := crc32.New(crcTable)
+	w := io.MultiWriter(f, crc)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	for _, s := range sections {
+		if _, err := w.Write(s); err != nil {
+			return err
+		}
+	}
+	footer := make([]byte, artifactFooterSize)
+	binary.LittleEndian.PutUint32(footer[0:4], crc.Sum32())
+	copy(footer[4:8], artifactMagic)
+	if _, err := f.Write(footer); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readArtifact reads a whole artifact, checks its header, length and
+// checksum, and returns its sections, which must number want.
+func readArtifact(path, kind string, want int) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, offsets, err := parseLayout(bytes.NewReader(data), int64(len(data)), path, kind, want)
+	if err != nil {
+		return nil, err
+	}
+	body := len(data) - artifactFooterSize
+	if sum := crc32.Checksum(data[:body], crcTable); sum != info.CRC {
+		return nil, formatErrorf(path, "checksum mismatch (stored %08x, computed %08x)", info.CRC, sum)
+	}
+	sections := make([][]byte, len(offsets))
+	for i, off := range offsets {
+		sections[i] = data[off : off+int64(info.Sections[i])]
+	}
+	return sections, nil
+}
+
+// openArtifact checks the header and length of an artifact opened for random
+// access and returns readers for its sections. The checksum is not verified,
+// since that would mean reading the whole file; VerifyArtifact does.
+func openArtifact(f *os.File, path, kind string, want int) ([]*io.SectionReader, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	info, offsets, err := parseLayout(f, fi.Size(), path, kind, want)
+	if err != nil {
+		return nil, err
+	}
+	sections := make([]*io.SectionReader, len(offsets))
+	for i, off := range offsets {
+		sections[i] = io.NewSectionReader(f, off, int64(info.Sections[i]))
+	}
+	return sections, nil
+}
+
+// mapArtifact maps an artifact into memory, checks its header and length,
+// and returns its sections as slices of the mapping, valid until the mapping
+// is closed. Like openArtifact it leaves the checksum to VerifyArtifact, so
+// opening does not touch every page of the file.
+func mapArtifact(path, kind string, want int) (*mmapx.File, [][]byte, error) {
+	f, err := mmapx.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := f.Bytes()
+	info, offsets, err := parseLayout(bytes.NewReader(data), int64(len(data)), path, kind, want)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	sections := make([][]byte, len(offsets))
+	for i, off := range offsets {
+		sections[i] = data[off : off+int64(info.Sections[i])]
+	}
+	return f, sections, nil
+}
+
+// parseLayout validates the header and footer of an artifact of size bytes
+// and returns its info and the file offset of each section. kind may be
+// empty to accept any known kind, and want negative to accept any count.
+func parseLayout(r io.ReaderAt, size int64, path, kind string, want int) (ArtifactInfo, []int64, error) {
+	var header [artifactHeaderSize]byte
+	if size < artifactHeaderSize+artifactFooterSize {
+		return ArtifactInfo{}, nil, formatErrorf(path, "truncated: %d bytes is shorter than the header and footer", size)
+	}
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return ArtifactInfo{}, nil, err
+	}
+	if string(header[0:4]) != artifactMagic {
+		return ArtifactInfo{}, nil, formatErrorf(path, "not a repodex index artifact (bad magic); run sync to rebuild the index")
+	}
+	info := ArtifactInfo{
+		Kind:    string(header[4:8]),
+		Version: binary.LittleEndian.Uint16(header[8:10]),
+		Size:    size,
+	}
+	wantVersion, known := artifactVersions[info.Kind]
+	if !known {
+		return ArtifactInfo{}, nil, formatErrorf(path, "unknown artifact kind %q", info.Kind)
+	}
+	if kind != "" && info.Kind != kind {
+		return ArtifactInfo{}, nil, formatErrorf(path, "artifact kind %q, expected %q", info.Kind, kind)
+	}
+	if info.Version != wantVersion {
+		return ArtifactInfo{}, nil, formatErrorf(path, "format version %d, this build reads %d; run sync to rebuild the index", info.Version, wantVersion)
+	}
+	count := binary.LittleEndian.Uint32(header[12:16])
+	if count > maxSections {
+		return ArtifactInfo{}, nil, formatErrorf(path, "%d sections, at most %d are supported", count, maxSections)
+	}
+	if want >= 0 && int(count) != want {
+		return ArtifactInfo{}, nil, formatErrorf(path, "%d sections, expected %d", count, want)
+	}
+	table := make([]byte, 8*count)
+	if int64(len(table)) > size-artifactHeaderSize-artifactFooterSize {
+		return ArtifactInfo{}, nil, formatErrorf(path, "truncated section table")
+	}
+	if _, err := r.ReadAt(table, artifactHeaderSize); err != nil {
+		return ArtifactInfo{}, nil, err
+	}
+	offsets := make([]int64, count)
+	expected := uint64(artifactHeaderSize) + uint64(len(table))
+	for i := range offsets {
+		n := binary.LittleEndian.Uint64(table[8*i:])
+		offsets[i] = int64(expected)
+		info.Sections = append(info.Sections, n)
+		if n > uint64(size) {
+			expected = uint64(size) + 1
+			break
+		}
+		expected += n
+	}
+	expected += artifactFooterSize
+	if expected != uint64(size) {
+		if expected > uint64(size) {
+			return ArtifactInfo{}, nil, formatErrorf(path, "truncated: %d bytes, header describes at least %d", size, expected)
+		}
+		return ArtifactInfo{}, nil, formatErrorf(path, "%d bytes of trailing data", uint64(size)-expected)
+	}
+	var footer [artifactFooterSize]byte
+	if _, err := r.ReadAt(footer[:], size-artifactFooterSize); err != nil {
+		return ArtifactInfo{}, nil, err
+	}
+	if string(footer[4:8]) != artifactMagic {
+		return ArtifactInfo{}, nil, formatErrorf(path, "missing footer; the file was not completely written")
+	}
+	info.CRC = binary.LittleEndian.Uint32(footer[0:4])
+	return info, offsets, nil
+}
+
+// VerifyArtifact checks the header, length and checksum of the artifact at
+// path, whatever its kind.
+func VerifyArtifact(path string) (ArtifactInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ArtifactInfo{}, err
+	}
+	info, _, err := parseLayout(bytes.NewReader(data), int64(len(data)), path, "", -1)
+	if err != nil {
+		return ArtifactInfo{}, err
+	}
+	if sum := crc32.Checksum(data[:len(data)-artifactFooterSize], crcTable); sum != info.CRC {
+		return info, formatErrorf(path, "checksum mismatch (stored %08x, computed %08x)", info.CRC, sum)
+	}
+	return info, nil
+}
+
+// sectionDecoder reads the fields of one section, turning a short read into
+// a FormatError naming the artifact.
+type sectionDecoder struct {
+	path string
+	r    *bytes.Reader
+	err  error
+}
+
+func newSectionDecoder(path string, section []byte) *sectionDecoder {
+	return &sectionDecoder{path: path, r: bytes.NewReader(section)}
+}
+
+func (d *sectionDecoder) read(v interface{}) {
+	if d.err != nil {
+		return
+	}
+	if err := binary.Read(d.r, binary.LittleEndian, v); err != nil {
+		d.fail(err)
+	}
+}
+
+func (d *sectionDecoder) u32() uint32 {
+	var v uint32
+	d.read(&v)
+	return v
+}
+
+func (d *sectionDecoder) string() string {
+	if d.err != nil {
+		return ""
+	}
+	n := d.u32()
+	if d.err != nil {
+		return ""
+	}
+	if int64(n) > int64(d.r.Len()) {
+		d.fail(io.ErrUnexpectedEOF)
+		return ""
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		d.fail(err)
+	}
+	return string(buf)
+}
+
+// count reads a record count, rejecting counts that cannot fit in the rest
+// of the section given the minimum record size.
+func (d *sectionDecoder) count(minRecord int) uint32 {
+	n := d.u32()
+	if d.err == nil && uint64(n)*uint64(minRecord) > uint64(d.r.Len()) {
+		d.err = formatErrorf(d.path, "record count %d exceeds the section size", n)
+		return 0
+	}
+	return n
+}
+
+func (d *sectionDecoder) fail(err error) {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		d.err = formatErrorf(d.path, "section ends in the middle of a record")
+		return
+	}
+	d.err = err
+}
+
+// finish reports a decoding error or unread bytes left in the section.
+func (d *sectionDecoder) finish() error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.r.Len() != 0 {
+		return formatErrorf(d.path, "%d unread bytes at the end of the section", d.r.Len())
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package index
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestChunks(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chunks.dat")
+	chunks := []ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "a.ts", StartLine: 1, EndLine: 3, Snippet: "one"},
+		{ChunkID: 2, FileID: 1, Path: "a.ts", StartLine: 4, EndLine: 6, Snippet: "two"},
+	}
+	if err := writeChunks(path, chunks); err != nil {
+		t.Fatalf("write chunks: %v", err)
+	}
+	return path
+}
+
+func expectFormatError(t *testing.T, err error, want string) {
+	t.Helper()
+	var fe *FormatError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected a FormatError containing %q, got %v", want, err)
+	}
+	if !strings.Contains(err.Error(), "chunks.dat: ") || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected error naming chunks.dat and %q, got %q", want, err)
+	}
+}
+
+func TestArtifactHeader(t *testing.T) {
+	path := writeTestChunks(t)
+	info, err := VerifyArtifact(path)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if info.Kind != kindChunks || info.Version != artifactVersions[kindChunks] || len(info.Sections) != 3 {
+		t.Fatalf("unexpected header %+v", info)
+	}
+	if fi, _ := os.Stat(path); info.Size != fi.Size() {
+		t.Fatalf("size %d, file has %d bytes", info.Size, fi.Size())
+	}
+}
+
+func TestArtifactDamageIsReported(t *testing.T) {
+	path := writeTestChunks(t)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, data[:len(data)-5], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadChunkEntries(path)
+	expectFormatError(t, err, "truncated")
+
+	flipped := append([]byte(nil), data...)
+	flipped[len(data)-artifactFooterSize-1] ^= 0xff
+	if err := os.WriteFile(path, flipped, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadChunkEntries(path)
+	expectFormatError(t, err, "checksum mismatch")
+
+	newer := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint16(newer[8:10], artifactVersions[kindChunks]+1)
+	if err := os.WriteFile(path, newer, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadChunkEntries(path)
+	expectFormatError(t, err, "format version")
+
+	// The headerless format written before artifacts had a container.
+	legacy := binary.LittleEndian.AppendUint32(nil, 0)
+	if err := os.WriteFile(path, append(legacy, make([]byte, 32)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadChunkEntries(path)
+	expectFormatError(t, err, "bad magic")
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadTerms(path); err == nil || !strings.Contains(err.Error(), `expected "TERM"`) {
+		t.Fatalf("expected a kind mismatch, got %v", err)
+	}
+}
@@ -1,56 +1,30 @@
 package index
 
-import (
-	"encoding/binary"
-	"io"
-	"os"
-)
+// fileRecordMin is the smallest encoded file entry: id, empty path, mtime,
+// size and hash.
+const fileRecordMin = 4 + 4 + 8 + 8 + 8
 
 // LoadFileEntries reads minimal file data from files.dat.
 func LoadFileEntries(path string) ([]FileEntry, error) {
-	f, err := os.Open(path)
+	sections, err := readArtifact(path, kindFiles, 1)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	d := newSectionDecoder(path, sections[0])
+	count := d.count(fileRecordMin)
 
-	var count uint32
-	if err := binary.Read(f, binary.LittleEndian, &count); err != nil {
-		return nil, err
-	}
 	entries := make([]FileEntry, 0, count)
-	for i := uint32(0); i < count; i++ {
+	for i := uint32(0); i < count && d.err == nil; i++ {
 		var fe FileEntry
-		if err := binary.Read(f, binary.LittleEndian, &fe.FileID); err != nil {
-			return nil, err
-		}
-		pathStr, err := readString(f)
-		if err != nil {
-			return nil, err
-		}
-		fe.Path = pathStr
-		if err := binary.Read(f, binary.LittleEndian, &fe.MTime); err != nil {
-			return nil, err
-		}
-		if err := binary.Read(f, binary.LittleEndian, &fe.Size); err != nil {
-			return nil, err
-		}
-		if err := binary.Read(f, binary.LittleEndian, &fe.Hash64); err != nil {
-			return nil, err
-		}
+		d.read(&fe.FileID)
+		fe.Path = d.string()
+		d.read(&fe.MTime)
+		d.read(&fe.Size)
+		d.read(&fe.Hash64)
 		entries = append(entries, fe)
 	}
-	return entries, nil
-}
-
-func readString(r io.Reader) (string, error) {
-	var length uint32
-	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
-		return "", err
-	}
-	buf := make([]byte, length)
-	if _, err := io.ReadFull(r, buf); err != nil {
-		return "", err
+	if err := d.finish(); err != nil {
+		return nil, err
 	}
-	return string(buf), nil
+	return entries, nil
 }
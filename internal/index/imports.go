@@ -0,0 +1,68 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// ImportEntry is a file-level dependency edge recorded in imports.dat.
+type ImportEntry struct {
+	FileID    uint32
+	Path      string
+	Line      uint32
+	Kind      string
+	Specifier string
+	// Resolved is the indexed file the specifier resolves to; empty when the
+	// specifier is an external package or could not be resolved.
+	Resolved string
+}
+
+// importRecordMin is the smallest encoded import edge: file id, line and
+// four empty strings.
+const importRecordMin = 4 + 4 + 4*4
+
+// WriteImports stores import edges as a count followed by fixed fields and length-prefixed strings.
+func WriteImports(path string, imports []ImportEntry) error {
+	w := &bytes.Buffer{}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(imports))); err != nil {
+		return err
+	}
+	for _, imp := range imports {
+		if err := binary.Write(w, binary.LittleEndian, imp.FileID); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, imp.Line); err != nil {
+			return err
+		}
+		for _, str := range []string{imp.Path, imp.Kind, imp.Specifier, imp.Resolved} {
+			if err := writeString(w, str); err != nil {
+				return err
+			}
+		}
+	}
+	return writeArtifact(path, kindImports, w.Bytes())
+}
+
+// LoadImports reads imports.dat.
+func LoadImports(path string) ([]ImportEntry, error) {
+	sections, err := readArtifact(path, kindImports, 1)
+	if err != nil {
+		return nil, err
+	}
+	d := newSectionDecoder(path, sections[0])
+	count := d.count(importRecordMin)
+	imports := make([]ImportEntry, 0, count)
+	for i := uint32(0); i < count && d.err == nil; i++ {
+		var imp ImportEntry
+		d.read(&imp.FileID)
+		d.read(&imp.Line)
+		for _, dst := range []*string{&imp.Path, &imp.Kind, &imp.Specifier, &imp.Resolved} {
+			*dst = d.string()
+		}
+		imports = append(imports, imp)
+	}
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	return imports, nil
+}
@@ -0,0 +1,328 @@
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Each term's postings are stored as blocks of up to postingBlockSize
+// entries. The list starts in postings.dat at TermInfo.Offset with a skip
+// table, one entry per block:
+//
+//	last     uvarint  last chunk id of the block, minus that of the previous block
+//	size     uvarint  encoded size of the block in postings.dat
+//	posSize  uvarint  encoded size of the block's positions in positions.dat
+//
+// followed by the blocks. A block holds, per posting, the chunk id as a
+// uvarint delta from the previous one (the previous block's last id for the
+// first posting of a block) and the tf as a uvarint. The positions of the
+// block start in positions.dat at TermInfo.PosOffset plus the sizes of the
+// earlier blocks and hold, per posting, a count and then each position as a
+// uvarint delta from the previous one.
+//
+// Chunk ids strictly ascend within a list, as do positions within a posting,
+// so deltas stay small and an unchanged list encodes to the same bytes
+// between syncs. The skip table lets a reader jump to the block holding a
+// chunk id without decoding the blocks before it, and positions are only
+// decoded for the lists a query reads.
+const postingBlockSize = 128
+
+// skipEntryMin is the smallest encoded skip table entry.
+const skipEntryMin = 3
+
+// Postings gives access to the encoded postings and positions of an index.
+// Lists are decoded on demand, one term at a time; a Postings is immutable and
+// safe for concurrent use.
+type Postings struct {
+	path          string
+	positionsPath string
+	data          []byte
+	positions     []byte
+}
+
+// LoadPostings reads postings.dat and positions.dat without decoding them.
+func LoadPostings(path, positionsPath string) (*Postings, error) {
+	sections, err := readArtifact(path, kindPostings, 1)
+	if err != nil {
+		return nil, err
+	}
+	posSections, err := readArtifact(positionsPath, kindPositions, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &Postings{path: path, positionsPath: positionsPath, data: sections[0], positions: posSections[0]}, nil
+}
+
+// appendPostingList encodes one term's list, which must be in strictly
+// ascending chunk id order with strictly ascending positions per posting,
+// onto the postings and positions sections.
+func appendPostingList(data, positions []byte, list []Posting) ([]byte, []byte, error) {
+	var skips, blocks []byte
+	var prev uint32
+	for start := 0; start < len(list); start += postingBlockSize {
+		end := start + postingBlockSize
+		if end > len(list) {
+			end = len(list)
+		}
+		base := prev
+		blockStart, posStart := len(blocks), len(positions)
+		for i, p := range list[start:end] {
+			if start+i > 0 && p.ChunkID <= prev {
+				return nil, nil, fmt.Errorf("chunk id %d follows %d; postings must be in ascending chunk id order", p.ChunkID, prev)
+			}
+			blocks = binary.AppendUvarint(blocks, uint64(p.ChunkID-prev))
+			blocks = binary.AppendUvarint(blocks, uint64(p.TF))
+			prev = p.ChunkID
+
+			positions = binary.AppendUvarint(positions, uint64(len(p.Positions)))
+			var last uint32
+			for j, pos := range p.Positions {
+				if j > 0 && pos <= last {
+					return nil, nil, fmt.Errorf("position %d follows %d in chunk %d; positions must be in ascending order", pos, last, p.ChunkID)
+				}
+				positions = binary.AppendUvarint(positions, uint64(pos-last))
+				last = pos
+			}
+		}
+		skips = binary.AppendUvarint(skips, uint64(prev-base))
+		skips = binary.AppendUvarint(skips, uint64(len(blocks)-blockStart))
+		skips = binary.AppendUvarint(skips, uint64(len(positions)-posStart))
+	}
+	data = append(data, skips...)
+	return append(data, blocks...), positions, nil
+}
+
+// postingBlock locates one block of a list.
+type postingBlock struct {
+	// base is the last chunk id of the previous block, zero for the first.
+	base    uint32
+	last    uint32
+	count   int
+	off     int
+	size    int
+	posOff  int
+	posSize int
+}
+
+// uvarintReader decodes consecutive uvarints from buf, recording the first
+// failure.
+type uvarintReader struct {
+	buf []byte
+	off int
+	bad bool
+}
+
+func (r *uvarintReader) next() uint64 {
+	if r.bad {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf[r.off:])
+	if n <= 0 {
+		r.bad = true
+		return 0
+	}
+	r.off += n
+	return v
+}
+
+// next32 is next for values that must fit in a uint32.
+func (r *uvarintReader) next32() uint32 {
+	v := r.next()
+	if v > math.MaxUint32 {
+		r.bad = true
+		return 0
+	}
+	return uint32(v)
+}
+
+// blocks decodes the skip table of the list described by info.
+func (p *Postings) blocks(info TermInfo) ([]postingBlock, error) {
+	if info.Offset > uint64(len(p.data)) {
+		return nil, formatErrorf(p.path, "postings offset %d lies outside the section (%d bytes)", info.Offset, len(p.data))
+	}
+	if info.PosOffset > uint64(len(p.positions)) {
+		return nil, formatErrorf(p.positionsPath, "positions offset %d lies outside the section (%d bytes)", info.PosOffset, len(p.positions))
+	}
+	n := (uint64(info.DF) + postingBlockSize - 1) / postingBlockSize
+	if n*skipEntryMin > uint64(len(p.data))-info.Offset {
+		return nil, formatErrorf(p.path, "skip table of %d blocks at offset %d runs past the section", n, info.Offset)
+	}
+	blocks := make([]postingBlock, n)
+	r := uvarintReader{buf: p.data, off: int(info.Offset)}
+	var last uint64
+	for i := range blocks {
+		b := &blocks[i]
+		b.base = uint32(last)
+		last += r.next()
+		b.size = int(r.next32())
+		b.posSize = int(r.next32())
+		if r.bad || last > math.MaxUint32 {
+			return nil, formatErrorf(p.path, "damaged skip table at offset %d", info.Offset)
+		}
+		b.last = uint32(last)
+		b.count = postingBlockSize
+		if i == len(blocks)-1 {
+			b.count = int(info.DF) - i*postingBlockSize
+		}
+	}
+	off, posOff := uint64(r.off), info.PosOffset
+	for i := range blocks {
+		b := &blocks[i]
+		b.off, b.posOff = int(off), int(posOff)
+		off += uint64(b.size)
+		posOff += uint64(b.posSize)
+	}
+	if off > uint64(len(p.data)) {
+		return nil, formatErrorf(p.path, "postings at offset %d run past the section", info.Offset)
+	}
+	if posOff > uint64(len(p.positions)) {
+		return nil, formatErrorf(p.positionsPath, "positions at offset %d run past the section", info.PosOffset)
+	}
+	return blocks, nil
+}
+
+// decodeBlock appends the postings of b to dst, with their positions when
+// withPositions is set.
+func (p *Postings) decodeBlock(b postingBlock, first bool, withPositions bool, dst []Posting) ([]Posting, error) {
+	r := uvarintReader{buf: p.data[:b.off+b.size], off: b.off}
+	pr := uvarintReader{buf: p.positions[:b.posOff+b.posSize], off: b.posOff}
+	id := uint64(b.base)
+	for i := 0; i < b.count; i++ {
+		delta := r.next()
+		if delta == 0 && !(first && i == 0) {
+			return nil, formatErrorf(p.path, "chunk ids of the block at offset %d do not ascend", b.off)
+		}
+		id += delta
+		post := Posting{ChunkID: uint32(id), TF: r.next32()}
+		if withPositions {
+			if count := pr.next(); count > 0 {
+				if count > uint64(b.posSize) {
+					return nil, formatErrorf(p.positionsPath, "position count %d exceeds the block at offset %d", count, b.posOff)
+				}
+				post.Positions = make([]uint32, count)
+				var last uint32
+				for j := range post.Positions {
+					last += pr.next32()
+					post.Positions[j] = last
+				}
+			}
+		}
+		if r.bad || pr.bad || id > math.MaxUint32 {
+			return nil, formatErrorf(p.path, "damaged block at offset %d", b.off)
+		}
+		dst = append(dst, post)
+	}
+	if id != uint64(b.last) || r.off != len(r.buf) {
+		return nil, formatErrorf(p.path, "block at offset %d does not match its skip entry", b.off)
+	}
+	if withPositions && pr.off != len(pr.buf) {
+		return nil, formatErrorf(p.positionsPath, "positions at offset %d do not match their skip entry", b.posOff)
+	}
+	return dst, nil
+}
+
+// List decodes the postings, with positions, of the term described by info.
+func (p *Postings) List(info TermInfo) ([]Posting, error) {
+	blocks, err := p.blocks(info)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Posting, 0, info.DF)
+	for i, b := range blocks {
+		if list, err = p.decodeBlock(b, i == 0, true, list); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// extent returns the offsets just past the list described by info in
+// postings.dat and positions.dat.
+func (p *Postings) extent(info TermInfo) (uint64, uint64, error) {
+	blocks, err := p.blocks(info)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(blocks) == 0 {
+		return info.Offset, info.PosOffset, nil
+	}
+	last := blocks[len(blocks)-1]
+	return uint64(last.off + last.size), uint64(last.posOff + last.posSize), nil
+}
+
+// PostingIterator walks one term's postings in chunk id order, decoding a
+// block at a time and without positions.
+type PostingIterator struct {
+	p      *Postings
+	blocks []postingBlock
+	block  int
+	buf    []Posting
+	i      int
+	err    error
+}
+
+// Iterator returns an iterator over the postings of the term described by
+// info, positioned before the first posting.
+func (p *Postings) Iterator(info TermInfo) (*PostingIterator, error) {
+	blocks, err := p.blocks(info)
+	if err != nil {
+		return nil, err
+	}
+	return &PostingIterator{p: p, blocks: blocks, block: -1}, nil
+}
+
+// load decodes block b and positions the iterator on its first posting.
+func (it *PostingIterator) load(b int) bool {
+	it.block, it.buf, it.i = b, it.buf[:0], 0
+	if b >= len(it.blocks) {
+		return false
+	}
+	it.buf, it.err = it.p.decodeBlock(it.blocks[b], b == 0, false, it.buf)
+	return it.err == nil
+}
+
+// Next advances to the next posting and reports whether there is one.
+func (it *PostingIterator) Next() bool {
+	if it.err != nil || it.block >= len(it.blocks) {
+		return false
+	}
+	if it.i+1 < len(it.buf) {
+		it.i++
+		return true
+	}
+	return it.load(it.block + 1)
+}
+
+// SkipTo advances to the first posting whose chunk id is at least id and
+// reports whether there is one. Blocks ending before id are skipped without
+// being decoded. The iterator never moves backwards.
+func (it *PostingIterator) SkipTo(id uint32) bool {
+	if it.err != nil || it.block >= len(it.blocks) {
+		return false
+	}
+	b := it.block
+	if b < 0 {
+		b = 0
+	}
+	for b < len(it.blocks) && it.blocks[b].last < id {
+		b++
+	}
+	if b != it.block && !it.load(b) {
+		return false
+	}
+	rest := it.buf[it.i:]
+	it.i += sort.Search(len(rest), func(k int) bool { return rest[k].ChunkID >= id })
+	return true
+}
+
+// Posting returns the current posting; Positions is always nil.
+func (it *PostingIterator) Posting() Posting {
+	return it.buf[it.i]
+}
+
+// Err returns the error that stopped the iterator, if any.
+func (it *PostingIterator) Err() error {
+	return it.err
+}
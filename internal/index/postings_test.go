@@ -0,0 +1,108 @@
+package index
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/memkit/repodex/internal/store"
+)
+
+// writeLongList serializes a term spanning several blocks, with chunk ids
+// 3, 6, 9, ... and positions on every posting.
+func writeLongList(t *testing.T, n int) (*Postings, TermInfo, []Posting) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(store.Dir(root), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	var list []Posting
+	for i := 1; i <= n; i++ {
+		list = append(list, Posting{ChunkID: uint32(3 * i), TF: uint32(i%4 + 1), Positions: []uint32{uint32(i), uint32(i + 7)}})
+	}
+	postings := map[string][]Posting{"long": list, "short": {{ChunkID: 6, TF: 1, Positions: []uint32{2}}}}
+	if err := Serialize(root, nil, nil, postings); err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+	terms, _, err := LoadTerms(store.TermsPath(root))
+	if err != nil {
+		t.Fatalf("load terms: %v", err)
+	}
+	p, err := LoadPostings(store.PostingsPath(root), store.PositionsPath(root))
+	if err != nil {
+		t.Fatalf("load postings: %v", err)
+	}
+	return p, terms["long"], list
+}
+
+func TestPostingsRoundTripAcrossBlocks(t *testing.T) {
+	p, info, want := writeLongList(t, 3*postingBlockSize+5)
+	got, err := p.List(info)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("postings differ after %d entries", len(got))
+	}
+	if size := len(p.data); size >= len(want)*8 {
+		t.Fatalf("expected compressed postings, got %d bytes for %d entries", size, len(want))
+	}
+}
+
+func TestPostingIteratorSkipTo(t *testing.T) {
+	p, info, want := writeLongList(t, 3*postingBlockSize+5)
+	it, err := p.Iterator(info)
+	if err != nil {
+		t.Fatalf("Iterator: %v", err)
+	}
+	if !it.Next() || it.Posting().ChunkID != 3 {
+		t.Fatalf("expected first posting 3, got %+v", it.Posting())
+	}
+	// 1000 is not a multiple of 3; the next id lies in the third block.
+	if !it.SkipTo(1000) || it.Posting().ChunkID != 1002 || it.block != 2 {
+		t.Fatalf("expected 1002 in block 2, got %+v in block %d", it.Posting(), it.block)
+	}
+	if !it.SkipTo(1002) || it.Posting().ChunkID != 1002 {
+		t.Fatalf("SkipTo the current id moved to %+v", it.Posting())
+	}
+	if !it.Next() || it.Posting().ChunkID != 1005 || it.Posting().TF != want[334].TF {
+		t.Fatalf("expected 1005 after 1002, got %+v", it.Posting())
+	}
+	last := want[len(want)-1].ChunkID
+	if !it.SkipTo(last) || it.Posting().ChunkID != last {
+		t.Fatalf("expected last posting %d, got %+v", last, it.Posting())
+	}
+	if it.Next() || it.SkipTo(last+1) {
+		t.Fatalf("expected iterator to be exhausted")
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+}
+
+func TestSerializeRejectsUnsortedPostings(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(store.Dir(root), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	postings := map[string][]Posting{"alpha": {{ChunkID: 2, TF: 1}, {ChunkID: 1, TF: 1}}}
+	err := Serialize(root, nil, nil, postings)
+	if err == nil || !strings.Contains(err.Error(), "ascending") {
+		t.Fatalf("expected an ordering error, got %v", err)
+	}
+}
+
+func TestSerializeRejectsUnsortedPositions(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(store.Dir(root), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, positions := range [][]uint32{{3, 1}, {2, 2}} {
+		postings := map[string][]Posting{"alpha": {{ChunkID: 1, TF: 2, Positions: positions}}}
+		err := Serialize(root, nil, nil, postings)
+		if err == nil || !strings.Contains(err.Error(), "positions must be in ascending order") {
+			t.Fatalf("expected an ordering error for positions %v, got %v", positions, err)
+		}
+	}
+}
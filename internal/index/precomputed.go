@@ -1,8 +1,11 @@
 package index
 
 import (
+	"fmt"
 	"path/filepath"
 	"sort"
+
+	"github.com/memkit/repodex/internal/lang"
 )
 
 // PrecomputedFile holds chunk/token data ready for index assembly.
@@ -12,18 +15,29 @@ type PrecomputedFile struct {
 	Size   int64
 	Hash64 uint64
 	Chunks []PrecomputedChunk
+	// Symbols are the declarations reported by the language plugin.
+	Symbols []lang.Symbol
+	// Imports are the module specifiers referenced by the file, unresolved.
+	Imports []lang.Import
+	// Snapshot is the compressed indexed content when snapshots are enabled.
+	Snapshot []byte
 }
 
 // PrecomputedChunk describes a chunk with its tokens.
+// TermFreqs and Positions are parallel to Tokens; when TermFreqs is absent every
+// token counts once, and when Positions is absent no positions are recorded.
 type PrecomputedChunk struct {
-	StartLine uint32
-	EndLine   uint32
-	Snippet   string
-	Tokens    []string
+	StartLine  uint32
+	EndLine    uint32
+	Snippet    string
+	Tokens     []string
+	TermFreqs  []uint32
+	Positions  [][]uint32
+	TokenCount uint32
 }
 
 // BuildFromPrecomputed assembles index structures from precomputed chunks/tokens.
-func BuildFromPrecomputed(files []PrecomputedFile) ([]FileEntry, []ChunkEntry, map[string][]uint32, error) {
+func BuildFromPrecomputed(files []PrecomputedFile) ([]FileEntry, []ChunkEntry, map[string][]Posting, error) {
 	sortedFiles := make([]PrecomputedFile, len(files))
 	copy(sortedFiles, files)
 	sort.Slice(sortedFiles, func(i, j int) bool {
@@ -33,7 +47,7 @@ func BuildFromPrecomputed(files []PrecomputedFile) ([]FileEntry, []ChunkEntry, m
 	var (
 		fileEntries  []FileEntry
 		chunkEntries []ChunkEntry
-		postings            = make(map[string][]uint32)
+		postings            = make(map[string][]Posting)
 		nextFileID   uint32 = 1
 		nextChunkID  uint32 = 1
 	)
@@ -51,26 +65,122 @@ func BuildFromPrecomputed(files []PrecomputedFile) ([]FileEntry, []ChunkEntry, m
 		fileEntries = append(fileEntries, fileEntry)
 
 		for _, ch := range f.Chunks {
+			if len(ch.TermFreqs) != 0 && len(ch.TermFreqs) != len(ch.Tokens) {
+				return nil, nil, nil, fmt.Errorf("chunk %s:%d-%d: term frequency count mismatch", path, ch.StartLine, ch.EndLine)
+			}
+			if len(ch.Positions) != 0 && len(ch.Positions) != len(ch.Tokens) {
+				return nil, nil, nil, fmt.Errorf("chunk %s:%d-%d: term position count mismatch", path, ch.StartLine, ch.EndLine)
+			}
 			chunkEntry := ChunkEntry{
-				ChunkID:   nextChunkID,
-				FileID:    fileEntry.FileID,
-				Path:      path,
-				StartLine: ch.StartLine,
-				EndLine:   ch.EndLine,
-				Snippet:   ch.Snippet,
+				ChunkID:    nextChunkID,
+				FileID:     fileEntry.FileID,
+				Path:       path,
+				StartLine:  ch.StartLine,
+				EndLine:    ch.EndLine,
+				TokenCount: ch.TokenCount,
+				Snippet:    ch.Snippet,
 			}
-			chunkEntries = append(chunkEntries, chunkEntry)
-			for _, term := range ch.Tokens {
-				postings[term] = append(postings[term], chunkEntry.ChunkID)
+			var total uint32
+			for i, term := range ch.Tokens {
+				tf := uint32(1)
+				if len(ch.TermFreqs) != 0 && ch.TermFreqs[i] > 0 {
+					tf = ch.TermFreqs[i]
+				}
+				total += tf
+				posting := Posting{ChunkID: chunkEntry.ChunkID, TF: tf}
+				if len(ch.Positions) != 0 {
+					posting.Positions = ch.Positions[i]
+				}
+				postings[term] = append(postings[term], posting)
+			}
+			if chunkEntry.TokenCount == 0 {
+				chunkEntry.TokenCount = total
 			}
+			chunkEntries = append(chunkEntries, chunkEntry)
 			nextChunkID++
 		}
 	}
 
-	for term, ids := range postings {
-		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
-		postings[term] = dedupUint32(ids)
+	for term, list := range postings {
+		postings[term] = sortPostings(list)
 	}
 
 	return fileEntries, chunkEntries, postings, nil
 }
+
+// TermCounts folds path tokens and the ordered text tokens of a chunk into a
+// sorted unique term list with parallel term frequencies and text positions,
+// plus the total token count. Positions are ordinals into textTokens; path
+// tokens count towards frequency but have no position.
+func TermCounts(pathTokens []string, textTokens []string) ([]string, []uint32, [][]uint32, uint32) {
+	counts := make(map[string]uint32, len(pathTokens)+len(textTokens))
+	positions := make(map[string][]uint32, len(textTokens))
+	for _, tok := range pathTokens {
+		counts[tok]++
+	}
+	for pos, tok := range textTokens {
+		counts[tok]++
+		positions[tok] = append(positions[tok], uint32(pos))
+	}
+	terms := make([]string, 0, len(counts))
+	for tok := range counts {
+		terms = append(terms, tok)
+	}
+	sort.Strings(terms)
+	freqs := make([]uint32, len(terms))
+	termPositions := make([][]uint32, len(terms))
+	var total uint32
+	for i, tok := range terms {
+		freqs[i] = counts[tok]
+		termPositions[i] = positions[tok]
+		total += counts[tok]
+	}
+	return terms, freqs, termPositions, total
+}
+
+// sortPostings orders postings by chunk id and merges duplicates by summing
+// tf. Positions end up strictly ascending, as the postings writer requires:
+// those of merged duplicates, or supplied out of order, are sorted and
+// deduplicated.
+func sortPostings(in []Posting) []Posting {
+	if len(in) == 0 {
+		return in
+	}
+	sort.Slice(in, func(i, j int) bool { return in[i].ChunkID < in[j].ChunkID })
+	out := []Posting{in[0]}
+	for i := 1; i < len(in); i++ {
+		last := &out[len(out)-1]
+		if in[i].ChunkID == last.ChunkID {
+			last.TF += in[i].TF
+			// Cap the slice so the merge never writes into another posting's positions.
+			last.Positions = append(last.Positions[:len(last.Positions):len(last.Positions)], in[i].Positions...)
+			continue
+		}
+		out = append(out, in[i])
+	}
+	for i := range out {
+		out[i].Positions = ascendingPositions(out[i].Positions)
+	}
+	return out
+}
+
+// ascendingPositions returns positions sorted and without repeats, copying
+// them only when they are not already.
+func ascendingPositions(positions []uint32) []uint32 {
+	ascending := true
+	for i := 1; i < len(positions) && ascending; i++ {
+		ascending = positions[i] > positions[i-1]
+	}
+	if ascending {
+		return positions
+	}
+	sorted := append([]uint32(nil), positions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	out := sorted[:1]
+	for _, pos := range sorted[1:] {
+		if pos != out[len(out)-1] {
+			out = append(out, pos)
+		}
+	}
+	return out
+}
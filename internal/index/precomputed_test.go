@@ -1,6 +1,9 @@
 package index
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestBuildFromPrecomputedUsesProvidedTokens(t *testing.T) {
 	files := []PrecomputedFile{
@@ -28,15 +31,76 @@ func TestBuildFromPrecomputedUsesProvidedTokens(t *testing.T) {
 	}
 
 	alpha := postings["alpha"]
-	if len(alpha) != 1 || alpha[0] != chunksOut[0].ChunkID {
+	if len(alpha) != 1 || alpha[0].ChunkID != chunksOut[0].ChunkID {
 		t.Fatalf("expected alpha posting to include first chunk id, got %v", alpha)
 	}
 	beta := postings["beta"]
-	if len(beta) != 2 || beta[0] != chunksOut[0].ChunkID || beta[1] != chunksOut[1].ChunkID {
+	if len(beta) != 2 || beta[0].ChunkID != chunksOut[0].ChunkID || beta[1].ChunkID != chunksOut[1].ChunkID {
 		t.Fatalf("expected beta to include both chunk ids, got %v", beta)
 	}
 	gamma := postings["gamma"]
-	if len(gamma) != 1 || gamma[0] != chunksOut[1].ChunkID {
+	if len(gamma) != 1 || gamma[0].ChunkID != chunksOut[1].ChunkID {
 		t.Fatalf("expected gamma posting to include second chunk id, got %v", gamma)
 	}
 }
+
+func TestBuildFromPrecomputedCarriesTermFrequencies(t *testing.T) {
+	files := []PrecomputedFile{
+		{
+			Path: "a.ts",
+			Chunks: []PrecomputedChunk{
+				{StartLine: 1, EndLine: 5, Tokens: []string{"alpha", "beta"}, TermFreqs: []uint32{3, 1}, TokenCount: 4},
+			},
+		},
+	}
+
+	_, chunksOut, postings, err := BuildFromPrecomputed(files)
+	if err != nil {
+		t.Fatalf("BuildFromPrecomputed returned error: %v", err)
+	}
+	if chunksOut[0].TokenCount != 4 {
+		t.Fatalf("expected token count 4, got %d", chunksOut[0].TokenCount)
+	}
+	if got := postings["alpha"]; len(got) != 1 || got[0].TF != 3 {
+		t.Fatalf("expected alpha tf 3, got %v", got)
+	}
+	if got := postings["beta"]; len(got) != 1 || got[0].TF != 1 {
+		t.Fatalf("expected beta tf 1, got %v", got)
+	}
+}
+
+func TestTermCountsCountsPathAndTextOccurrences(t *testing.T) {
+	terms, freqs, positions, total := TermCounts([]string{"user"}, []string{"user", "repo", "user"})
+	if len(terms) != 2 || terms[0] != "repo" || terms[1] != "user" {
+		t.Fatalf("unexpected terms %v", terms)
+	}
+	if freqs[0] != 1 || freqs[1] != 3 {
+		t.Fatalf("unexpected freqs %v", freqs)
+	}
+	if len(positions[1]) != 2 || positions[1][0] != 0 || positions[1][1] != 2 {
+		t.Fatalf("unexpected user positions %v", positions[1])
+	}
+	if total != 4 {
+		t.Fatalf("expected total 4, got %d", total)
+	}
+}
+
+func TestBuildFromPrecomputedMergesPositionsInOrder(t *testing.T) {
+	files := []PrecomputedFile{
+		{
+			Path: "a.ts",
+			Chunks: []PrecomputedChunk{
+				{StartLine: 1, EndLine: 5, Tokens: []string{"alpha", "alpha"}, TermFreqs: []uint32{2, 2}, Positions: [][]uint32{{4, 1}, {1, 2}}},
+			},
+		},
+	}
+
+	_, _, postings, err := BuildFromPrecomputed(files)
+	if err != nil {
+		t.Fatalf("BuildFromPrecomputed returned error: %v", err)
+	}
+	got := postings["alpha"]
+	if len(got) != 1 || got[0].TF != 4 || !reflect.DeepEqual(got[0].Positions, []uint32{1, 2, 4}) {
+		t.Fatalf("expected one posting with tf 4 and positions [1 2 4], got %v", got)
+	}
+}
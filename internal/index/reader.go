@@ -0,0 +1,87 @@
+package index
+
+import (
+	"github.com/memkit/repodex/internal/mmapx"
+	"github.com/memkit/repodex/internal/store"
+)
+
+// Reader answers queries from the artifacts of one generation mapped into
+// memory. Nothing is decoded up front: terms are found by binary search in
+// the term table, chunk records are read in place, and a postings list is
+// decoded when a query reads it, so opening costs little more than mapping
+// the files. Checksums are left to Verify. A Reader is safe for concurrent
+// use until Close; the chunk entries, terms and postings read through it are
+// copies and stay valid after Close.
+type Reader struct {
+	terms    *TermDict
+	chunks   *ChunkTable
+	postings *Postings
+	maps     []*mmapx.File
+}
+
+// OpenReader maps the terms, chunks, postings and positions artifacts of gen.
+// The caller should hold a lease on gen for as long as the Reader is open.
+func OpenReader(gen store.Generation) (*Reader, error) {
+	r := &Reader{}
+	ok := false
+	defer func() {
+		if !ok {
+			r.Close()
+		}
+	}()
+	open := func(path, kind string, want int) ([][]byte, error) {
+		f, sections, err := mapArtifact(path, kind, want)
+		if err != nil {
+			return nil, err
+		}
+		r.maps = append(r.maps, f)
+		return sections, nil
+	}
+
+	sections, err := open(gen.TermsPath(), kindTerms, 2)
+	if err != nil {
+		return nil, err
+	}
+	if r.terms, err = newTermDict(gen.TermsPath(), sections[0], sections[1]); err != nil {
+		return nil, err
+	}
+	if sections, err = open(gen.ChunksPath(), kindChunks, 3); err != nil {
+		return nil, err
+	}
+	if r.chunks, err = newChunkTable(gen.ChunksPath(), sections[0], sections[1], sections[2]); err != nil {
+		return nil, err
+	}
+	postings, err := open(gen.PostingsPath(), kindPostings, 1)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := open(gen.PositionsPath(), kindPositions, 1)
+	if err != nil {
+		return nil, err
+	}
+	r.postings = &Postings{path: gen.PostingsPath(), positionsPath: gen.PositionsPath(), data: postings[0], positions: positions[0]}
+	ok = true
+	return r, nil
+}
+
+// Terms returns the term dictionary.
+func (r *Reader) Terms() *TermDict { return r.terms }
+
+// Chunks returns the chunk table.
+func (r *Reader) Chunks() *ChunkTable { return r.chunks }
+
+// Postings returns the postings and positions.
+func (r *Reader) Postings() *Postings { return r.postings }
+
+// Close unmaps the artifacts. The dictionary, table and postings must not be
+// used afterwards.
+func (r *Reader) Close() error {
+	var first error
+	for _, f := range r.maps {
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	r.maps = nil
+	return first
+}
@@ -0,0 +1,74 @@
+package index
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/memkit/repodex/internal/store"
+)
+
+func TestReaderQueriesInPlace(t *testing.T) {
+	gen := store.Generation{Dir: t.TempDir()}
+	chunks := []ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "a.ts", StartLine: 1, EndLine: 3, TokenCount: 4, Snippet: "alpha beta"},
+		{ChunkID: 2, FileID: 1, Path: "a.ts", StartLine: 4, EndLine: 6, TokenCount: 2, Snippet: "alpha"},
+		{ChunkID: 5, FileID: 2, Path: "b.ts", StartLine: 1, EndLine: 1, TokenCount: 1, Snippet: ""},
+	}
+	postings := map[string][]Posting{
+		"alpha": {{ChunkID: 1, TF: 1, Positions: []uint32{0}}, {ChunkID: 2, TF: 1, Positions: []uint32{0}}},
+		"beta":  {{ChunkID: 1, TF: 1, Positions: []uint32{1}}},
+	}
+	if err := SerializeGeneration(gen, []FileEntry{{FileID: 1, Path: "a.ts"}, {FileID: 2, Path: "b.ts"}}, chunks, postings); err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+
+	r, err := OpenReader(gen)
+	if err != nil {
+		t.Fatalf("OpenReader: %v", err)
+	}
+	table := r.Chunks()
+	if table.Len() != len(chunks) {
+		t.Fatalf("expected %d chunks, got %d", len(chunks), table.Len())
+	}
+	for i, want := range chunks {
+		if got := table.At(i); !reflect.DeepEqual(got, want) {
+			t.Fatalf("chunk %d differs:\n%+v\n%+v", i, got, want)
+		}
+	}
+	if i, ok := table.Find(5); !ok || i != 2 || table.TokenCount(i) != 1 {
+		t.Fatalf("expected chunk 5 at 2, got %d %v", i, ok)
+	}
+	if _, ok := table.Find(3); ok {
+		t.Fatalf("expected chunk 3 to be missing")
+	}
+
+	info, ok := r.Terms().Lookup("alpha")
+	if !ok || info.DF != 2 {
+		t.Fatalf("unexpected lookup %+v %v", info, ok)
+	}
+	if got := r.Terms().Prefix("b"); !reflect.DeepEqual(got, []string{"beta"}) {
+		t.Fatalf("unexpected prefix expansion %v", got)
+	}
+	list, err := r.Postings().List(info)
+	if err != nil || !reflect.DeepEqual(list, postings["alpha"]) {
+		t.Fatalf("unexpected postings %+v, %v", list, err)
+	}
+
+	entry := table.At(0)
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if entry.Path != "a.ts" || entry.Snippet != "alpha beta" {
+		t.Fatalf("entry read before Close changed to %+v", entry)
+	}
+}
+
+func TestSerializeRejectsUnsortedChunks(t *testing.T) {
+	gen := store.Generation{Dir: t.TempDir()}
+	chunks := []ChunkEntry{{ChunkID: 2, FileID: 1, Path: "a.ts"}, {ChunkID: 1, FileID: 1, Path: "a.ts"}}
+	err := SerializeGeneration(gen, []FileEntry{{FileID: 1, Path: "a.ts"}}, chunks, nil)
+	if err == nil || !strings.Contains(err.Error(), "ascending") {
+		t.Fatalf("expected an ordering error, got %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 package index
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"os"
 	"sort"
@@ -9,31 +11,30 @@ import (
 	"github.com/memkit/repodex/internal/store"
 )
 
-// Serialize writes index data to disk.
-func Serialize(root string, files []FileEntry, chunks []ChunkEntry, postings map[string][]uint32) error {
-	if err := os.MkdirAll(store.Dir(root), 0o755); err != nil {
-		return err
-	}
+// Serialize writes index data in place into the current generation. Sync
+// writes a fresh generation with SerializeGeneration instead.
+func Serialize(root string, files []FileEntry, chunks []ChunkEntry, postings map[string][]Posting) error {
+	return SerializeGeneration(store.Current(root), files, chunks, postings)
+}
 
-	if err := writeFiles(store.FilesPath(root), files); err != nil {
+// SerializeGeneration writes the files, chunks, terms, postings and positions
+// artifacts into gen.
+func SerializeGeneration(gen store.Generation, files []FileEntry, chunks []ChunkEntry, postings map[string][]Posting) error {
+	if err := os.MkdirAll(gen.Dir, 0o755); err != nil {
 		return err
 	}
-	if err := writeChunks(store.ChunksPath(root), chunks); err != nil {
+
+	if err := writeFiles(gen.FilesPath(), files); err != nil {
 		return err
 	}
-	if err := writeTermsAndPostings(store.TermsPath(root), store.PostingsPath(root), postings); err != nil {
+	if err := writeChunks(gen.ChunksPath(), chunks); err != nil {
 		return err
 	}
-	return nil
+	return writeTermsAndPostings(gen.TermsPath(), gen.PostingsPath(), gen.PositionsPath(), postings)
 }
 
 func writeFiles(path string, files []FileEntry) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
+	f := &bytes.Buffer{}
 	if err := binary.Write(f, binary.LittleEndian, uint32(len(files))); err != nil {
 		return err
 	}
@@ -54,54 +55,21 @@ func writeFiles(path string, files []FileEntry) error {
 			return err
 		}
 	}
-	return nil
+	return writeArtifact(path, kindFiles, f.Bytes())
 }
 
 func writeChunks(path string, chunks []ChunkEntry) error {
-	f, err := os.Create(path)
+	table, strs, totals, err := encodeChunkTable(chunks)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
-	if err := binary.Write(f, binary.LittleEndian, uint32(len(chunks))); err != nil {
-		return err
-	}
-	for _, ch := range chunks {
-		if err := binary.Write(f, binary.LittleEndian, ch.ChunkID); err != nil {
-			return err
-		}
-		if err := binary.Write(f, binary.LittleEndian, ch.FileID); err != nil {
-			return err
-		}
-		if err := writeString(f, ch.Path); err != nil {
-			return err
-		}
-		if err := binary.Write(f, binary.LittleEndian, ch.StartLine); err != nil {
-			return err
-		}
-		if err := binary.Write(f, binary.LittleEndian, ch.EndLine); err != nil {
-			return err
-		}
-		if err := writeString(f, ch.Snippet); err != nil {
-			return err
-		}
-	}
-	return nil
+	return writeArtifact(path, kindChunks, table, strs, totals)
 }
 
-func writeTermsAndPostings(termsPath, postingsPath string, postings map[string][]uint32) error {
-	postingsFile, err := os.Create(postingsPath)
-	if err != nil {
-		return err
-	}
-	defer postingsFile.Close()
-
-	termsFile, err := os.Create(termsPath)
-	if err != nil {
-		return err
-	}
-	defer termsFile.Close()
+// writeTermsAndPostings writes terms.dat in term order together with the
+// encoded lists they point into in postings.dat and positions.dat.
+func writeTermsAndPostings(termsPath, postingsPath, positionsPath string, postings map[string][]Posting) error {
+	var data, positions []byte
 
 	terms := make([]string, 0, len(postings))
 	for term := range postings {
@@ -109,30 +77,23 @@ func writeTermsAndPostings(termsPath, postingsPath string, postings map[string][
 	}
 	sort.Strings(terms)
 
-	if err := binary.Write(termsFile, binary.LittleEndian, uint32(len(terms))); err != nil {
+	infos := make([]TermInfo, len(terms))
+	for i, term := range terms {
+		list := postings[term]
+		infos[i] = TermInfo{Offset: uint64(len(data)), PosOffset: uint64(len(positions)), DF: uint32(len(list))}
+		var err error
+		if data, positions, err = appendPostingList(data, positions, list); err != nil {
+			return fmt.Errorf("term %q: %w", term, err)
+		}
+	}
+	table, names := encodeTermTable(terms, infos)
+	if err := writeArtifact(termsPath, kindTerms, table, names); err != nil {
 		return err
 	}
-
-	var offset uint64
-	for _, term := range terms {
-		ids := postings[term]
-		if err := writeString(termsFile, term); err != nil {
-			return err
-		}
-		if err := binary.Write(termsFile, binary.LittleEndian, offset); err != nil {
-			return err
-		}
-		if err := binary.Write(termsFile, binary.LittleEndian, uint32(len(ids))); err != nil {
-			return err
-		}
-		for _, id := range ids {
-			if err := binary.Write(postingsFile, binary.LittleEndian, id); err != nil {
-				return err
-			}
-			offset += 4
-		}
+	if err := writeArtifact(postingsPath, kindPostings, data); err != nil {
+		return err
 	}
-	return nil
+	return writeArtifact(positionsPath, kindPositions, positions)
 }
 
 func writeString(w io.Writer, s string) error {
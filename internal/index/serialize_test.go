@@ -0,0 +1,118 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/memkit/repodex/internal/lang"
+	"github.com/memkit/repodex/internal/store"
+)
+
+func TestSerializeRoundTripsPostingsAndPositions(t *testing.T) {
+	root := t.TempDir()
+	files := []FileEntry{{FileID: 1, Path: "a.ts"}}
+	chunks := []ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "a.ts", StartLine: 1, EndLine: 3, TokenCount: 5, Snippet: "one"},
+		{ChunkID: 2, FileID: 1, Path: "a.ts", StartLine: 4, EndLine: 6, TokenCount: 2, Snippet: "two"},
+	}
+	postings := map[string][]Posting{
+		"alpha": {{ChunkID: 1, TF: 2, Positions: []uint32{0, 3}}, {ChunkID: 2, TF: 1}},
+		"beta":  {{ChunkID: 1, TF: 1, Positions: []uint32{1}}},
+	}
+	if err := os.MkdirAll(store.Dir(root), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := Serialize(root, files, chunks, postings); err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+
+	gotChunks, err := LoadChunkEntries(store.ChunksPath(root))
+	if err != nil {
+		t.Fatalf("load chunks: %v", err)
+	}
+	if !reflect.DeepEqual(gotChunks, chunks) {
+		t.Fatalf("chunks differ:\n%v\n%v", gotChunks, chunks)
+	}
+	terms, _, err := LoadTerms(store.TermsPath(root))
+	if err != nil {
+		t.Fatalf("load terms: %v", err)
+	}
+	all, err := LoadPostings(store.PostingsPath(root), store.PositionsPath(root))
+	if err != nil {
+		t.Fatalf("load postings: %v", err)
+	}
+	for term, want := range postings {
+		got, err := all.List(terms[term])
+		if err != nil {
+			t.Fatalf("term %s: %v", term, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("term %s postings differ:\n%v\n%v", term, got, want)
+		}
+	}
+}
+
+func TestSymbolsRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	files := []FileEntry{{FileID: 1, Path: "a.ts"}, {FileID: 2, Path: "b.ts"}}
+	byPath := map[string][]lang.Symbol{
+		"b.ts": {{Name: "helper", Kind: lang.KindFunction, StartLine: 1, EndLine: 3}},
+		"a.ts": {
+			{Name: "Repo", Kind: lang.KindClass, Exported: true, StartLine: 2, EndLine: 9},
+			{Name: "find", Kind: lang.KindMethod, Parent: "Repo", Exported: true, StartLine: 3, EndLine: 5},
+		},
+		"missing.ts": {{Name: "gone", Kind: lang.KindFunction, StartLine: 1, EndLine: 1}},
+	}
+	symbols := BuildSymbols(files, byPath)
+	if len(symbols) != 3 || symbols[0].Name != "Repo" || symbols[2].FileID != 2 {
+		t.Fatalf("unexpected symbols %+v", symbols)
+	}
+	path := filepath.Join(root, "symbols.dat")
+	if err := WriteSymbols(path, symbols); err != nil {
+		t.Fatalf("write symbols: %v", err)
+	}
+	got, err := LoadSymbols(path)
+	if err != nil {
+		t.Fatalf("load symbols: %v", err)
+	}
+	if !reflect.DeepEqual(got, symbols) {
+		t.Fatalf("symbols differ:\n%v\n%v", got, symbols)
+	}
+}
+
+func TestSnapshotsRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "snapshots.dat")
+	contents := map[uint32]string{3: "export const c = 3;\n", 1: "", 7: strings.Repeat("line\n", 500)}
+	var entries []SnapshotEntry
+	for id, content := range contents {
+		data, err := CompressSnapshot([]byte(content))
+		if err != nil {
+			t.Fatalf("compress: %v", err)
+		}
+		entries = append(entries, SnapshotEntry{FileID: id, Data: data})
+	}
+	if err := WriteSnapshots(path, entries); err != nil {
+		t.Fatalf("write snapshots: %v", err)
+	}
+	for id, want := range contents {
+		got, ok, err := ReadSnapshot(path, id)
+		if err != nil || !ok {
+			t.Fatalf("read snapshot %d: ok=%v err=%v", id, ok, err)
+		}
+		if string(got) != want {
+			t.Fatalf("snapshot %d differs: %q", id, got)
+		}
+	}
+	for _, id := range []uint32{0, 2, 8} {
+		if _, ok, err := ReadSnapshot(path, id); ok || err != nil {
+			t.Fatalf("expected no snapshot for %d, got ok=%v err=%v", id, ok, err)
+		}
+	}
+	if _, ok, err := ReadSnapshot(filepath.Join(root, "missing.dat"), 1); ok || err != nil {
+		t.Fatalf("missing snapshots.dat should report no snapshot, got ok=%v err=%v", ok, err)
+	}
+}
@@ -0,0 +1,159 @@
+package index
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SnapshotEntry is the flate-compressed, newline-normalized content of a file
+// as it was indexed.
+type SnapshotEntry struct {
+	FileID uint32
+	Data   []byte
+}
+
+// SnapshotsFromPrecomputed collects the snapshots carried by precomputed files.
+func SnapshotsFromPrecomputed(files []FileEntry, precomputed []PrecomputedFile) []SnapshotEntry {
+	byPath := make(map[string][]byte, len(precomputed))
+	for _, f := range precomputed {
+		if len(f.Snapshot) > 0 {
+			byPath[filepath.ToSlash(f.Path)] = f.Snapshot
+		}
+	}
+	var out []SnapshotEntry
+	for _, fe := range files {
+		if data, ok := byPath[fe.Path]; ok {
+			out = append(out, SnapshotEntry{FileID: fe.FileID, Data: data})
+		}
+	}
+	return out
+}
+
+// snapshotSlotSize is the size of one offset table slot: file id, data offset, data length.
+const snapshotSlotSize = 4 + 8 + 4
+
+// CompressSnapshot deflates file content for a SnapshotEntry.
+func CompressSnapshot(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteSnapshots stores snapshots in two sections: an offset table sorted by
+// file id and the compressed blobs, so a single file can be read without
+// loading the rest.
+func WriteSnapshots(path string, entries []SnapshotEntry) error {
+	sorted := make([]SnapshotEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FileID < sorted[j].FileID })
+
+	table := make([]byte, 0, len(sorted)*snapshotSlotSize)
+	var blobs bytes.Buffer
+	for _, e := range sorted {
+		table = binary.LittleEndian.AppendUint32(table, e.FileID)
+		table = binary.LittleEndian.AppendUint64(table, uint64(blobs.Len()))
+		table = binary.LittleEndian.AppendUint32(table, uint32(len(e.Data)))
+		blobs.Write(e.Data)
+	}
+	return writeArtifact(path, kindSnapshots, table, blobs.Bytes())
+}
+
+// ReadSnapshot returns the indexed content of fileID from snapshots.dat. It
+// reports false when the file has no snapshot or snapshots.dat does not exist.
+func ReadSnapshot(path string, fileID uint32) ([]byte, bool, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	sections, err := openArtifact(f, path, kindSnapshots, 2)
+	if err != nil {
+		return nil, false, err
+	}
+	table, blobs := sections[0], sections[1]
+	if table.Size()%snapshotSlotSize != 0 {
+		return nil, false, formatErrorf(path, "offset table size %d is not a multiple of %d", table.Size(), snapshotSlotSize)
+	}
+	count := int(table.Size() / snapshotSlotSize)
+	slot := func(i int) (uint32, uint64, uint32, error) {
+		var buf [snapshotSlotSize]byte
+		if _, err := table.ReadAt(buf[:], int64(i)*snapshotSlotSize); err != nil {
+			return 0, 0, 0, err
+		}
+		return binary.LittleEndian.Uint32(buf[0:4]), binary.LittleEndian.Uint64(buf[4:12]), binary.LittleEndian.Uint32(buf[12:16]), nil
+	}
+	var searchErr error
+	i := sort.Search(count, func(i int) bool {
+		id, _, _, err := slot(i)
+		if err != nil && searchErr == nil {
+			searchErr = err
+		}
+		return id >= fileID
+	})
+	if searchErr != nil {
+		return nil, false, searchErr
+	}
+	if i == count {
+		return nil, false, nil
+	}
+	id, offset, length, err := slot(i)
+	if err != nil {
+		return nil, false, err
+	}
+	if id != fileID {
+		return nil, false, nil
+	}
+	if offset+uint64(length) > uint64(blobs.Size()) {
+		return nil, false, formatErrorf(path, "snapshot for file %d runs past the data section", fileID)
+	}
+	r := flate.NewReader(io.NewSectionReader(blobs, int64(offset), int64(length)))
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, false, fmt.Errorf("snapshot for file %d: %w", fileID, err)
+	}
+	return content, true, nil
+}
+
+// LoadSnapshotIndex reads the offset table of snapshots.dat, verifying the
+// whole file's checksum, and returns the file id of every snapshot.
+func LoadSnapshotIndex(path string) ([]uint32, error) {
+	sections, err := readArtifact(path, kindSnapshots, 2)
+	if err != nil {
+		return nil, err
+	}
+	table, blobs := sections[0], sections[1]
+	if len(table)%snapshotSlotSize != 0 {
+		return nil, formatErrorf(path, "offset table size %d is not a multiple of %d", len(table), snapshotSlotSize)
+	}
+	ids := make([]uint32, 0, len(table)/snapshotSlotSize)
+	for off := 0; off < len(table); off += snapshotSlotSize {
+		id := binary.LittleEndian.Uint32(table[off:])
+		start := binary.LittleEndian.Uint64(table[off+4:])
+		length := binary.LittleEndian.Uint32(table[off+12:])
+		if start+uint64(length) > uint64(len(blobs)) {
+			return nil, formatErrorf(path, "snapshot for file %d runs past the data section", id)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
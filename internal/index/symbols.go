@@ -0,0 +1,124 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"sort"
+
+	"github.com/memkit/repodex/internal/lang"
+)
+
+// SymbolEntry is a declaration recorded in symbols.dat.
+type SymbolEntry struct {
+	FileID    uint32
+	Path      string
+	Name      string
+	Kind      string
+	Parent    string
+	Exported  bool
+	StartLine uint32
+	EndLine   uint32
+}
+
+// BuildSymbols attaches file ids to per-file symbols, ordered by path then line.
+// Symbols for paths missing from files are skipped.
+func BuildSymbols(files []FileEntry, byPath map[string][]lang.Symbol) []SymbolEntry {
+	var out []SymbolEntry
+	for _, fe := range files {
+		for _, sym := range byPath[filepath.ToSlash(fe.Path)] {
+			out = append(out, SymbolEntry{
+				FileID:    fe.FileID,
+				Path:      fe.Path,
+				Name:      sym.Name,
+				Kind:      sym.Kind,
+				Parent:    sym.Parent,
+				Exported:  sym.Exported,
+				StartLine: sym.StartLine,
+				EndLine:   sym.EndLine,
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].StartLine < out[j].StartLine
+	})
+	return out
+}
+
+// SymbolsFromPrecomputed collects the symbols carried by precomputed files.
+func SymbolsFromPrecomputed(files []FileEntry, precomputed []PrecomputedFile) []SymbolEntry {
+	byPath := make(map[string][]lang.Symbol, len(precomputed))
+	for _, f := range precomputed {
+		if len(f.Symbols) > 0 {
+			byPath[filepath.ToSlash(f.Path)] = f.Symbols
+		}
+	}
+	return BuildSymbols(files, byPath)
+}
+
+// symbolRecordMin is the smallest encoded symbol: file id, four empty
+// strings, the exported flag and two lines.
+const symbolRecordMin = 4 + 4*4 + 1 + 4 + 4
+
+// WriteSymbols stores symbols as a count followed by fixed fields and length-prefixed strings.
+func WriteSymbols(path string, symbols []SymbolEntry) error {
+	w := &bytes.Buffer{}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(symbols))); err != nil {
+		return err
+	}
+	for _, s := range symbols {
+		if err := binary.Write(w, binary.LittleEndian, s.FileID); err != nil {
+			return err
+		}
+		for _, str := range []string{s.Path, s.Name, s.Kind, s.Parent} {
+			if err := writeString(w, str); err != nil {
+				return err
+			}
+		}
+		var exported uint8
+		if s.Exported {
+			exported = 1
+		}
+		if err := binary.Write(w, binary.LittleEndian, exported); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, s.StartLine); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, s.EndLine); err != nil {
+			return err
+		}
+	}
+	return writeArtifact(path, kindSymbols, w.Bytes())
+}
+
+// LoadSymbols reads symbols.dat.
+func LoadSymbols(path string) ([]SymbolEntry, error) {
+	sections, err := readArtifact(path, kindSymbols, 1)
+	if err != nil {
+		return nil, err
+	}
+	d := newSectionDecoder(path, sections[0])
+	count := d.count(symbolRecordMin)
+	symbols := make([]SymbolEntry, 0, count)
+	for i := uint32(0); i < count && d.err == nil; i++ {
+		var s SymbolEntry
+		d.read(&s.FileID)
+		for _, dst := range []*string{&s.Path, &s.Name, &s.Kind, &s.Parent} {
+			*dst = d.string()
+		}
+		var exported uint8
+		d.read(&exported)
+		s.Exported = exported == 1
+		d.read(&s.StartLine)
+		d.read(&s.EndLine)
+		symbols = append(symbols, s)
+	}
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	return symbols, nil
+}
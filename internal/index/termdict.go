@@ -0,0 +1,295 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// terms.dat holds two sections: a table of fixed-width records sorted by
+// term, and the term bytes they point into. A record is
+//
+//	offset     uint64  start of the term's list in postings.dat
+//	posOffset  uint64  start of the term's positions in positions.dat
+//	df         uint32
+//	nameOffset uint32  start of the term in the names section
+//	nameLen    uint32
+//
+// so a term is found by binary search over the table without decoding it.
+const termRecordSize = 8 + 8 + 4 + 4 + 4
+
+// TermDict is an immutable, sorted term dictionary supporting exact lookup and
+// enumeration by prefix, suffix, and edit distance. It reads the terms.dat
+// table in place, which may be mapped memory.
+type TermDict struct {
+	table []byte
+	names []byte
+	n     int
+
+	// bySuffix holds term indexes ordered by reversed term for suffix scans.
+	// It is built on the first Suffix call.
+	suffixOnce sync.Once
+	bySuffix   []int
+	reversed   []string
+}
+
+// encodeTermTable encodes sorted terms and their metadata into the table and
+// names sections of terms.dat.
+func encodeTermTable(terms []string, infos []TermInfo) ([]byte, []byte) {
+	table := make([]byte, 0, len(terms)*termRecordSize)
+	var names []byte
+	for i, term := range terms {
+		table = binary.LittleEndian.AppendUint64(table, infos[i].Offset)
+		table = binary.LittleEndian.AppendUint64(table, infos[i].PosOffset)
+		table = binary.LittleEndian.AppendUint32(table, infos[i].DF)
+		table = binary.LittleEndian.AppendUint32(table, uint32(len(names)))
+		table = binary.LittleEndian.AppendUint32(table, uint32(len(term)))
+		names = append(names, term...)
+	}
+	return table, names
+}
+
+// newTermDict wraps the sections of terms.dat read from path, checking only
+// the table size so that opening costs nothing per term. Names outside the
+// names section read as empty; Verify reports them along with the order.
+func newTermDict(path string, table, names []byte) (*TermDict, error) {
+	if len(table)%termRecordSize != 0 {
+		return nil, formatErrorf(path, "term table size %d is not a multiple of %d", len(table), termRecordSize)
+	}
+	return &TermDict{table: table, names: names, n: len(table) / termRecordSize}, nil
+}
+
+// NewTermDict builds a dictionary from term metadata.
+func NewTermDict(terms map[string]TermInfo) *TermDict {
+	sorted := make([]string, 0, len(terms))
+	for term := range terms {
+		sorted = append(sorted, term)
+	}
+	sort.Strings(sorted)
+	infos := make([]TermInfo, len(sorted))
+	for i, term := range sorted {
+		infos[i] = terms[term]
+	}
+	table, names := encodeTermTable(sorted, infos)
+	return &TermDict{table: table, names: names, n: len(sorted)}
+}
+
+// LoadTermDict reads terms.dat into a TermDict.
+func LoadTermDict(path string) (*TermDict, error) {
+	sections, err := readArtifact(path, kindTerms, 2)
+	if err != nil {
+		return nil, err
+	}
+	return newTermDict(path, sections[0], sections[1])
+}
+
+func (d *TermDict) record(i int) []byte {
+	return d.table[i*termRecordSize : (i+1)*termRecordSize]
+}
+
+// name returns term i without copying; the bytes must not be retained.
+func (d *TermDict) name(i int) []byte {
+	if !d.nameInBounds(i) {
+		return nil
+	}
+	rec := d.record(i)
+	off := binary.LittleEndian.Uint32(rec[20:])
+	return d.names[off : off+binary.LittleEndian.Uint32(rec[24:])]
+}
+
+// nameInBounds reports whether term i lies inside the names section.
+func (d *TermDict) nameInBounds(i int) bool {
+	rec := d.record(i)
+	off, n := binary.LittleEndian.Uint32(rec[20:]), binary.LittleEndian.Uint32(rec[24:])
+	return uint64(off)+uint64(n) <= uint64(len(d.names))
+}
+
+func (d *TermDict) term(i int) string {
+	return string(d.name(i))
+}
+
+func (d *TermDict) info(i int) TermInfo {
+	rec := d.record(i)
+	return TermInfo{
+		Offset:    binary.LittleEndian.Uint64(rec[0:]),
+		PosOffset: binary.LittleEndian.Uint64(rec[8:]),
+		DF:        binary.LittleEndian.Uint32(rec[16:]),
+	}
+}
+
+// search returns the index of the first term not less than s.
+func (d *TermDict) search(s string) int {
+	return sort.Search(d.n, func(i int) bool { return string(d.name(i)) >= s })
+}
+
+// Len returns the number of terms.
+func (d *TermDict) Len() int {
+	if d == nil {
+		return 0
+	}
+	return d.n
+}
+
+// Lookup returns the metadata for an exact term.
+func (d *TermDict) Lookup(term string) (TermInfo, bool) {
+	if d == nil {
+		return TermInfo{}, false
+	}
+	i := d.search(term)
+	if i < d.n && string(d.name(i)) == term {
+		return d.info(i), true
+	}
+	return TermInfo{}, false
+}
+
+// Prefix returns all terms starting with prefix in sorted order.
+func (d *TermDict) Prefix(prefix string) []string {
+	if d == nil {
+		return nil
+	}
+	var out []string
+	for i := d.search(prefix); i < d.n && bytes.HasPrefix(d.name(i), []byte(prefix)); i++ {
+		out = append(out, d.term(i))
+	}
+	return out
+}
+
+// Suffix returns all terms ending with suffix in sorted order.
+func (d *TermDict) Suffix(suffix string) []string {
+	if d == nil {
+		return nil
+	}
+	d.suffixOnce.Do(d.buildSuffixIndex)
+	rev := reverseString(suffix)
+	start := sort.Search(len(d.bySuffix), func(i int) bool {
+		return d.reversed[d.bySuffix[i]] >= rev
+	})
+	var out []string
+	for i := start; i < len(d.bySuffix) && strings.HasPrefix(d.reversed[d.bySuffix[i]], rev); i++ {
+		out = append(out, d.term(d.bySuffix[i]))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (d *TermDict) buildSuffixIndex() {
+	d.reversed = make([]string, d.n)
+	d.bySuffix = make([]int, d.n)
+	for i := 0; i < d.n; i++ {
+		d.reversed[i] = reverseString(d.term(i))
+		d.bySuffix[i] = i
+	}
+	sort.Slice(d.bySuffix, func(i, j int) bool {
+		return d.reversed[d.bySuffix[i]] < d.reversed[d.bySuffix[j]]
+	})
+}
+
+// FuzzyMatch is a term within the requested edit distance.
+type FuzzyMatch struct {
+	Term     string
+	Distance int
+}
+
+// Fuzzy returns terms within maxDist edits of term (insertions, deletions,
+// substitutions, and adjacent transpositions), ordered by distance then term.
+//
+// The sorted table is walked as a trie: terms sharing a prefix with the
+// previous one reuse its distance rows, and once every entry of a row
+// exceeds maxDist no extension can come back within it, so all terms with
+// that prefix are skipped by binary search. The cost then follows the terms
+// near term rather than the size of the dictionary.
+func (d *TermDict) Fuzzy(term string, maxDist int) []FuzzyMatch {
+	if d == nil || maxDist < 0 {
+		return nil
+	}
+	target := []rune(term)
+	// rows[k] holds the distances between the first k runes of the
+	// candidate and every prefix of target.
+	rows := [][]int{make([]int, len(target)+1)}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	var prev, cand []rune
+	var out []FuzzyMatch
+	for i := 0; i < d.n; {
+		name := d.name(i)
+		cand = append(cand[:0], []rune(string(name))...)
+		k := 0
+		for k < len(prev) && k < len(cand) && k < len(rows)-1 && prev[k] == cand[k] {
+			k++
+		}
+		rows = rows[:k+1]
+		pruned := false
+		for ; k < len(cand); k++ {
+			row := fuzzyRow(rows, target, cand, k)
+			rows = append(rows, row)
+			if minInt(row) > maxDist {
+				prefix := []byte(string(cand[:k+1]))
+				rest := d.n - i
+				i += sort.Search(rest, func(m int) bool { return !bytes.HasPrefix(d.name(i+m), prefix) })
+				pruned = true
+				break
+			}
+		}
+		prev, cand = cand, prev
+		if pruned {
+			continue
+		}
+		if dist := rows[len(prev)][len(target)]; dist <= maxDist {
+			out = append(out, FuzzyMatch{Term: string(name), Distance: dist})
+		}
+		i++
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Distance < out[j].Distance
+	})
+	return out
+}
+
+// fuzzyRow computes the optimal string alignment row for rune k of cand from
+// the rows of its first k and k-1 runes.
+func fuzzyRow(rows [][]int, target, cand []rune, k int) []int {
+	above := rows[k]
+	row := make([]int, len(target)+1)
+	row[0] = k + 1
+	for j := 1; j <= len(target); j++ {
+		cost := 1
+		if target[j-1] == cand[k] {
+			cost = 0
+		}
+		v := above[j] + 1
+		if ins := row[j-1] + 1; ins < v {
+			v = ins
+		}
+		if sub := above[j-1] + cost; sub < v {
+			v = sub
+		}
+		if k > 0 && j > 1 && cand[k] == target[j-2] && cand[k-1] == target[j-1] {
+			if tr := rows[k-1][j-2] + 1; tr < v {
+				v = tr
+			}
+		}
+		row[j] = v
+	}
+	return row
+}
+
+func minInt(values []int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
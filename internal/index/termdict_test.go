@@ -0,0 +1,125 @@
+package index
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTermDictLookupPrefixSuffix(t *testing.T) {
+	dict := NewTermDict(map[string]TermInfo{
+		"auth":        {Offset: 0, DF: 3},
+		"author":      {Offset: 3, DF: 1},
+		"authorize":   {Offset: 4, DF: 2},
+		"service":     {Offset: 6, DF: 4},
+		"userservice": {Offset: 10, DF: 1},
+		"receive":     {Offset: 11, DF: 2},
+	})
+
+	if info, ok := dict.Lookup("author"); !ok || info.Offset != 3 || info.DF != 1 {
+		t.Fatalf("unexpected lookup result %+v %v", info, ok)
+	}
+	if _, ok := dict.Lookup("auto"); ok {
+		t.Fatalf("expected missing term")
+	}
+	if got := dict.Prefix("auth"); !reflect.DeepEqual(got, []string{"auth", "author", "authorize"}) {
+		t.Fatalf("unexpected prefix expansion %v", got)
+	}
+	if got := dict.Suffix("service"); !reflect.DeepEqual(got, []string{"service", "userservice"}) {
+		t.Fatalf("unexpected suffix expansion %v", got)
+	}
+	if got := dict.Prefix("zzz"); len(got) != 0 {
+		t.Fatalf("expected no prefix matches, got %v", got)
+	}
+}
+
+func TestTermDictFuzzy(t *testing.T) {
+	dict := NewTermDict(map[string]TermInfo{
+		"receive": {DF: 1},
+		"recover": {DF: 1},
+		"believe": {DF: 1},
+		"server":  {DF: 1},
+	})
+
+	got := dict.Fuzzy("recieve", 1)
+	if len(got) != 1 || got[0].Term != "receive" || got[0].Distance != 1 {
+		t.Fatalf("expected transposition to cost one edit, got %+v", got)
+	}
+	got = dict.Fuzzy("recieve", 2)
+	if len(got) != 2 || got[0].Term != "receive" || got[1].Term != "believe" || got[1].Distance != 2 {
+		t.Fatalf("unexpected distance-2 matches %+v", got)
+	}
+	if got := dict.Fuzzy("receive", 0); len(got) != 1 || got[0].Distance != 0 {
+		t.Fatalf("expected exact match at distance 0, got %+v", got)
+	}
+}
+
+// osaDistance is the plain optimal string alignment distance Fuzzy must agree with.
+func osaDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// syntheticTerms returns n distinct identifier-like terms.
+func syntheticTerms(n int) map[string]TermInfo {
+	syllables := []string{"get", "set", "user", "ser", "vice", "re", "ceive", "load", "auth", "or", "ize", "é", "node", "x"}
+	terms := make(map[string]TermInfo, n)
+	for i := 0; len(terms) < n; i++ {
+		var b strings.Builder
+		for v := i + 1; v > 0; v /= len(syllables) {
+			b.WriteString(syllables[v%len(syllables)])
+		}
+		terms[b.String()] = TermInfo{DF: 1}
+	}
+	return terms
+}
+
+func TestTermDictFuzzyMatchesBruteForce(t *testing.T) {
+	terms := syntheticTerms(3000)
+	terms[""] = TermInfo{DF: 1}
+	dict := NewTermDict(terms)
+	for _, q := range []string{"usersevrice", "recieve", "getx", "é", "", "authorizeuser", "zzzz"} {
+		for maxDist := 0; maxDist <= 2; maxDist++ {
+			want := map[string]int{}
+			for term := range terms {
+				if dist := osaDistance([]rune(q), []rune(term)); dist <= maxDist {
+					want[term] = dist
+				}
+			}
+			got := map[string]int{}
+			for _, m := range dict.Fuzzy(q, maxDist) {
+				got[m.Term] = m.Distance
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Fuzzy(%q, %d) = %v, want %v", q, maxDist, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkTermDictFuzzy(b *testing.B) {
+	dict := NewTermDict(syntheticTerms(200000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dict.Fuzzy("usersevriceload", 2)
+	}
+}
@@ -1,5 +1,11 @@
 package index
 
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
 // FileEntry describes a scanned file.
 type FileEntry struct {
 	FileID uint32
@@ -11,10 +17,45 @@ type FileEntry struct {
 
 // ChunkEntry captures a chunk of a file.
 type ChunkEntry struct {
+	ChunkID    uint32
+	FileID     uint32
+	Path       string
+	StartLine  uint32
+	EndLine    uint32
+	TokenCount uint32
+	Snippet    string
+}
+
+// Posting records how often and where a term occurs in a chunk.
+// Positions are token ordinals within the chunk text, in ascending order.
+type Posting struct {
 	ChunkID   uint32
-	FileID    uint32
-	Path      string
-	StartLine uint32
-	EndLine   uint32
-	Snippet   string
+	TF        uint32
+	Positions []uint32
+}
+
+// FileByPath finds the entry for a repo-relative path, accepting OS separators
+// and a leading "./".
+func FileByPath(files []FileEntry, p string) (FileEntry, bool) {
+	cleaned := path.Clean(filepath.ToSlash(strings.TrimSpace(p)))
+	for _, fe := range files {
+		if fe.Path == cleaned {
+			return fe, true
+		}
+	}
+	return FileEntry{}, false
+}
+
+// FileByID finds the entry for a file id. Ids are assigned densely from 1 in
+// file order, so the direct slot is checked before falling back to a scan.
+func FileByID(files []FileEntry, id uint32) (FileEntry, bool) {
+	if id >= 1 && int(id) <= len(files) && files[id-1].FileID == id {
+		return files[id-1], true
+	}
+	for _, fe := range files {
+		if fe.FileID == id {
+			return fe, true
+		}
+	}
+	return FileEntry{}, false
 }
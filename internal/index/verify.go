@@ -0,0 +1,308 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/memkit/repodex/internal/store"
+)
+
+// maxVerifyProblems caps the problems listed per check, so a badly damaged
+// index does not produce one line per posting.
+const maxVerifyProblems = 20
+
+// VerifyReport is the result of Verify.
+type VerifyReport struct {
+	OK         bool             `json:"ok"`
+	Generation string           `json:"generation,omitempty"`
+	Artifacts  []ArtifactReport `json:"artifacts"`
+	Problems   []string         `json:"problems,omitempty"`
+}
+
+// ArtifactReport is the container check of one artifact file.
+type ArtifactReport struct {
+	Name string `json:"name"`
+	// Missing is set for an absent optional artifact (snapshots.dat).
+	Missing bool          `json:"missing,omitempty"`
+	Info    *ArtifactInfo `json:"info,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// problemList collects problems of one check up to maxVerifyProblems.
+type problemList struct {
+	check   string
+	items   []string
+	dropped int
+}
+
+func (p *problemList) add(format string, args ...interface{}) {
+	if len(p.items) >= maxVerifyProblems {
+		p.dropped++
+		return
+	}
+	p.items = append(p.items, p.check+": "+fmt.Sprintf(format, args...))
+}
+
+func (p *problemList) flush(r *VerifyReport) {
+	r.Problems = append(r.Problems, p.items...)
+	if p.dropped > 0 {
+		r.Problems = append(r.Problems, fmt.Sprintf("%s: %d more problems not listed", p.check, p.dropped))
+	}
+}
+
+// Verify checks every artifact of gen for damage, decodes them, and
+// validates them against each other and against meta: chunks, symbols,
+// imports and snapshots reference existing files, term lists decode and tile
+// postings.dat and positions.dat exactly, postings reference existing chunks, and
+// the counts in meta.json match the artifacts.
+func Verify(gen store.Generation, meta store.Meta) VerifyReport {
+	report := VerifyReport{Generation: gen.Name}
+
+	artifacts := []struct {
+		name     string
+		path     string
+		optional bool
+	}{
+		{store.FilesFile, gen.FilesPath(), false},
+		{store.ChunksFile, gen.ChunksPath(), false},
+		{store.TermsFile, gen.TermsPath(), false},
+		{store.PostingsFile, gen.PostingsPath(), false},
+		{store.PositionsFile, gen.PositionsPath(), false},
+		{store.SymbolsFile, gen.SymbolsPath(), false},
+		{store.ImportsFile, gen.ImportsPath(), false},
+		{store.SnapshotsFile, gen.SnapshotsPath(), true},
+	}
+	valid := make(map[string]bool, len(artifacts))
+	for _, a := range artifacts {
+		ar := ArtifactReport{Name: a.name}
+		info, err := VerifyArtifact(a.path)
+		switch {
+		case err == nil:
+			ar.Info = &info
+			valid[a.name] = true
+		case errors.Is(err, os.ErrNotExist) && a.optional:
+			ar.Missing = true
+		case errors.Is(err, os.ErrNotExist):
+			ar.Error = "missing"
+			report.Problems = append(report.Problems, a.name+": missing")
+		default:
+			ar.Error = err.Error()
+			report.Problems = append(report.Problems, err.Error())
+		}
+		report.Artifacts = append(report.Artifacts, ar)
+	}
+
+	decode := func(name string, load func() error) bool {
+		if !valid[name] {
+			return false
+		}
+		if err := load(); err != nil {
+			report.Problems = append(report.Problems, err.Error())
+			return false
+		}
+		return true
+	}
+	var (
+		files    []FileEntry
+		table    *ChunkTable
+		dict     *TermDict
+		terms    map[string]TermInfo
+		postings *Postings
+		symbols  []SymbolEntry
+		imports  []ImportEntry
+		snapIDs  []uint32
+		err      error
+	)
+	haveFiles := decode(store.FilesFile, func() error { files, err = LoadFileEntries(gen.FilesPath()); return err })
+	haveChunks := decode(store.ChunksFile, func() error { table, err = loadChunkTable(gen.ChunksPath()); return err })
+	haveTerms := decode(store.TermsFile, func() error { dict, err = LoadTermDict(gen.TermsPath()); return err })
+	if haveTerms {
+		terms = make(map[string]TermInfo, dict.Len())
+		for i := 0; i < dict.Len(); i++ {
+			terms[dict.term(i)] = dict.info(i)
+		}
+	}
+	havePostings := valid[store.PositionsFile] &&
+		decode(store.PostingsFile, func() error { postings, err = LoadPostings(gen.PostingsPath(), gen.PositionsPath()); return err })
+	haveSymbols := decode(store.SymbolsFile, func() error { symbols, err = LoadSymbols(gen.SymbolsPath()); return err })
+	haveImports := decode(store.ImportsFile, func() error { imports, err = LoadImports(gen.ImportsPath()); return err })
+	haveSnapshots := decode(store.SnapshotsFile, func() error { snapIDs, err = LoadSnapshotIndex(gen.SnapshotsPath()); return err })
+
+	filesByID := make(map[uint32]string, len(files))
+	if haveFiles {
+		p := problemList{check: store.FilesFile}
+		for _, fe := range files {
+			if _, dup := filesByID[fe.FileID]; dup {
+				p.add("file id %d is used twice", fe.FileID)
+			}
+			filesByID[fe.FileID] = fe.Path
+		}
+		p.flush(&report)
+	}
+	// checkFile validates a file reference by id and, when path is not
+	// empty, that the id names that path.
+	checkFile := func(p *problemList, what string, id uint32, path string) {
+		want, ok := filesByID[id]
+		if !ok {
+			p.add("%s references missing file id %d", what, id)
+		} else if path != "" && path != want {
+			p.add("%s names %q but file id %d is %q", what, path, id, want)
+		}
+	}
+
+	chunkIDs := make(map[uint32]struct{})
+	if haveChunks {
+		p := problemList{check: store.ChunksFile}
+		// Readers binary search the table by id and take the token count
+		// sum as stored, so neither is checked when chunks.dat is opened.
+		var total uint64
+		for i := 0; i < table.Len(); i++ {
+			ch := table.At(i)
+			what := fmt.Sprintf("chunk %d", ch.ChunkID)
+			if i > 0 && ch.ChunkID <= table.ID(i-1) {
+				p.add("chunk id %d follows %d; ids must ascend", ch.ChunkID, table.ID(i-1))
+			}
+			for _, f := range []int{5, 7} {
+				if !table.stringInBounds(i, f) {
+					p.add("%s names a string outside the strings section", what)
+				}
+			}
+			total += uint64(ch.TokenCount)
+			chunkIDs[ch.ChunkID] = struct{}{}
+			if ch.StartLine > ch.EndLine {
+				p.add("%s ends (line %d) before it starts (line %d)", what, ch.EndLine, ch.StartLine)
+			}
+			if haveFiles {
+				checkFile(&p, what, ch.FileID, ch.Path)
+			}
+		}
+		if stored := table.TotalTokenCount(); stored != total {
+			p.add("token counts sum to %d but chunks.dat stores %d", total, stored)
+		}
+		p.flush(&report)
+	}
+
+	if haveTerms {
+		p := problemList{check: store.TermsFile}
+		for i := 0; i < dict.Len(); i++ {
+			if !dict.nameInBounds(i) {
+				p.add("term %d lies outside the names section", i)
+			}
+		}
+		// Lookups binary search the table, so it must be strictly sorted.
+		for i := 1; i < dict.Len(); i++ {
+			switch prev, cur := dict.term(i-1), dict.term(i); {
+			case prev == cur:
+				p.add("term %q is stored more than once", cur)
+			case prev > cur:
+				p.add("term %q is out of order after %q", cur, prev)
+			}
+		}
+		if havePostings {
+			// Term lists must tile postings.dat and positions.dat: in offset
+			// order each one starts where the previous ended.
+			byOffset := make([]string, 0, len(terms))
+			for term := range terms {
+				byOffset = append(byOffset, term)
+			}
+			sort.Slice(byOffset, func(i, j int) bool { return terms[byOffset[i]].Offset < terms[byOffset[j]].Offset })
+			var next, posNext uint64
+			for _, term := range byOffset {
+				info := terms[term]
+				if info.DF == 0 {
+					p.add("term %q has no postings", term)
+					continue
+				}
+				end, posEnd, err := postings.extent(info)
+				if err != nil {
+					p.add("term %q: %v", term, err)
+					continue
+				}
+				if info.Offset != next {
+					p.add("term %q postings start at %d, expected %d", term, info.Offset, next)
+				}
+				if info.PosOffset != posNext {
+					p.add("term %q positions start at %d, expected %d", term, info.PosOffset, posNext)
+				}
+				if end > next {
+					next = end
+				}
+				if posEnd > posNext {
+					posNext = posEnd
+				}
+			}
+			if total := uint64(len(postings.data)); next != total {
+				p.add("terms cover %d bytes of postings but postings.dat holds %d", next, total)
+			}
+			if total := uint64(len(postings.positions)); posNext != total {
+				p.add("terms cover %d bytes of positions but positions.dat holds %d", posNext, total)
+			}
+		}
+		p.flush(&report)
+	}
+	if havePostings && haveTerms && haveChunks {
+		p := problemList{check: store.PostingsFile}
+		sorted := make([]string, 0, len(terms))
+		for term := range terms {
+			sorted = append(sorted, term)
+		}
+		sort.Strings(sorted)
+		for _, term := range sorted {
+			list, err := postings.List(terms[term])
+			if err != nil {
+				p.add("term %q: %v", term, err)
+				continue
+			}
+			for _, post := range list {
+				if _, ok := chunkIDs[post.ChunkID]; !ok {
+					p.add("term %q references missing chunk id %d", term, post.ChunkID)
+				}
+			}
+		}
+		p.flush(&report)
+	}
+	if haveSymbols && haveFiles {
+		p := problemList{check: store.SymbolsFile}
+		for _, s := range symbols {
+			checkFile(&p, fmt.Sprintf("symbol %s", s.Name), s.FileID, s.Path)
+		}
+		p.flush(&report)
+	}
+	if haveImports && haveFiles {
+		p := problemList{check: store.ImportsFile}
+		for _, imp := range imports {
+			checkFile(&p, fmt.Sprintf("import of %q at %s:%d", imp.Specifier, imp.Path, imp.Line), imp.FileID, imp.Path)
+		}
+		p.flush(&report)
+	}
+	if haveSnapshots && haveFiles {
+		p := problemList{check: store.SnapshotsFile}
+		for _, id := range snapIDs {
+			checkFile(&p, "snapshot", id, "")
+		}
+		p.flush(&report)
+	}
+
+	p := problemList{check: "meta.json"}
+	if meta.SchemaVersion != store.SchemaVersion {
+		p.add("schema version %d, this build writes %d; run sync to rebuild the index", meta.SchemaVersion, store.SchemaVersion)
+	}
+	if meta.Generation != gen.Name {
+		p.add("names generation %q, verified %q", meta.Generation, gen.Name)
+	}
+	if haveFiles && meta.FileCount != len(files) {
+		p.add("FileCount is %d but files.dat holds %d files", meta.FileCount, len(files))
+	}
+	if haveChunks && meta.ChunkCount != table.Len() {
+		p.add("ChunkCount is %d but chunks.dat holds %d chunks", meta.ChunkCount, table.Len())
+	}
+	if haveTerms && meta.TermCount != dict.Len() {
+		p.add("TermCount is %d but terms.dat holds %d terms", meta.TermCount, dict.Len())
+	}
+	p.flush(&report)
+
+	report.OK = len(report.Problems) == 0
+	return report
+}
@@ -9,10 +9,49 @@ type ChunkDraft struct {
 	Snippet   string
 }
 
+// Symbol kinds reported by language plugins.
+const (
+	KindFunction  = "function"
+	KindClass     = "class"
+	KindMethod    = "method"
+	KindInterface = "interface"
+	KindType      = "type"
+	KindEnum      = "enum"
+	KindNamespace = "namespace"
+	KindVariable  = "variable"
+)
+
+// Symbol is a declaration found in a file. Parent names the enclosing class for methods.
+type Symbol struct {
+	Name      string
+	Kind      string
+	Parent    string
+	Exported  bool
+	StartLine uint32
+	EndLine   uint32
+}
+
+// Import kinds reported by language plugins.
+const (
+	ImportStatic  = "import"
+	ImportExport  = "export"
+	ImportRequire = "require"
+	ImportDynamic = "dynamic"
+)
+
+// Import is a module specifier referenced by a file.
+type Import struct {
+	Specifier string
+	Kind      string
+	Line      uint32
+}
+
 // LanguagePlugin defines the interface implemented by language processors.
 type LanguagePlugin interface {
 	ID() string
 	Match(path string) bool
 	ChunkFile(path string, content []byte, cfg config.ChunkingConfig, limits config.LimitsConfig) ([]ChunkDraft, error)
 	TokenizeChunk(path string, chunkText string, cfg config.TokenizationConfig) []string
+	Symbols(path string, content []byte) []Symbol
+	Imports(path string, content []byte) []Import
 }
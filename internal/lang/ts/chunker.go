@@ -1,6 +1,7 @@
 package ts
 
 import (
+	"regexp"
 	"strings"
 	"unicode/utf8"
 
@@ -58,31 +59,15 @@ func collectBlocks(lines []string, cfg config.ChunkingConfig) []block {
 				} else {
 					blocks = append(blocks, block{start: lineNum, end: lineNum})
 					currentIdx = len(blocks) - 1
-					currentType = "other"
+					currentType = groupOther
 				}
-			} else if isBoundary(trimmed) {
-				trigger := classifyTrigger(trimmed)
-				switch trigger {
-				case "import":
-					if currentType != "import" {
-						blocks = append(blocks, block{start: lineNum, end: lineNum})
-						currentIdx = len(blocks) - 1
-						currentType = "import"
-					} else {
-						blocks[currentIdx].end = lineNum
-					}
-				case "constlet":
-					if currentType != "constlet" {
-						blocks = append(blocks, block{start: lineNum, end: lineNum})
-						currentIdx = len(blocks) - 1
-						currentType = "constlet"
-					} else {
-						blocks[currentIdx].end = lineNum
-					}
-				default:
+			} else if decl, ok := matchDeclaration(trimmed); ok {
+				if decl.group != groupOther && decl.group == currentType {
+					blocks[currentIdx].end = lineNum
+				} else {
 					blocks = append(blocks, block{start: lineNum, end: lineNum})
 					currentIdx = len(blocks) - 1
-					currentType = "other"
+					currentType = decl.group
 				}
 			} else if currentIdx >= 0 {
 				blocks[currentIdx].end = lineNum
@@ -97,43 +82,77 @@ func collectBlocks(lines []string, cfg config.ChunkingConfig) []block {
 	return blocks
 }
 
-func isBoundary(trimmed string) bool {
-	lowered := strings.ToLower(trimmed)
-	prefixes := []string{
-		"import ",
-		"export default ",
-		"export function ",
-		"function ",
-		"export class ",
-		"class ",
-		"export interface ",
-		"interface ",
-		"export type ",
-		"type ",
-		"export enum ",
-		"enum ",
-		"export const ",
-		"const ",
-		"let ",
+// Top-level statements are recognised once, by matchDeclaration: the chunker
+// starts a block at each one and Symbols reports the symbols they declare.
+var (
+	declPattern = regexp.MustCompile(`^(export\s+)?(default\s+)?(?:declare\s+)?(?:abstract\s+)?(?:async\s+)?(function\s*\*?|class|interface|type|const\s+enum|enum|namespace|module|const|let|var)\s+([A-Za-z_$][\w$]*)`)
+	// anonymousDefaultPattern matches `export default function (...)` and `export default class {`.
+	anonymousDefaultPattern = regexp.MustCompile(`^export\s+default\s+(?:abstract\s+)?(?:async\s+)?(function|class)\b`)
+	// functionValuePattern matches the initializer of an arrow-function or function-expression const.
+	functionValuePattern = regexp.MustCompile(`^\s*(?::[^=]*)?=\s*(?:async\s+)?(?:function\b|(?:<[^>]*>\s*)?\(|[A-Za-z_$][\w$]*\s*=>)`)
+)
+
+// Block groups: consecutive imports, and consecutive const/let/var
+// declarations, share a block; every other declaration starts its own.
+const (
+	groupImport   = "import"
+	groupConstLet = "constlet"
+	groupOther    = "other"
+)
+
+// declaration is a top-level statement that starts a chunk block.
+type declaration struct {
+	group string
+	// symbol is the declared symbol; hasSymbol is false for imports and
+	// anonymous default exports such as `export default {`.
+	symbol    lang.Symbol
+	hasSymbol bool
+}
+
+// matchDeclaration classifies a trimmed top-level line.
+func matchDeclaration(trimmed string) (declaration, bool) {
+	if strings.HasPrefix(trimmed, "import ") {
+		return declaration{group: groupImport}, true
 	}
-	for _, p := range prefixes {
-		if strings.HasPrefix(lowered, p) {
-			return true
+	if m := declPattern.FindStringSubmatch(trimmed); m != nil {
+		d := declaration{group: groupOther, hasSymbol: true}
+		d.symbol = lang.Symbol{Name: m[4], Exported: m[1] != ""}
+		keyword := strings.Join(strings.Fields(m[3]), " ")
+		switch {
+		case strings.HasPrefix(keyword, "function"):
+			d.symbol.Kind = lang.KindFunction
+		case keyword == "class":
+			d.symbol.Kind = lang.KindClass
+		case keyword == "interface":
+			d.symbol.Kind = lang.KindInterface
+		case keyword == "type":
+			d.symbol.Kind = lang.KindType
+		case keyword == "enum" || keyword == "const enum":
+			d.symbol.Kind = lang.KindEnum
+		case keyword == "namespace" || keyword == "module":
+			d.symbol.Kind = lang.KindNamespace
+		default:
+			d.symbol.Kind = lang.KindVariable
+			if functionValuePattern.MatchString(trimmed[len(m[0]):]) {
+				d.symbol.Kind = lang.KindFunction
+			}
+			if m[2] == "" {
+				d.group = groupConstLet
+			}
 		}
+		return d, true
 	}
-	return false
-}
-
-func classifyTrigger(trimmed string) string {
-	lowered := strings.ToLower(trimmed)
-	switch {
-	case strings.HasPrefix(lowered, "import "):
-		return "import"
-	case strings.HasPrefix(lowered, "export const ") || strings.HasPrefix(lowered, "const ") || strings.HasPrefix(lowered, "let "):
-		return "constlet"
-	default:
-		return "other"
+	if m := anonymousDefaultPattern.FindStringSubmatch(trimmed); m != nil {
+		kind := lang.KindFunction
+		if m[1] == "class" {
+			kind = lang.KindClass
+		}
+		return declaration{group: groupOther, symbol: lang.Symbol{Name: "default", Kind: kind, Exported: true}, hasSymbol: true}, true
+	}
+	if strings.HasPrefix(trimmed, "export default ") {
+		return declaration{group: groupOther}, true
 	}
+	return declaration{}, false
 }
 
 func updateDepth(line string, braceDepth *int, parenDepth *int, inBlockComment *bool) {
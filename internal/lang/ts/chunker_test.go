@@ -170,3 +170,30 @@ func TestUpdateDepthNestedAndComments(t *testing.T) {
 		}
 	}
 }
+
+func TestChunkerStartsBlocksAtSymbols(t *testing.T) {
+	content := "import { x } from \"./x\";\n" +
+		"export async function load() {\n  return x;\n}\n" +
+		"export abstract class Base {\n  run() {}\n}\n" +
+		"namespace Util {\n  export const y = 1;\n}\n" +
+		"var legacy = 2;\n"
+	cfg := config.ChunkingConfig{MaxLines: 50, OverlapLines: 5, MinChunkLines: 1}
+	limits := config.LimitsConfig{MaxSnippetBytes: 200}
+
+	chunks, err := ChunkFile("sample.ts", []byte(content), cfg, limits)
+	if err != nil {
+		t.Fatalf("chunk error: %v", err)
+	}
+	starts := make(map[uint32]bool)
+	for _, c := range chunks {
+		starts[c.StartLine] = true
+	}
+	for _, sym := range Symbols("sample.ts", []byte(content)) {
+		if sym.Parent == "" && !starts[sym.StartLine] {
+			t.Fatalf("top-level symbol %s at line %d does not start a chunk: %+v", sym.Name, sym.StartLine, chunks)
+		}
+	}
+	if len(chunks) != 5 {
+		t.Fatalf("expected import, function, class, namespace and var chunks, got %+v", chunks)
+	}
+}
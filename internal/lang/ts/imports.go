@@ -0,0 +1,104 @@
+package ts
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/memkit/repodex/internal/lang"
+	"github.com/memkit/repodex/internal/textutil"
+)
+
+var importPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	// import x from "m"; import { a, b as c } from "m"; import * as ns from "m"; import type T from "m"
+	{lang.ImportStatic, regexp.MustCompile(`\bimport\s+(?:type\s+)?[\w$*{},\s]+?\s*from\s*['"]([^'"\n]+)['"]`)},
+	// import "m"
+	{lang.ImportStatic, regexp.MustCompile(`\bimport\s*['"]([^'"\n]+)['"]`)},
+	// export * from "m"; export * as ns from "m"; export { a } from "m"
+	{lang.ImportExport, regexp.MustCompile(`\bexport\s+(?:type\s+)?(?:\*(?:\s+as\s+[\w$]+)?|\{[^}]*\})\s*from\s*['"]([^'"\n]+)['"]`)},
+	// require("m"), including import x = require("m")
+	{lang.ImportRequire, regexp.MustCompile(`\brequire\s*\(\s*['"]([^'"\n]+)['"]\s*\)`)},
+	// import("m")
+	{lang.ImportDynamic, regexp.MustCompile(`\bimport\s*\(\s*['"]([^'"\n]+)['"]\s*\)`)},
+}
+
+// Imports extracts static, re-export, require and dynamic import specifiers in
+// source order. Comments are blanked first so commented-out imports are skipped;
+// dynamic imports with non-literal specifiers are ignored.
+func Imports(path string, content []byte) []lang.Import {
+	text := stripComments(textutil.NormalizeNewlinesString(string(content)))
+
+	type found struct {
+		offset int
+		imp    lang.Import
+	}
+	var matches []found
+	for _, p := range importPatterns {
+		for _, loc := range p.pattern.FindAllStringSubmatchIndex(text, -1) {
+			if loc[0] > 0 && text[loc[0]-1] == '.' {
+				// Member calls such as loader.import("x") are not module imports.
+				continue
+			}
+			matches = append(matches, found{
+				offset: loc[0],
+				imp: lang.Import{
+					Specifier: text[loc[2]:loc[3]],
+					Kind:      p.kind,
+					Line:      uint32(strings.Count(text[:loc[0]], "\n") + 1),
+				},
+			})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].offset < matches[j].offset })
+	out := make([]lang.Import, 0, len(matches))
+	for _, m := range matches {
+		out = append(out, m.imp)
+	}
+	return out
+}
+
+// stripComments replaces line and block comments with spaces, keeping newlines
+// and string literal contents so offsets and line numbers are preserved.
+func stripComments(text string) string {
+	b := []byte(text)
+	var delim byte
+	inLine, inBlock := false, false
+	for i := 0; i < len(b); i++ {
+		ch := b[i]
+		switch {
+		case inLine:
+			if ch == '\n' {
+				inLine = false
+			} else {
+				b[i] = ' '
+			}
+		case inBlock:
+			if ch == '*' && i+1 < len(b) && b[i+1] == '/' {
+				b[i], b[i+1] = ' ', ' '
+				inBlock = false
+				i++
+			} else if ch != '\n' {
+				b[i] = ' '
+			}
+		case delim != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == delim || (ch == '\n' && delim != '`') {
+				delim = 0
+			}
+		case ch == '"' || ch == '\'' || ch == '`':
+			delim = ch
+		case ch == '/' && i+1 < len(b) && b[i+1] == '/':
+			b[i] = ' '
+			inLine = true
+		case ch == '/' && i+1 < len(b) && b[i+1] == '*':
+			b[i], b[i+1] = ' ', ' '
+			inBlock = true
+			i++
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,44 @@
+package ts
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/memkit/repodex/internal/lang"
+)
+
+func TestImportsExtractsSpecifiers(t *testing.T) {
+	content := `import React from "react";
+import {
+  a,
+  b as c,
+} from './local';
+import type { User } from "@app/models";
+import "./polyfill";
+// import { skipped } from "./commented";
+/* import x from "./blocked"; */
+export * from "./reexport";
+export { d } from '../sibling';
+const fs = require("fs");
+import legacy = require("./legacy");
+const lazy = () => import("./lazy");
+loader.import("./not-a-module");
+const name = "./dynamic-" + x;
+import(name);
+`
+	got := Imports("a.ts", []byte(content))
+	want := []lang.Import{
+		{Specifier: "react", Kind: lang.ImportStatic, Line: 1},
+		{Specifier: "./local", Kind: lang.ImportStatic, Line: 2},
+		{Specifier: "@app/models", Kind: lang.ImportStatic, Line: 6},
+		{Specifier: "./polyfill", Kind: lang.ImportStatic, Line: 7},
+		{Specifier: "./reexport", Kind: lang.ImportExport, Line: 10},
+		{Specifier: "../sibling", Kind: lang.ImportExport, Line: 11},
+		{Specifier: "fs", Kind: lang.ImportRequire, Line: 12},
+		{Specifier: "./legacy", Kind: lang.ImportRequire, Line: 13},
+		{Specifier: "./lazy", Kind: lang.ImportDynamic, Line: 14},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected imports:\n got %+v\nwant %+v", got, want)
+	}
+}
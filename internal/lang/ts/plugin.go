@@ -33,3 +33,11 @@ func (p TSPlugin) ChunkFile(path string, content []byte, cfg config.ChunkingConf
 func (p TSPlugin) TokenizeChunk(path string, chunkText string, cfg config.TokenizationConfig) []string {
 	return Tokenize(path, chunkText, cfg)
 }
+
+func (p TSPlugin) Symbols(path string, content []byte) []lang.Symbol {
+	return Symbols(path, content)
+}
+
+func (p TSPlugin) Imports(path string, content []byte) []lang.Import {
+	return Imports(path, content)
+}
@@ -0,0 +1,126 @@
+package ts
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/memkit/repodex/internal/lang"
+	"github.com/memkit/repodex/internal/textutil"
+)
+
+var (
+	methodPattern        = regexp.MustCompile(`^((?:(?:public|private|protected|static|readonly|async|abstract|override|declare|get|set)\s+)*)\*?\s*(#?[A-Za-z_$][\w$]*)\s*[?!]?\s*(?:<[^>]*>)?\s*\(`)
+	propertyArrowPattern = regexp.MustCompile(`^((?:(?:public|private|protected|static|readonly|override|declare)\s+)*)(#?[A-Za-z_$][\w$]*)\s*[?!]?\s*(?::[^=]*)?=\s*(?:async\s+)?(?:function\b|(?:<[^>]*>\s*)?\(|[A-Za-z_$][\w$]*\s*=>)`)
+)
+
+// nonMethodNames are statement keywords that look like calls inside class bodies.
+var nonMethodNames = map[string]struct{}{
+	"if": {}, "for": {}, "while": {}, "switch": {}, "catch": {}, "return": {},
+	"function": {}, "new": {}, "super": {}, "this": {}, "await": {}, "typeof": {},
+}
+
+type openSymbol struct {
+	index int
+	// closeDepth is the brace depth at which the declaration ends.
+	closeDepth int
+	// needsBody keeps declarations like `class A` open until their `{ ... }` body
+	// has been seen, even when the brace is on a later line.
+	needsBody bool
+	opened    bool
+}
+
+// Symbols extracts top-level declarations and class members using the
+// chunker's declaration matcher and line-oriented brace tracking. End lines are heuristic: a
+// declaration ends on the line where braces and parentheses return to the
+// depth it started at.
+func Symbols(path string, content []byte) []lang.Symbol {
+	normalized := textutil.NormalizeNewlinesString(string(content))
+	lines := strings.Split(normalized, "\n")
+
+	var symbols []lang.Symbol
+	var open []openSymbol
+	var braceDepth, parenDepth int
+	inBlockComment := false
+
+	for i, raw := range lines {
+		lineNum := uint32(i + 1)
+		trimmed := strings.TrimSpace(raw)
+		depthBefore := braceDepth
+		commentLine := inBlockComment || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*") || strings.HasPrefix(trimmed, "*")
+
+		if !commentLine && parenDepth == 0 {
+			var sym lang.Symbol
+			found := false
+			if depthBefore == 0 {
+				decl, ok := matchDeclaration(trimmed)
+				sym, found = decl.symbol, ok && decl.hasSymbol
+			} else if class, ok := innermostClass(symbols, open); ok && depthBefore == class.closeDepth+1 {
+				sym, found = classMember(trimmed, symbols[class.index])
+			}
+			if found {
+				sym.StartLine, sym.EndLine = lineNum, lineNum
+				symbols = append(symbols, sym)
+				open = append(open, openSymbol{
+					index:      len(symbols) - 1,
+					closeDepth: depthBefore,
+					needsBody:  sym.Kind != lang.KindVariable && sym.Kind != lang.KindType,
+				})
+			}
+		}
+
+		updateDepth(raw, &braceDepth, &parenDepth, &inBlockComment)
+
+		if n := len(open); n > 0 && (braceDepth > open[n-1].closeDepth || strings.Contains(trimmed, "{")) {
+			open[n-1].opened = true
+		}
+		for len(open) > 0 {
+			top := open[len(open)-1]
+			done := braceDepth < top.closeDepth ||
+				(braceDepth == top.closeDepth && parenDepth == 0 &&
+					(top.opened || !top.needsBody || strings.HasSuffix(trimmed, ";")))
+			if !done {
+				break
+			}
+			symbols[top.index].EndLine = lineNum
+			open = open[:len(open)-1]
+		}
+	}
+	for _, o := range open {
+		symbols[o.index].EndLine = uint32(len(lines))
+	}
+	return symbols
+}
+
+// innermostClass returns the open class whose body encloses the current line.
+func innermostClass(symbols []lang.Symbol, open []openSymbol) (openSymbol, bool) {
+	for i := len(open) - 1; i >= 0; i-- {
+		if symbols[open[i].index].Kind == lang.KindClass {
+			return open[i], true
+		}
+		if symbols[open[i].index].Kind != lang.KindMethod {
+			return openSymbol{}, false
+		}
+	}
+	return openSymbol{}, false
+}
+
+func classMember(trimmed string, class lang.Symbol) (lang.Symbol, bool) {
+	m := propertyArrowPattern.FindStringSubmatch(trimmed)
+	if m == nil {
+		m = methodPattern.FindStringSubmatch(trimmed)
+	}
+	if m == nil {
+		return lang.Symbol{}, false
+	}
+	name := m[2]
+	if _, skip := nonMethodNames[name]; skip {
+		return lang.Symbol{}, false
+	}
+	private := strings.HasPrefix(name, "#") || strings.Contains(m[1], "private") || strings.Contains(m[1], "protected")
+	return lang.Symbol{
+		Name:     name,
+		Kind:     lang.KindMethod,
+		Parent:   class.Name,
+		Exported: class.Exported && !private,
+	}, true
+}
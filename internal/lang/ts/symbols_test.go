@@ -0,0 +1,91 @@
+package ts
+
+import (
+	"testing"
+
+	"github.com/memkit/repodex/internal/lang"
+)
+
+func TestSymbolsDeclarationsAndMembers(t *testing.T) {
+	content := `import { db } from "./db";
+
+export class UserRepository {
+  private cache = new Map();
+
+  constructor(private readonly conn: Db) {}
+
+  async findById(id: string): Promise<User> {
+    if (this.cache.has(id)) {
+      return this.cache.get(id);
+    }
+    return db.query(id);
+  }
+
+  private evict(id: string) {
+    this.cache.delete(id);
+  }
+
+  handle = (event: Event) => {
+    console.log(event);
+  };
+}
+
+class Empty {}
+
+function helper() {
+  run(1);
+}
+
+export const makeRepo = (conn: Db) => new UserRepository(conn);
+const LIMIT = 10;
+export interface User {
+  id: string;
+}
+export type UserId = string;
+export enum Role {
+  Admin,
+}
+export default function () {}
+`
+	got := Symbols("repo.ts", []byte(content))
+	want := []lang.Symbol{
+		{Name: "UserRepository", Kind: lang.KindClass, Exported: true, StartLine: 3, EndLine: 22},
+		{Name: "constructor", Kind: lang.KindMethod, Parent: "UserRepository", Exported: true, StartLine: 6, EndLine: 6},
+		{Name: "findById", Kind: lang.KindMethod, Parent: "UserRepository", Exported: true, StartLine: 8, EndLine: 13},
+		{Name: "evict", Kind: lang.KindMethod, Parent: "UserRepository", StartLine: 15, EndLine: 17},
+		{Name: "handle", Kind: lang.KindMethod, Parent: "UserRepository", Exported: true, StartLine: 19, EndLine: 21},
+		{Name: "Empty", Kind: lang.KindClass, StartLine: 24, EndLine: 24},
+		{Name: "helper", Kind: lang.KindFunction, StartLine: 26, EndLine: 28},
+		{Name: "makeRepo", Kind: lang.KindFunction, Exported: true, StartLine: 30, EndLine: 30},
+		{Name: "LIMIT", Kind: lang.KindVariable, StartLine: 31, EndLine: 31},
+		{Name: "User", Kind: lang.KindInterface, Exported: true, StartLine: 32, EndLine: 34},
+		{Name: "UserId", Kind: lang.KindType, Exported: true, StartLine: 35, EndLine: 35},
+		{Name: "Role", Kind: lang.KindEnum, Exported: true, StartLine: 36, EndLine: 38},
+		{Name: "default", Kind: lang.KindFunction, Exported: true, StartLine: 39, EndLine: 39},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d symbols, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("symbol %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestSymbolsBraceOnNextLine(t *testing.T) {
+	content := "class Service\n{\n  start()\n  {\n    go();\n  }\n}\nfunction after() {}\n"
+	got := Symbols("svc.ts", []byte(content))
+	if len(got) != 3 {
+		t.Fatalf("expected 3 symbols, got %+v", got)
+	}
+	if got[0].Name != "Service" || got[0].EndLine != 7 {
+		t.Fatalf("unexpected class symbol %+v", got[0])
+	}
+	if got[1].Name != "start" || got[1].Parent != "Service" || got[1].StartLine != 3 || got[1].EndLine != 6 {
+		t.Fatalf("unexpected method symbol %+v", got[1])
+	}
+	if got[2].Name != "after" || got[2].StartLine != 8 {
+		t.Fatalf("unexpected function symbol %+v", got[2])
+	}
+}
@@ -0,0 +1,235 @@
+// Package lockx implements advisory lock files that record their owner, so
+// a lock left behind by a crashed process can be recognised and broken.
+package lockx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/memkit/repodex/internal/procx"
+)
+
+const (
+	// pollInterval is how often AcquireWait retries a held lock.
+	pollInterval = 100 * time.Millisecond
+	// unreadableLockAge is how long a lock file may stay unparseable; a new
+	// lock is empty between its creation and the write of its owner.
+	unreadableLockAge = time.Minute
+	// refreshInterval is how often a holder touches its lock file, and
+	// foreignLockAge how long a lock from another host, whose owner cannot
+	// be checked, stays held without being touched.
+	refreshInterval = time.Minute
+	foreignLockAge  = 10 * time.Minute
+	// breakGuardSuffix names the file that serialises breaking a stale lock.
+	// It is held for a few system calls only, so one older than
+	// abandonedGuardAge was left by a crashed process.
+	breakGuardSuffix  = ".break"
+	abandonedGuardAge = 10 * time.Second
+	guardPollInterval = 10 * time.Millisecond
+)
+
+// Owner identifies the process holding a lock.
+type Owner struct {
+	PID         int    `json:"pid"`
+	Host        string `json:"host"`
+	StartedUnix int64  `json:"started_unix"`
+}
+
+// HeldError reports a lock held by another live process, or by a process on
+// another host that touched it within foreignLockAge.
+type HeldError struct {
+	Path  string
+	Owner Owner
+}
+
+func (e *HeldError) Error() string {
+	if e.Owner.PID == 0 {
+		return fmt.Sprintf("%s is held by another process", e.Path)
+	}
+	return fmt.Sprintf("%s is held by pid %d on %s since %s", e.Path, e.Owner.PID, e.Owner.Host,
+		time.Unix(e.Owner.StartedUnix, 0).Format(time.RFC3339))
+}
+
+// Lock is a held lock file. Until released, it touches the file every
+// refreshInterval so that other hosts keep seeing it as held.
+type Lock struct {
+	path string
+	data []byte
+	stop chan struct{}
+	done chan struct{}
+}
+
+// TryAcquire creates the lock file at path, returning *HeldError at once
+// when a live owner holds it. A stale lock, whose owner exited on this host
+// or, on another host, stopped touching it, is removed and taken over.
+func TryAcquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create lock dir: %w", err)
+	}
+	data, err := json.Marshal(Owner{PID: os.Getpid(), Host: procx.Hostname(), StartedUnix: time.Now().Unix()})
+	if err != nil {
+		return nil, err
+	}
+	// Two attempts: the second follows breaking a stale lock.
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			if _, err := f.Write(data); err != nil {
+				f.Close()
+				os.Remove(path)
+				return nil, fmt.Errorf("write lock: %w", err)
+			}
+			if err := f.Close(); err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("write lock: %w", err)
+			}
+			// Read the owner back: a lock broken in between is not ours.
+			if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, data) {
+				owner, _, _ := inspect(path)
+				return nil, &HeldError{Path: path, Owner: owner}
+			}
+			lock := &Lock{path: path, data: data, stop: make(chan struct{}), done: make(chan struct{})}
+			go lock.refresh()
+			return lock, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("create lock: %w", err)
+		}
+		owner, held, err := inspect(path)
+		if err != nil {
+			return nil, err
+		}
+		if held {
+			return nil, &HeldError{Path: path, Owner: owner}
+		}
+		if err := breakStale(path); err != nil {
+			return nil, err
+		}
+	}
+	owner, _, _ := inspect(path)
+	return nil, &HeldError{Path: path, Owner: owner}
+}
+
+// AcquireWait is TryAcquire retried until the lock is free. onWait, if set,
+// is called once with the owner when the first attempt finds the lock held.
+func AcquireWait(path string, onWait func(Owner)) (*Lock, error) {
+	notified := false
+	for {
+		lock, err := TryAcquire(path)
+		var held *HeldError
+		if !errors.As(err, &held) {
+			return lock, err
+		}
+		if !notified && onWait != nil {
+			onWait(held.Owner)
+			notified = true
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Holder reports the owner of the lock at path when a live process holds it.
+func Holder(path string) (Owner, bool, error) {
+	return inspect(path)
+}
+
+// inspect reads the lock at path and reports whether it is held. A missing
+// lock is not held; an unreadable one is held until it is old enough to have
+// been abandoned mid-write, and one from another host until it goes
+// foreignLockAge without being touched.
+func inspect(path string) (Owner, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Owner{}, false, nil
+	}
+	if err != nil {
+		return Owner{}, false, fmt.Errorf("read lock: %w", err)
+	}
+	var owner Owner
+	if err := json.Unmarshal(data, &owner); err != nil {
+		fi, statErr := os.Stat(path)
+		if statErr != nil {
+			return Owner{}, false, nil
+		}
+		return Owner{}, time.Since(fi.ModTime()) <= unreadableLockAge, nil
+	}
+	if owner.Host == procx.Hostname() {
+		return owner, procx.Alive(owner.PID), nil
+	}
+	// Liveness of processes on other hosts cannot be checked; their holders
+	// touch the lock instead.
+	fi, err := os.Stat(path)
+	if err != nil {
+		return owner, false, nil
+	}
+	return owner, time.Since(fi.ModTime()) <= foreignLockAge, nil
+}
+
+// breakStale removes a stale lock. Breakers take turns through a guard file
+// and re-inspect the lock under it, so a lock that another breaker already
+// replaced with a live one is never removed. Live locks are only ever
+// removed by their owner.
+func breakStale(path string) error {
+	guard := path + breakGuardSuffix
+	for {
+		f, err := os.OpenFile(guard, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			f.Close()
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("break stale lock: %w", err)
+		}
+		if fi, err := os.Stat(guard); err == nil && time.Since(fi.ModTime()) > abandonedGuardAge {
+			_ = os.Remove(guard)
+			continue
+		}
+		time.Sleep(guardPollInterval)
+	}
+	defer os.Remove(guard)
+	_, held, err := inspect(path)
+	if err != nil || held {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("break stale lock: %w", err)
+	}
+	return nil
+}
+
+// refresh touches the lock file until the lock is released.
+func (l *Lock) refresh() {
+	defer close(l.done)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if data, err := os.ReadFile(l.path); err == nil && bytes.Equal(data, l.data) {
+				now := time.Now()
+				_ = os.Chtimes(l.path, now, now)
+			}
+		}
+	}
+}
+
+// Release removes the lock file if it still belongs to this lock. It is safe
+// on a nil lock and when called twice.
+func (l *Lock) Release() {
+	if l == nil || l.path == "" {
+		return
+	}
+	close(l.stop)
+	<-l.done
+	if data, err := os.ReadFile(l.path); err == nil && bytes.Equal(data, l.data) {
+		_ = os.Remove(l.path)
+	}
+	l.path = ""
+}
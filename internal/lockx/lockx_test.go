@@ -0,0 +1,168 @@
+package lockx
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/memkit/repodex/internal/procx"
+)
+
+func TestTryAcquireHeldAndRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+
+	lock, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("TryAcquire: %v", err)
+	}
+	_, err = TryAcquire(path)
+	var held *HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("expected HeldError, got %v", err)
+	}
+	if held.Owner.PID != os.Getpid() || held.Owner.Host != procx.Hostname() {
+		t.Fatalf("unexpected owner %+v", held.Owner)
+	}
+	if owner, ok, err := Holder(path); err != nil || !ok || owner.PID != os.Getpid() {
+		t.Fatalf("Holder = %+v, %v, %v", owner, ok, err)
+	}
+
+	lock.Release()
+	lock.Release()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected lock file to be removed, got %v", err)
+	}
+	again, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("TryAcquire after release: %v", err)
+	}
+	again.Release()
+}
+
+func TestTryAcquireBreaksStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	data, _ := json.Marshal(Owner{PID: 1 << 30, Host: procx.Hostname(), StartedUnix: time.Now().Unix()})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := Holder(path); err != nil || ok {
+		t.Fatalf("expected stale lock not to be held, got %v, %v", ok, err)
+	}
+
+	lock, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("TryAcquire over stale lock: %v", err)
+	}
+	defer lock.Release()
+	if owner, ok, _ := Holder(path); !ok || owner.PID != os.Getpid() {
+		t.Fatalf("expected lock to be taken over, got %+v", owner)
+	}
+}
+
+func TestTryAcquireRespectsOtherHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	data, _ := json.Marshal(Owner{PID: 1 << 30, Host: procx.Hostname() + "-other", StartedUnix: time.Now().Unix()})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := TryAcquire(path)
+	var held *HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("expected a lock from another host to be held, got %v", err)
+	}
+}
+
+func TestTryAcquireBreaksUntouchedForeignLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	data, _ := json.Marshal(Owner{PID: 1 << 30, Host: procx.Hostname() + "-other", StartedUnix: time.Now().Unix()})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-foreignLockAge - time.Minute)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("expected a foreign lock past its age to be broken, got %v", err)
+	}
+	lock.Release()
+}
+
+func TestTryAcquireStaleLockHasOneWinner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	data, _ := json.Marshal(Owner{PID: 1 << 30, Host: procx.Hostname(), StartedUnix: time.Now().Unix()})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const racers = 8
+	locks := make(chan *Lock, racers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			lock, err := TryAcquire(path)
+			var held *HeldError
+			if err != nil && !errors.As(err, &held) {
+				t.Errorf("TryAcquire: %v", err)
+			}
+			if lock != nil {
+				locks <- lock
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(locks)
+	won := 0
+	for lock := range locks {
+		won++
+		lock.Release()
+	}
+	if won != 1 {
+		t.Fatalf("expected exactly one holder of the broken lock, got %d", won)
+	}
+}
+
+func TestAcquireWaitReturnsOnceReleased(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	lock, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("TryAcquire: %v", err)
+	}
+
+	waiting := make(chan Owner, 1)
+	done := make(chan error, 1)
+	go func() {
+		second, err := AcquireWait(path, func(owner Owner) { waiting <- owner })
+		second.Release()
+		done <- err
+	}()
+
+	select {
+	case owner := <-waiting:
+		if owner.PID != os.Getpid() {
+			t.Fatalf("unexpected owner %+v", owner)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("AcquireWait did not report the held lock")
+	}
+	lock.Release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("AcquireWait: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("AcquireWait did not acquire the released lock")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package mmapx
+
+import "os"
+
+// Open reads the file at path into memory, as mapping is not implemented on
+// this platform.
+func Open(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{data: data}, nil
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package mmapx
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Open maps the file at path. The file must not be truncated or rewritten in
+// place while mapped; index artifacts never are once published.
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size == 0 {
+		// Empty files cannot be mapped.
+		return &File{}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("map %s: %d bytes is too large", path, size)
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("map %s: %w", path, err)
+	}
+	return &File{data: data, unmap: func() error { return syscall.Munmap(data) }}, nil
+}
@@ -0,0 +1,28 @@
+// Package mmapx maps files read-only into memory, falling back to reading
+// them where mapping is unavailable.
+package mmapx
+
+// File is the read-only contents of a file. The bytes must not be used after
+// Close.
+type File struct {
+	data  []byte
+	unmap func() error
+}
+
+// Bytes returns the file contents.
+func (f *File) Bytes() []byte {
+	if f == nil {
+		return nil
+	}
+	return f.data
+}
+
+// Close releases the mapping. It is safe on a nil File and when called twice.
+func (f *File) Close() error {
+	if f == nil || f.unmap == nil {
+		return nil
+	}
+	unmap := f.unmap
+	f.data, f.unmap = nil, nil
+	return unmap()
+}
@@ -0,0 +1,40 @@
+package mmapx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMapsContents(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data")
+	if err := os.WriteFile(path, []byte("mapped"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if string(f.Bytes()) != "mapped" {
+		t.Fatalf("unexpected contents %q", f.Bytes())
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := f.Close(); err != nil || f.Bytes() != nil {
+		t.Fatalf("second Close: %v, bytes %q", err, f.Bytes())
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err = Open(empty)
+	if err != nil || len(f.Bytes()) != 0 {
+		t.Fatalf("expected an empty file, got %q, %v", f.Bytes(), err)
+	}
+	if _, err := Open(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist, got %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package procx
+
+// Alive reports whether a process with the given PID exists on this host.
+// Without a way to check, every positive PID is assumed alive so callers
+// never reclaim something still in use.
+func Alive(pid int) bool {
+	return pid > 0
+}
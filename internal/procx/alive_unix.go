@@ -0,0 +1,18 @@
+//go:build unix
+
+package procx
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Alive reports whether a process with the given PID exists on this host.
+func Alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	// EPERM means the process exists but belongs to another user.
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
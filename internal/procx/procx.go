@@ -0,0 +1,14 @@
+// Package procx answers questions about other processes on this host.
+package procx
+
+import "os"
+
+// Hostname returns the host name recorded next to PIDs in lock and lease
+// files, or "" when it cannot be determined.
+func Hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}
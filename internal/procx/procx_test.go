@@ -0,0 +1,19 @@
+package procx
+
+import (
+	"os"
+	"testing"
+)
+
+func TestAlive(t *testing.T) {
+	if !Alive(os.Getpid()) {
+		t.Fatalf("expected the current process to be alive")
+	}
+	if Alive(0) || Alive(-1) {
+		t.Fatalf("expected non-positive pids to be reported dead")
+	}
+	// Above the Linux pid_max ceiling, so never a live process there.
+	if Alive(1 << 30) {
+		t.Fatalf("expected an unused pid to be reported dead")
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/memkit/repodex/internal/config"
 	"github.com/memkit/repodex/internal/hash"
+	"github.com/memkit/repodex/internal/ignore"
 )
 
 // BuildEffectiveRules merges global defaults, profile rules, and user overrides.
@@ -20,18 +21,44 @@ func BuildEffectiveRules(root string, cfg config.Config) (EffectiveRules, error)
 		return EffectiveRules{}, err
 	}
 
-	scanIgnore := append([]string{}, GlobalScanIgnore(detected.HasPackageJSON)...)
-	for _, p := range detected.Profiles {
-		if rules := p.Rules(); len(rules.ScanIgnore) > 0 {
-			scanIgnore = append(scanIgnore, rules.ScanIgnore...)
+	var scanIgnore []string
+	var sources []IgnorePattern
+	add := func(source string, patterns ...string) {
+		for _, p := range patterns {
+			scanIgnore = append(scanIgnore, p)
+			sources = append(sources, IgnorePattern{Pattern: p, Source: source})
 		}
 	}
+	add(IgnoreSourceGlobal, GlobalScanIgnore(detected.HasPackageJSON)...)
+	for _, p := range detected.Profiles {
+		add(IgnoreSourceProfilePrefix+p.ID(), p.Rules().ScanIgnore...)
+	}
+	add(IgnoreSourceExcludeDirs, dirPatterns(cfg.ExcludeDirs)...)
+	if dirs, err := ignore.LoadDirs(filepath.Join(root, ".repodex", "ignore")); err == nil {
+		add(IgnoreSourceRepodexIgnore, dirPatterns(dirs)...)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return EffectiveRules{}, fmt.Errorf("load .repodex/ignore: %w", err)
+	}
 	if userPatterns, err := loadScanIgnore(root); err == nil {
-		scanIgnore = append(scanIgnore, userPatterns...)
+		add(IgnoreSourceScanIgnore, userPatterns...)
 	} else if !errors.Is(err, os.ErrNotExist) {
 		return EffectiveRules{}, fmt.Errorf("load .scanignore: %w", err)
 	}
 
+	var gitExclude []string
+	if cfg.Scan.UseGitignore {
+		lines, err := loadGitExclude(root)
+		if err != nil {
+			return EffectiveRules{}, fmt.Errorf("load .git/info/exclude: %w", err)
+		}
+		for _, line := range lines {
+			if _, ok := ignore.ParsePattern(line, ""); ok {
+				gitExclude = append(gitExclude, line)
+				sources = append(sources, IgnorePattern{Pattern: line, Source: IgnoreSourceGitExclude})
+			}
+		}
+	}
+
 	tokenRules := mergeTokenRules(cfg.Token, detected.Profiles)
 	userToken, err := loadTokenizeOverride(root)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -46,15 +73,18 @@ func BuildEffectiveRules(root string, cfg config.Config) (EffectiveRules, error)
 	}
 	scanSettings := ScanSettings{
 		MaxTextFileSizeBytes: cfg.Scan.MaxTextFileSizeBytes,
+		UseGitignore:         cfg.Scan.UseGitignore,
 	}
 
-	rulesHash, err := computeRulesHash(detectedIDs, scanIgnore, scanSettings, tokenRules)
+	rulesHash, err := computeRulesHash(detectedIDs, scanIgnore, gitExclude, scanSettings, tokenRules)
 	if err != nil {
 		return EffectiveRules{}, err
 	}
 
 	return EffectiveRules{
 		ScanIgnore:       scanIgnore,
+		IgnoreSources:    sources,
+		GitExclude:       gitExclude,
 		Tokenize:         tokenRules,
 		TokenConfig:      tokenCfg,
 		DetectedProfiles: detectedIDs,
@@ -197,6 +227,43 @@ func (t TokenizeRules) ToConfig(base config.TokenizationConfig) config.Tokenizat
 	return out
 }
 
+// dirPatterns turns directory names from ExcludeDirs or .repodex/ignore into
+// directory-only scan patterns, which match the directory at any depth.
+func dirPatterns(dirs []string) []string {
+	var patterns []string
+	for _, dir := range dirs {
+		dir = filepath.ToSlash(strings.TrimSpace(dir))
+		negate := strings.HasPrefix(dir, "!")
+		dir = strings.Trim(strings.TrimPrefix(dir, "!"), "/")
+		if dir == "" {
+			continue
+		}
+		if strings.Contains(dir, "/") {
+			// A slash would anchor the pattern to the root.
+			dir = "**/" + dir
+		}
+		if negate {
+			dir = "!" + dir
+		}
+		patterns = append(patterns, dir+"/")
+	}
+	return patterns
+}
+
+// loadGitExclude reads .git/info/exclude. A missing file, or a .git file
+// pointing elsewhere (worktrees, submodules), yields no patterns.
+func loadGitExclude(root string) ([]string, error) {
+	info, err := os.Stat(filepath.Join(root, ".git"))
+	if err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	lines, err := ignore.ReadPatterns(filepath.Join(root, ".git", "info", "exclude"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return lines, err
+}
+
 func loadScanIgnore(root string) ([]string, error) {
 	path := filepath.Join(root, ".scanignore")
 	data, err := os.ReadFile(path)
@@ -215,17 +282,19 @@ func loadScanIgnore(root string) ([]string, error) {
 	return patterns, nil
 }
 
-func computeRulesHash(profiles []string, scanIgnore []string, scanSettings ScanSettings, tokenize TokenizeRules) (uint64, error) {
+func computeRulesHash(profiles []string, scanIgnore []string, gitExclude []string, scanSettings ScanSettings, tokenize TokenizeRules) (uint64, error) {
 	state := struct {
 		SchemaVersion int
 		Profiles      []string
 		ScanIgnore    []string
+		GitExclude    []string `json:",omitempty"`
 		ScanSettings  ScanSettings
 		Tokenize      TokenizeRules
 	}{
 		SchemaVersion: SchemaVersion,
 		Profiles:      append([]string(nil), profiles...),
 		ScanIgnore:    append([]string(nil), scanIgnore...),
+		GitExclude:    append([]string(nil), gitExclude...),
 		ScanSettings:  scanSettings,
 		Tokenize:      tokenize,
 	}
@@ -236,34 +305,3 @@ func computeRulesHash(profiles []string, scanIgnore []string, scanSettings ScanS
 	}
 	return hash.Sum64(bytes), nil
 }
-
-// GlobMatch wraps doublestar for deterministic behavior.
-func GlobMatch(pattern, path string) (bool, error) {
-	patternParts := strings.Split(pattern, "/")
-	pathParts := strings.Split(path, "/")
-	match := matchSegments(patternParts, pathParts)
-	return match, nil
-}
-
-func matchSegments(patternParts, pathParts []string) bool {
-	if len(patternParts) == 0 {
-		return len(pathParts) == 0
-	}
-	head := patternParts[0]
-	if head == "**" {
-		for i := 0; i <= len(pathParts); i++ {
-			if matchSegments(patternParts[1:], pathParts[i:]) {
-				return true
-			}
-		}
-		return false
-	}
-	if len(pathParts) == 0 {
-		return false
-	}
-	ok, err := filepath.Match(head, pathParts[0])
-	if err != nil || !ok {
-		return false
-	}
-	return matchSegments(patternParts[1:], pathParts[1:])
-}
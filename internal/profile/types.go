@@ -48,7 +48,13 @@ func (c DetectContext) Join(parts ...string) string {
 
 // EffectiveRules represents the merged scan and tokenization rules.
 type EffectiveRules struct {
-	ScanIgnore       []string
+	ScanIgnore []string
+	// GitExclude holds the .git/info/exclude lines when ScanSettings.UseGitignore
+	// is set; nested .gitignore files are read while scanning.
+	GitExclude []string
+	// IgnoreSources pairs each ScanIgnore and GitExclude pattern with where it
+	// came from.
+	IgnoreSources    []IgnorePattern
 	Tokenize         TokenizeRules
 	TokenConfig      config.TokenizationConfig
 	DetectedProfiles []string
@@ -56,7 +62,27 @@ type EffectiveRules struct {
 	RulesHash        uint64
 }
 
+// IgnorePattern is a scan ignore pattern and the source that contributed it.
+type IgnorePattern struct {
+	Pattern string `json:"pattern"`
+	Source  string `json:"source"`
+}
+
+// Ignore pattern sources, in the order their patterns are applied; later
+// patterns (including "!" negations) override earlier ones.
+const (
+	IgnoreSourceGlobal        = "global"
+	IgnoreSourceProfilePrefix = "profile:"
+	IgnoreSourceExcludeDirs   = "config:ExcludeDirs"
+	IgnoreSourceRepodexIgnore = ".repodex/ignore"
+	IgnoreSourceScanIgnore    = ".scanignore"
+	IgnoreSourceGitExclude    = ".git/info/exclude"
+)
+
 // ScanSettings captures scan-level knobs.
 type ScanSettings struct {
 	MaxTextFileSizeBytes int64
+	// UseGitignore is omitted from the rules hash when off so enabling it is
+	// the only change that invalidates existing indexes.
+	UseGitignore bool `json:",omitempty"`
 }
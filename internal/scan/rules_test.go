@@ -3,6 +3,7 @@ package scan
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/memkit/repodex/internal/config"
@@ -177,6 +178,132 @@ func TestPackageLockIgnoreWithOverride(t *testing.T) {
 	}
 }
 
+func TestExcludeDirsAndRepodexIgnore(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{"src/a.ts", "src/gen/b.ts", "vendor/c.ts", "pkg/vendor/d.ts", "tmp/e.ts"} {
+		full := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte("const a = 1"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, ".repodex"), 0o755); err != nil {
+		t.Fatalf("mkdir .repodex: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".repodex", "ignore"), []byte("# generated\nsrc/gen/\ntmp\n"), 0o644); err != nil {
+		t.Fatalf("write ignore: %v", err)
+	}
+	cfg := newTestConfig()
+	cfg.IncludeExt = []string{".ts"}
+	cfg.ExcludeDirs = []string{"vendor"}
+
+	rules, err := profile.BuildEffectiveRules(root, cfg)
+	if err != nil {
+		t.Fatalf("rules: %v", err)
+	}
+	results, err := Walk(root, cfg, rules)
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != "src/a.ts" {
+		t.Fatalf("expected only src/a.ts, got %+v", results)
+	}
+
+	want := map[string]string{
+		"vendor/":     profile.IgnoreSourceExcludeDirs,
+		"**/src/gen/": profile.IgnoreSourceRepodexIgnore,
+		"tmp/":        profile.IgnoreSourceRepodexIgnore,
+		".git/":       profile.IgnoreSourceGlobal,
+	}
+	for _, p := range rules.IgnoreSources {
+		if src, ok := want[p.Pattern]; ok && src == p.Source {
+			delete(want, p.Pattern)
+		}
+	}
+	if len(want) != 0 {
+		t.Fatalf("missing pattern sources %v in %+v", want, rules.IgnoreSources)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, ".repodex", "ignore"), []byte("src/gen/\n"), 0o644); err != nil {
+		t.Fatalf("rewrite ignore: %v", err)
+	}
+	edited, err := profile.BuildEffectiveRules(root, cfg)
+	if err != nil {
+		t.Fatalf("rules after edit: %v", err)
+	}
+	if edited.RulesHash == rules.RulesHash {
+		t.Fatalf("expected rules hash to change after .repodex/ignore edit")
+	}
+	cfg.ExcludeDirs = nil
+	noExclude, err := profile.BuildEffectiveRules(root, cfg)
+	if err != nil {
+		t.Fatalf("rules without ExcludeDirs: %v", err)
+	}
+	if noExclude.RulesHash == edited.RulesHash {
+		t.Fatalf("expected rules hash to change with ExcludeDirs")
+	}
+}
+
+func TestGitignoreRules(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":                  "*.gen.ts\n/local.ts\n",
+		".git/info/exclude":           "scratch/\n",
+		"a.ts":                        "",
+		"local.ts":                    "",
+		"api.gen.ts":                  "",
+		"src/local.ts":                "",
+		"src/client.gen.ts":           "",
+		"scratch/tmp.ts":              "",
+		"packages/web/.gitignore":     "out/\n!keep.gen.ts\n",
+		"packages/web/keep.gen.ts":    "",
+		"packages/web/out/bundle.ts":  "",
+		"packages/api/out/handler.ts": "",
+	}
+	for rel, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	cfg := newTestConfig()
+	cfg.IncludeExt = []string{".ts"}
+	cfg.ExcludeDirs = nil
+
+	walk := func() []string {
+		t.Helper()
+		rules, err := profile.BuildEffectiveRules(root, cfg)
+		if err != nil {
+			t.Fatalf("rules: %v", err)
+		}
+		results, err := Walk(root, cfg, rules)
+		if err != nil {
+			t.Fatalf("walk: %v", err)
+		}
+		var paths []string
+		for _, r := range results {
+			paths = append(paths, r.Path)
+		}
+		return paths
+	}
+
+	if got := walk(); len(got) != 9 {
+		t.Fatalf("expected git ignore rules to have no effect while UseGitignore is off, got %v", got)
+	}
+
+	cfg.Scan.UseGitignore = true
+	got := walk()
+	want := []string{"a.ts", "packages/api/out/handler.ts", "packages/web/keep.gen.ts", "src/local.ts"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
 func TestRulesHashInvalidation(t *testing.T) {
 	root := t.TempDir()
 	cfg := newTestConfig()
@@ -1,6 +1,8 @@
 package scan
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/memkit/repodex/internal/config"
 	"github.com/memkit/repodex/internal/hash"
+	"github.com/memkit/repodex/internal/ignore"
 	"github.com/memkit/repodex/internal/profile"
 	"github.com/memkit/repodex/internal/textutil"
 )
@@ -108,10 +111,55 @@ type candidate struct {
 	info    fs.FileInfo
 }
 
+// pathFilter combines the repodex scan rules with git's ignore rules. A scan
+// rule matching a path decides; otherwise git's rules do, when enabled.
+type pathFilter struct {
+	scanRules *ignore.Matcher
+	git       *ignore.Matcher
+}
+
+func newPathFilter(root string, rules profile.EffectiveRules) (*pathFilter, error) {
+	f := &pathFilter{scanRules: ignore.NewMatcher(rules.ScanIgnore)}
+	if rules.ScanSettings.UseGitignore {
+		f.git = ignore.NewMatcher(rules.GitExclude)
+		if err := f.addGitignore(root, ""); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// addGitignore loads dir/.gitignore, whose patterns apply below rel.
+func (f *pathFilter) addGitignore(dir, rel string) error {
+	if f.git == nil {
+		return nil
+	}
+	lines, err := ignore.ReadPatterns(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("load %s: %w", filepath.ToSlash(filepath.Join(rel, ".gitignore")), err)
+	}
+	f.git.Add(rel, lines)
+	return nil
+}
+
+func (f *pathFilter) ignored(rel string, isDir bool) bool {
+	if ignored, matched := f.scanRules.Match(rel, isDir); matched {
+		return ignored
+	}
+	ignored, _ := f.git.Match(rel, isDir)
+	return ignored
+}
+
 func collect(root string, cfg config.Config, rules profile.EffectiveRules) ([]candidate, error) {
-	matcher := newIgnoreMatcher(rules.ScanIgnore)
+	filter, err := newPathFilter(root, rules)
+	if err != nil {
+		return nil, err
+	}
 	var candidates []candidate
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -130,14 +178,16 @@ func collect(root string, cfg config.Config, rules profile.EffectiveRules) ([]ca
 		}
 
 		if d.IsDir() {
-			if matcher.shouldIgnore(rel, true) {
+			if filter.ignored(rel, true) {
 				return filepath.SkipDir
 			}
-			return nil
+			// WalkDir visits a directory before its entries, so its .gitignore
+			// is in place before they are matched.
+			return filter.addGitignore(path, rel)
 		}
 
 		lowerRel := strings.ToLower(rel)
-		if matcher.shouldIgnore(rel, false) {
+		if filter.ignored(rel, false) {
 			return nil
 		}
 		if profile.IsKnownBinaryExt(lowerRel) {
@@ -0,0 +1,119 @@
+package search
+
+import "sort"
+
+// collapseOverlapping merges results on overlapping chunks of the same file.
+// results may be in any order. Each group is reported once, at the rank of
+// its best-ranked member, which keeps its chunk id, score, snippet and span; the
+// range widens to cover every member, ChunkIDs lists them in line order, and
+// Why and Phrases gain the members' extra terms.
+func collapseOverlapping(results []Result) []Result {
+	byPath := make(map[string][]int)
+	for i, r := range results {
+		byPath[r.Path] = append(byPath[r.Path], i)
+	}
+	// group[i] is the index of the best-ranked result in i's overlap group.
+	// The output keeps the input order of the groups' best members.
+	group := make([]int, len(results))
+	for i := range group {
+		group[i] = i
+	}
+	for _, idx := range byPath {
+		if len(idx) < 2 {
+			continue
+		}
+		sort.Slice(idx, func(a, b int) bool {
+			ra, rb := results[idx[a]], results[idx[b]]
+			if ra.StartLine != rb.StartLine {
+				return ra.StartLine < rb.StartLine
+			}
+			return ra.EndLine < rb.EndLine
+		})
+		runStart := 0
+		runEnd := results[idx[0]].EndLine
+		for k := 1; k <= len(idx); k++ {
+			if k < len(idx) && results[idx[k]].StartLine <= runEnd {
+				if results[idx[k]].EndLine > runEnd {
+					runEnd = results[idx[k]].EndLine
+				}
+				continue
+			}
+			best := idx[runStart]
+			for _, i := range idx[runStart:k] {
+				if ranksBefore(results[i], results[best]) {
+					best = i
+				}
+			}
+			for _, i := range idx[runStart:k] {
+				group[i] = best
+			}
+			if k < len(idx) {
+				runStart, runEnd = k, results[idx[k]].EndLine
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	for i, g := range group {
+		if g != i {
+			members[g] = append(members[g], i)
+		}
+	}
+	for _, m := range members {
+		sort.Slice(m, func(a, b int) bool { return ranksBefore(results[m[a]], results[m[b]]) })
+	}
+	out := make([]Result, 0, len(results))
+	for i, r := range results {
+		if group[i] != i {
+			continue
+		}
+		if len(members[i]) > 0 {
+			r = mergeGroup(r, results, members[i])
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func mergeGroup(best Result, results []Result, others []int) Result {
+	merged := best
+	merged.Why = append([]string{}, best.Why...)
+	merged.Phrases = append([]string(nil), best.Phrases...)
+	seenWhy := make(map[string]struct{}, len(best.Why))
+	for _, t := range best.Why {
+		seenWhy[t] = struct{}{}
+	}
+	seenPhrase := make(map[string]struct{}, len(best.Phrases))
+	for _, p := range best.Phrases {
+		seenPhrase[p] = struct{}{}
+	}
+	all := []Result{best}
+	for _, i := range others {
+		r := results[i]
+		all = append(all, r)
+		if r.StartLine < merged.StartLine {
+			merged.StartLine = r.StartLine
+		}
+		if r.EndLine > merged.EndLine {
+			merged.EndLine = r.EndLine
+		}
+		for _, t := range r.Why {
+			if _, ok := seenWhy[t]; !ok {
+				seenWhy[t] = struct{}{}
+				merged.Why = append(merged.Why, t)
+			}
+		}
+		for _, p := range r.Phrases {
+			if _, ok := seenPhrase[p]; !ok {
+				seenPhrase[p] = struct{}{}
+				merged.Phrases = append(merged.Phrases, p)
+			}
+		}
+	}
+	sort.Slice(all, func(a, b int) bool { return all[a].StartLine < all[b].StartLine })
+	merged.ChunkIDs = make([]uint32, 0, len(all))
+	for _, r := range all {
+		merged.ChunkIDs = append(merged.ChunkIDs, r.ChunkID)
+	}
+	return merged
+}
@@ -0,0 +1,60 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/memkit/repodex/internal/hash"
+)
+
+// cursor marks the last result of a page. It is bound to the query text, the
+// per-file setting and collapsing because all of them change the filtered
+// ranking, and to the index generation because a sync renumbers chunks and
+// rescores them.
+type cursor struct {
+	Query      string  `json:"q"`
+	Generation string  `json:"g,omitempty"`
+	Score      float64 `json:"s"`
+	ChunkID    uint32  `json:"c"`
+}
+
+func queryKey(q string, maxPerFile int, collapse bool) string {
+	payload := []byte(q + "\x00" + strconv.Itoa(maxPerFile))
+	if collapse {
+		payload = append(payload, "\x00collapse"...)
+	}
+	return strconv.FormatUint(hash.Sum64(payload), 16)
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string, key string, generation string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return cursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if c.Query != key {
+		return cursor{}, fmt.Errorf("invalid cursor: issued for a different query")
+	}
+	if c.Generation != generation {
+		return cursor{}, fmt.Errorf("invalid cursor: issued for index generation %q but the current one is %q; repeat the query without a cursor", c.Generation, generation)
+	}
+	return c, nil
+}
+
+// after reports whether r ranks strictly below the cursor position.
+func (c cursor) after(r Result) bool {
+	if r.Score != c.Score {
+		return r.Score < c.Score
+	}
+	return r.ChunkID > c.ChunkID
+}
@@ -0,0 +1,253 @@
+package search
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/fetch"
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/tokenize"
+)
+
+// Highlight is a chunk line containing hits for the result's matched terms.
+type Highlight struct {
+	Line uint32 `json:"line"`
+	Text string `json:"text"`
+	// From is the byte offset of Text in the source line when a long line was cropped.
+	From int   `json:"from,omitempty"`
+	Hits []Hit `json:"hits"`
+}
+
+// Hit is the byte range [Start, End) of a matched term within Highlight.Text.
+type Hit struct {
+	Term  string `json:"term"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+const (
+	// maxHighlights is the number of best-matching lines kept per result.
+	maxHighlights = 3
+	// snippetWindow is the number of lines considered for the match-centred snippet.
+	snippetWindow = 3
+)
+
+// HighlightResults re-scans the files behind results, attaches the best-matching lines
+// with term byte ranges, and re-centres each Snippet on the densest window of
+// matched terms. Files are read as they were indexed, like fetch reads them:
+// a file changed since the sync is scanned from its snapshot in snapshotsPath,
+// and keeps its indexed snippet without highlights when the index has no
+// snapshot of it or it can no longer be read.
+func HighlightResults(root string, cfg config.Config, files []index.FileEntry, snapshotsPath string, results []Result) error {
+	if len(results) == 0 {
+		return nil
+	}
+	rootReal, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("resolve root: %w", err)
+	}
+	tokenizer := tokenize.New(cfg.Token)
+	maxBytes := cfg.Limits.MaxSnippetBytes
+
+	sources := make(map[string][]string)
+	for i := range results {
+		r := &results[i]
+		if len(r.Why) == 0 {
+			continue
+		}
+		lines, ok := sources[r.Path]
+		if !ok {
+			lines = indexedLines(rootReal, files, snapshotsPath, r.Path)
+			sources[r.Path] = lines
+		}
+		if lines == nil {
+			continue
+		}
+		highlightResult(r, lines, tokenizer, cfg.Token.TokenizeStringLiterals, maxBytes)
+	}
+	return nil
+}
+
+// indexedLines returns the lines of path as indexed, or nil when they are not
+// available.
+func indexedLines(rootReal string, files []index.FileEntry, snapshotsPath string, path string) []string {
+	fe, ok := index.FileByPath(files, path)
+	if !ok {
+		return nil
+	}
+	lines, stale, source, err := fetch.IndexedLines(rootReal, files, snapshotsPath, fe.FileID, path)
+	if err != nil || (stale && source != fetch.SourceSnapshot) {
+		return nil
+	}
+	return lines
+}
+
+type lineHits struct {
+	line     int
+	hits     []tokenize.Span
+	distinct int
+}
+
+func highlightResult(r *Result, lines []string, tokenizer tokenize.Tokenizer, stringsTokenized bool, maxBytes int) {
+	wanted := make(map[string]struct{}, len(r.Why))
+	for _, term := range r.Why {
+		wanted[term] = struct{}{}
+	}
+	start := int(r.StartLine)
+	if start < 1 {
+		start = 1
+	}
+	end := int(r.EndLine)
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return
+	}
+
+	// String-literal state depends on everything above the chunk when literals are skipped.
+	scanFrom := start
+	if !stringsTokenized {
+		scanFrom = 1
+	}
+	st := &tokenize.StringScanState{}
+	perLine := make([]lineHits, 0, end-start+1)
+	for ln := scanFrom; ln <= end; ln++ {
+		spans := tokenizer.SpansWithState(lines[ln-1], st)
+		if ln < start {
+			continue
+		}
+		lh := lineHits{line: ln}
+		seen := make(map[string]struct{})
+		for _, sp := range spans {
+			if _, ok := wanted[sp.Term]; !ok {
+				continue
+			}
+			lh.hits = append(lh.hits, sp)
+			if _, dup := seen[sp.Term]; !dup {
+				seen[sp.Term] = struct{}{}
+				lh.distinct++
+			}
+		}
+		perLine = append(perLine, lh)
+	}
+
+	var best []lineHits
+	for _, lh := range perLine {
+		if len(lh.hits) > 0 {
+			best = append(best, lh)
+		}
+	}
+	if len(best) == 0 {
+		return
+	}
+	sort.SliceStable(best, func(i, j int) bool {
+		if best[i].distinct != best[j].distinct {
+			return best[i].distinct > best[j].distinct
+		}
+		return len(best[i].hits) > len(best[j].hits)
+	})
+	if len(best) > maxHighlights {
+		best = best[:maxHighlights]
+	}
+	sort.Slice(best, func(i, j int) bool { return best[i].line < best[j].line })
+	r.Highlights = make([]Highlight, 0, len(best))
+	for _, lh := range best {
+		r.Highlights = append(r.Highlights, buildHighlight(lines[lh.line-1], lh, maxBytes))
+	}
+
+	r.Snippet = centredSnippet(lines, perLine, maxBytes)
+}
+
+// buildHighlight crops long lines around the first hit so Text stays within maxBytes.
+func buildHighlight(line string, lh lineHits, maxBytes int) Highlight {
+	from, to := 0, len(line)
+	if maxBytes > 0 && len(line) > maxBytes {
+		from = lh.hits[0].Start - maxBytes/4
+		if from < 0 {
+			from = 0
+		}
+		for from > 0 && !utf8.RuneStart(line[from]) {
+			from--
+		}
+		to = from + maxBytes
+		if to > len(line) {
+			to = len(line)
+		}
+		for to < len(line) && !utf8.RuneStart(line[to]) {
+			to--
+		}
+	}
+	h := Highlight{Line: uint32(lh.line), Text: line[from:to], From: from}
+	for _, sp := range lh.hits {
+		if sp.Start < from || sp.End > to {
+			continue
+		}
+		h.Hits = append(h.Hits, Hit{Term: sp.Term, Start: sp.Start - from, End: sp.End - from})
+	}
+	return h
+}
+
+// centredSnippet finds the snippetWindow-line window with the most distinct
+// matched terms (then most hits, then earliest) and renders the non-empty
+// lines nearest its middle line like the indexed snippet: trimmed lines in
+// source order, capped at maxBytes.
+func centredSnippet(lines []string, perLine []lineHits, maxBytes int) string {
+	bestStart, bestEnd, bestDistinct, bestHits := 0, 0, -1, -1
+	for i := range perLine {
+		endIdx := i + snippetWindow
+		if endIdx > len(perLine) {
+			endIdx = len(perLine)
+		}
+		terms := make(map[string]struct{})
+		hits := 0
+		for _, lh := range perLine[i:endIdx] {
+			for _, sp := range lh.hits {
+				terms[sp.Term] = struct{}{}
+			}
+			hits += len(lh.hits)
+		}
+		if len(terms) > bestDistinct || (len(terms) == bestDistinct && hits > bestHits) {
+			bestStart, bestEnd, bestDistinct, bestHits = i, endIdx, len(terms), hits
+		}
+		if endIdx == len(perLine) {
+			break
+		}
+	}
+	// Walk outwards from the middle of the window, below before above, so
+	// blank lines do not push the snippet off-centre.
+	mid := (bestStart + bestEnd - 1) / 2
+	var picked []int
+	for d := 0; d < len(perLine) && len(picked) < snippetWindow; d++ {
+		around := []int{mid + d, mid - d}
+		if d == 0 {
+			around = around[:1]
+		}
+		for _, i := range around {
+			if i < 0 || i >= len(perLine) || len(picked) >= snippetWindow {
+				continue
+			}
+			if strings.TrimSpace(lines[perLine[i].line-1]) != "" {
+				picked = append(picked, i)
+			}
+		}
+	}
+	sort.Ints(picked)
+	texts := make([]string, 0, len(picked))
+	for _, i := range picked {
+		texts = append(texts, strings.TrimSpace(lines[perLine[i].line-1]))
+	}
+	snippet := strings.Join(texts, "\n")
+	if maxBytes > 0 && len(snippet) > maxBytes {
+		b := []byte(snippet[:maxBytes])
+		for len(b) > 0 && !utf8.Valid(b) {
+			b = b[:len(b)-1]
+		}
+		snippet = string(b)
+	}
+	return snippet
+}
@@ -0,0 +1,868 @@
+package search
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/tokenize"
+)
+
+// QueryError reports a malformed query string.
+type QueryError struct {
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return "invalid query: " + e.Msg
+}
+
+// occur says how a clause takes part in matching.
+type occur int
+
+const (
+	occurShould occur = iota
+	occurMust
+	occurMustNot
+)
+
+// clause is one element of a (sub-)query: a node with its occurrence modifier.
+type clause struct {
+	occur occur
+	node  node
+}
+
+// node is an AST element: *termNode, *phraseNode, *patternNode, *fieldNode, *orNode or *groupNode.
+type node interface{}
+
+// termNode is a bare word; identifiers may expand into several tokens.
+type termNode struct {
+	text  string
+	terms []string
+}
+
+// phraseNode is a quoted token sequence that must occur at consecutive positions.
+type phraseNode struct {
+	text  string
+	terms []string
+}
+
+// patternKind selects how a patternNode expands against the term dictionary.
+type patternKind int
+
+const (
+	patternPrefix patternKind = iota
+	patternSuffix
+	patternFuzzy
+)
+
+// patternNode is a prefix (`auth*`), suffix (`*service`) or fuzzy (`recieve~`)
+// term. It matches when any of its dictionary expansions occurs.
+type patternNode struct {
+	text    string
+	kind    patternKind
+	value   string
+	maxDist int
+	terms   []string // filled by expandPatterns
+}
+
+// fieldNode restricts matches by chunk path.
+type fieldNode struct {
+	field string
+	value string
+}
+
+// orNode matches when any child matches.
+type orNode struct {
+	children []node
+}
+
+// groupNode is a parenthesized sub-query.
+type groupNode struct {
+	clauses []clause
+}
+
+// query is the parsed form of a search string.
+type query struct {
+	clauses []clause
+	terms   []string // every distinct term in query order
+	dropped []tokenize.Dropped
+}
+
+var langExts = map[string][]string{
+	"ts":  {".ts", ".tsx", ".mts", ".cts"},
+	"tsx": {".tsx"},
+	"js":  {".js", ".jsx", ".mjs", ".cjs"},
+	"jsx": {".jsx"},
+}
+
+// Query syntax (see docs/stdio_protocol.md):
+//
+//	word            optional term; identifiers split like indexed code
+//	abc* / *abc     any indexed token with that prefix / suffix
+//	abc~ / abc~N    any indexed token within N (1 or 2) edits; ~ picks by length
+//	"a b c"         phrase, tokens at consecutive positions
+//	+x / -x         x is required / x excludes the chunk
+//	x OR y          either side; cannot be combined with +/- without parentheses
+//	( ... )         sub-query
+//	path:, ext:, file:, lang:   path filters; required unless negated
+//
+// Words and phrases go through the same tokenizer as indexed text, so a word
+// matches the same tokens whether or not it is quoted.
+func parseQuery(q string, cfg config.TokenizationConfig) (query, error) {
+	lexemes, err := lexQuery(q)
+	if err != nil {
+		return query{}, err
+	}
+	p := &queryParser{lexemes: lexemes, tok: tokenize.New(cfg)}
+	clauses, err := p.parseClauses(false)
+	if err != nil {
+		return query{}, err
+	}
+	parsed := query{clauses: clauses, dropped: p.dropped}
+	parsed.collect()
+	return parsed, nil
+}
+
+// collect gathers every distinct term in query order, including pattern expansions.
+func (q *query) collect() {
+	q.terms = nil
+	seen := make(map[string]struct{})
+	collectTerms(q.clauses, func(term string) {
+		if _, ok := seen[term]; ok {
+			return
+		}
+		seen[term] = struct{}{}
+		q.terms = append(q.terms, term)
+	})
+}
+
+// maxExpansions caps how many dictionary terms a single pattern expands into;
+// the most frequent terms win.
+const maxExpansions = 64
+
+// expandPatterns resolves pattern nodes against the term dictionary and
+// refreshes the query term list.
+func (q *query) expandPatterns(dict *index.TermDict) {
+	var walk func(n node)
+	walk = func(n node) {
+		switch v := n.(type) {
+		case *patternNode:
+			v.terms = expandPattern(v, dict)
+		case *orNode:
+			for _, child := range v.children {
+				walk(child)
+			}
+		case *groupNode:
+			for _, c := range v.clauses {
+				walk(c.node)
+			}
+		}
+	}
+	for _, c := range q.clauses {
+		walk(c.node)
+	}
+	q.collect()
+}
+
+// unresolved walks the query and reports words and phrase terms missing from
+// the dictionary, plus patterns without expansions, in query order.
+func (q *query) unresolved(dict *index.TermDict) (terms []string, patterns []string) {
+	seen := make(map[string]struct{})
+	addTerm := func(term string) {
+		if _, ok := seen[term]; ok {
+			return
+		}
+		seen[term] = struct{}{}
+		if info, ok := dict.Lookup(term); !ok || info.DF == 0 {
+			terms = append(terms, term)
+		}
+	}
+	var walk func(n node)
+	walk = func(n node) {
+		switch v := n.(type) {
+		case *termNode:
+			for _, t := range v.terms {
+				addTerm(t)
+			}
+		case *phraseNode:
+			for _, t := range v.terms {
+				addTerm(t)
+			}
+		case *patternNode:
+			if len(v.terms) == 0 {
+				patterns = append(patterns, v.text)
+			}
+		case *orNode:
+			for _, child := range v.children {
+				walk(child)
+			}
+		case *groupNode:
+			for _, c := range v.clauses {
+				walk(c.node)
+			}
+		}
+	}
+	for _, c := range q.clauses {
+		walk(c.node)
+	}
+	return terms, patterns
+}
+
+func expandPattern(p *patternNode, dict *index.TermDict) []string {
+	type candidate struct {
+		term string
+		dist int
+		df   uint32
+	}
+	var candidates []candidate
+	add := func(term string, dist int) {
+		info, _ := dict.Lookup(term)
+		candidates = append(candidates, candidate{term: term, dist: dist, df: info.DF})
+	}
+	switch p.kind {
+	case patternPrefix:
+		for _, term := range dict.Prefix(p.value) {
+			add(term, 0)
+		}
+	case patternSuffix:
+		for _, term := range dict.Suffix(p.value) {
+			add(term, 0)
+		}
+	case patternFuzzy:
+		for _, m := range dict.Fuzzy(p.value, p.maxDist) {
+			add(m.Term, m.Distance)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.dist != b.dist {
+			return a.dist < b.dist
+		}
+		if a.df != b.df {
+			return a.df > b.df
+		}
+		return a.term < b.term
+	})
+	if len(candidates) > maxExpansions {
+		candidates = candidates[:maxExpansions]
+	}
+	terms := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		terms = append(terms, c.term)
+	}
+	return terms
+}
+
+type lexKind int
+
+const (
+	lexWord lexKind = iota
+	lexPhrase
+	lexOpen
+	lexClose
+	lexOr
+)
+
+type lexeme struct {
+	kind lexKind
+	text string
+	mod  occur
+}
+
+// spaceAt reports whether q has a Unicode space at byte offset i and its width.
+func spaceAt(q string, i int) (bool, int) {
+	r, w := utf8.DecodeRuneInString(q[i:])
+	return unicode.IsSpace(r), w
+}
+
+func lexQuery(q string) ([]lexeme, error) {
+	var out []lexeme
+	i := 0
+	for i < len(q) {
+		if space, w := spaceAt(q, i); space {
+			i += w
+			continue
+		}
+		mod := occurShould
+		if q[i] == '+' || q[i] == '-' {
+			if q[i] == '+' {
+				mod = occurMust
+			} else {
+				mod = occurMustNot
+			}
+			i++
+			if i >= len(q) || q[i] == ')' {
+				return nil, &QueryError{Msg: "dangling + or - modifier"}
+			}
+			if space, _ := spaceAt(q, i); space {
+				return nil, &QueryError{Msg: "dangling + or - modifier"}
+			}
+		}
+		switch q[i] {
+		case '(':
+			out = append(out, lexeme{kind: lexOpen, mod: mod})
+			i++
+		case ')':
+			out = append(out, lexeme{kind: lexClose})
+			i++
+		case '"':
+			end := strings.IndexByte(q[i+1:], '"')
+			if end < 0 {
+				return nil, &QueryError{Msg: "unterminated phrase"}
+			}
+			out = append(out, lexeme{kind: lexPhrase, text: strings.TrimSpace(q[i+1 : i+1+end]), mod: mod})
+			i += end + 2
+		default:
+			start := i
+			for i < len(q) && q[i] != '(' && q[i] != ')' && q[i] != '"' {
+				space, w := spaceAt(q, i)
+				if space {
+					break
+				}
+				i += w
+			}
+			word := q[start:i]
+			if word == "OR" && mod == occurShould {
+				out = append(out, lexeme{kind: lexOr})
+				continue
+			}
+			out = append(out, lexeme{kind: lexWord, text: word, mod: mod})
+		}
+	}
+	return out, nil
+}
+
+type queryParser struct {
+	lexemes []lexeme
+	pos     int
+	tok     tokenize.Tokenizer
+	dropped []tokenize.Dropped
+}
+
+func (p *queryParser) peek() (lexeme, bool) {
+	if p.pos >= len(p.lexemes) {
+		return lexeme{}, false
+	}
+	return p.lexemes[p.pos], true
+}
+
+func (p *queryParser) parseClauses(nested bool) ([]clause, error) {
+	var clauses []clause
+	for {
+		lx, ok := p.peek()
+		if !ok {
+			if nested {
+				return nil, &QueryError{Msg: "missing )"}
+			}
+			return clauses, nil
+		}
+		switch lx.kind {
+		case lexClose:
+			if !nested {
+				return nil, &QueryError{Msg: "unexpected )"}
+			}
+			p.pos++
+			return clauses, nil
+		case lexOr:
+			return nil, &QueryError{Msg: "OR needs a left operand"}
+		}
+
+		first, err := p.parseUnit()
+		if err != nil {
+			return nil, err
+		}
+		c := clause{occur: lx.mod, node: first}
+		for {
+			next, ok := p.peek()
+			if !ok || next.kind != lexOr {
+				break
+			}
+			p.pos++
+			right, ok := p.peek()
+			if !ok || right.kind == lexClose || right.kind == lexOr {
+				return nil, &QueryError{Msg: "OR needs a right operand"}
+			}
+			if c.occur != occurShould || right.mod != occurShould {
+				return nil, &QueryError{Msg: "+ and - cannot be combined with OR; use parentheses"}
+			}
+			operand, err := p.parseUnit()
+			if err != nil {
+				return nil, err
+			}
+			or, isOr := c.node.(*orNode)
+			if !isOr {
+				or = &orNode{children: []node{c.node}}
+				c.node = or
+			}
+			or.children = append(or.children, operand)
+		}
+		c.node = pruneNode(c.node)
+		if c.node == nil {
+			continue
+		}
+		if _, isField := c.node.(*fieldNode); isField && c.occur == occurShould {
+			c.occur = occurMust
+		}
+		clauses = append(clauses, c)
+	}
+}
+
+// parseUnit consumes a single word, phrase, field filter or parenthesized group.
+// Units that tokenize to nothing are returned as nil and pruned by the caller.
+func (p *queryParser) parseUnit() (node, error) {
+	lx := p.lexemes[p.pos]
+	p.pos++
+	switch lx.kind {
+	case lexOpen:
+		clauses, err := p.parseClauses(true)
+		if err != nil {
+			return nil, err
+		}
+		if len(clauses) == 0 {
+			return nil, nil
+		}
+		return &groupNode{clauses: clauses}, nil
+	case lexPhrase:
+		terms, dropped := p.tok.Inspect(lx.text)
+		p.dropped = append(p.dropped, dropped...)
+		switch len(terms) {
+		case 0:
+			return nil, nil
+		case 1:
+			return &termNode{text: lx.text, terms: terms}, nil
+		}
+		return &phraseNode{text: lx.text, terms: terms}, nil
+	case lexWord:
+		if field, value, ok := strings.Cut(lx.text, ":"); ok {
+			if n, isField, err := newFieldNode(strings.ToLower(field), value); isField {
+				return n, err
+			}
+		}
+		if n, isPattern, err := newPatternNode(lx.text); isPattern {
+			return n, err
+		}
+		tokens, dropped := p.tok.Inspect(lx.text)
+		p.dropped = append(p.dropped, dropped...)
+		terms := uniqueTerms(tokens)
+		if len(terms) == 0 {
+			return nil, nil
+		}
+		return &termNode{text: lx.text, terms: terms}, nil
+	}
+	return nil, &QueryError{Msg: "unexpected token"}
+}
+
+// uniqueTerms drops repeated tokens, keeping the first occurrence of each.
+func uniqueTerms(tokens []string) []string {
+	seen := make(map[string]struct{}, len(tokens))
+	out := tokens[:0]
+	for _, t := range tokens {
+		if _, dup := seen[t]; !dup {
+			seen[t] = struct{}{}
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func newFieldNode(field, value string) (node, bool, error) {
+	switch field {
+	case "path", "ext", "file", "lang":
+	default:
+		return nil, false, nil
+	}
+	if value == "" {
+		return nil, true, &QueryError{Msg: field + ": needs a value"}
+	}
+	if field == "lang" {
+		if _, ok := langExts[strings.ToLower(value)]; !ok {
+			return nil, true, &QueryError{Msg: "unknown lang " + value}
+		}
+	}
+	return &fieldNode{field: field, value: value}, true, nil
+}
+
+// newPatternNode recognizes wildcard and fuzzy words. Patterns apply to a
+// single index token, so the value must be letters and digits only.
+func newPatternNode(word string) (node, bool, error) {
+	p := &patternNode{text: word}
+	value := word
+	switch {
+	case strings.HasSuffix(word, "*") && !strings.HasPrefix(word, "*"):
+		p.kind = patternPrefix
+		value = strings.TrimSuffix(word, "*")
+	case strings.HasPrefix(word, "*") && !strings.HasSuffix(word, "*"):
+		p.kind = patternSuffix
+		value = strings.TrimPrefix(word, "*")
+	case strings.Contains(word, "~"):
+		p.kind = patternFuzzy
+		var dist string
+		value, dist, _ = strings.Cut(word, "~")
+		switch dist {
+		case "":
+			p.maxDist = 1
+			if utf8.RuneCountInString(value) > 5 {
+				p.maxDist = 2
+			}
+		case "1", "2":
+			p.maxDist = int(dist[0] - '0')
+		default:
+			return nil, true, &QueryError{Msg: "fuzzy distance must be 1 or 2 in " + word}
+		}
+	case strings.Contains(word, "*"):
+		return nil, true, &QueryError{Msg: "unsupported wildcard in " + word}
+	default:
+		return nil, false, nil
+	}
+	if value == "" {
+		return nil, true, &QueryError{Msg: "empty pattern " + word}
+	}
+	for _, r := range value {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return nil, true, &QueryError{Msg: "pattern must be a single token: " + word}
+		}
+	}
+	p.value = strings.ToLower(value)
+	return p, true, nil
+}
+
+// pruneNode drops empty units so stop words and empty groups do not affect matching.
+func pruneNode(n node) node {
+	switch v := n.(type) {
+	case nil:
+		return nil
+	case *orNode:
+		kept := v.children[:0]
+		for _, child := range v.children {
+			if child != nil {
+				kept = append(kept, child)
+			}
+		}
+		switch len(kept) {
+		case 0:
+			return nil
+		case 1:
+			return kept[0]
+		}
+		v.children = kept
+	}
+	return n
+}
+
+func collectTerms(clauses []clause, add func(string)) {
+	var walk func(n node)
+	walk = func(n node) {
+		switch v := n.(type) {
+		case *termNode:
+			for _, t := range v.terms {
+				add(t)
+			}
+		case *phraseNode:
+			for _, t := range v.terms {
+				add(t)
+			}
+		case *patternNode:
+			for _, t := range v.terms {
+				add(t)
+			}
+		case *orNode:
+			for _, child := range v.children {
+				walk(child)
+			}
+		case *groupNode:
+			collectTerms(v.clauses, add)
+		}
+	}
+	for _, c := range clauses {
+		walk(c.node)
+	}
+}
+
+// hasText reports whether a node references any term.
+func hasText(n node) bool {
+	switch v := n.(type) {
+	case *termNode, *phraseNode, *patternNode:
+		return true
+	case *orNode:
+		for _, child := range v.children {
+			if hasText(child) {
+				return true
+			}
+		}
+	case *groupNode:
+		for _, c := range v.clauses {
+			if c.occur != occurMustNot && hasText(c.node) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requiresHit reports whether accepting a chunk implies at least one term posting
+// for it, which lets evaluation skip chunks without hits.
+func requiresHit(clauses []clause) bool {
+	mustText := false
+	shouldText := false
+	for _, c := range clauses {
+		switch c.occur {
+		case occurMust:
+			if nodeRequiresHit(c.node) {
+				return true
+			}
+			if hasText(c.node) {
+				mustText = true
+			}
+		case occurShould:
+			if hasText(c.node) {
+				shouldText = true
+			}
+		}
+	}
+	return !mustText && shouldText
+}
+
+func nodeRequiresHit(n node) bool {
+	switch v := n.(type) {
+	case *termNode, *phraseNode, *patternNode:
+		return true
+	case *orNode:
+		for _, child := range v.children {
+			if !nodeRequiresHit(child) {
+				return false
+			}
+		}
+		return true
+	case *groupNode:
+		return requiresHit(v.clauses)
+	}
+	return false
+}
+
+// chunkView is what the evaluator knows about one candidate chunk.
+type chunkView struct {
+	path string
+	hits map[string][]uint32 // term -> positions; presence means the term occurs
+}
+
+// match is the outcome of evaluating a node against a chunk.
+type match struct {
+	ok      bool
+	terms   []string // terms that contribute to the score
+	phrases []string
+}
+
+func (m *match) absorb(other match) {
+	m.terms = append(m.terms, other.terms...)
+	m.phrases = append(m.phrases, other.phrases...)
+}
+
+// evalClauses applies must/must-not/should semantics. Should clauses are optional
+// when a required text clause exists; otherwise at least one must contribute.
+func evalClauses(clauses []clause, cv chunkView) match {
+	var out match
+	mustText := false
+	shouldText := false
+	shouldHit := false
+	for _, c := range clauses {
+		m := evalNode(c.node, cv)
+		switch c.occur {
+		case occurMust:
+			if !m.ok {
+				return match{}
+			}
+			if hasText(c.node) {
+				mustText = true
+			}
+			out.absorb(m)
+		case occurMustNot:
+			if m.ok {
+				return match{}
+			}
+		case occurShould:
+			if hasText(c.node) {
+				shouldText = true
+			}
+			if m.ok || len(m.terms) > 0 {
+				shouldHit = true
+				out.absorb(m)
+			}
+		}
+	}
+	if !mustText && shouldText && !shouldHit {
+		return match{}
+	}
+	out.ok = true
+	return out
+}
+
+// evalNode reports a strict match (all tokens of a word, the full phrase, any OR
+// branch) together with partial contributions used for optional clauses.
+func evalNode(n node, cv chunkView) match {
+	switch v := n.(type) {
+	case *termNode:
+		var m match
+		for _, t := range v.terms {
+			if _, ok := cv.hits[t]; ok {
+				m.terms = append(m.terms, t)
+			}
+		}
+		m.ok = len(m.terms) == len(v.terms)
+		return m
+	case *phraseNode:
+		lists := make([][]uint32, 0, len(v.terms))
+		for _, t := range v.terms {
+			lists = append(lists, cv.hits[t])
+		}
+		if !matchPhrase(lists) {
+			return match{}
+		}
+		return match{ok: true, terms: append([]string(nil), v.terms...), phrases: []string{v.text}}
+	case *patternNode:
+		var m match
+		for _, t := range v.terms {
+			if _, ok := cv.hits[t]; ok {
+				m.terms = append(m.terms, t)
+			}
+		}
+		m.ok = len(m.terms) > 0
+		return m
+	case *fieldNode:
+		return match{ok: matchField(v, cv.path)}
+	case *orNode:
+		var out match
+		for _, child := range v.children {
+			m := evalNode(child, cv)
+			if m.ok {
+				out.ok = true
+			}
+			out.absorb(m)
+		}
+		return out
+	case *groupNode:
+		return evalClauses(v.clauses, cv)
+	}
+	return match{}
+}
+
+// acceptedFiles returns the ids of the files that pass the top-level path
+// filters of clauses, or nil when there are none. Filters inside groups and OR
+// are left to evalClauses.
+func acceptedFiles(clauses []clause, files func() ([]index.FileEntry, error)) (map[uint32]struct{}, error) {
+	var filters []clause
+	for _, c := range clauses {
+		if _, ok := c.node.(*fieldNode); ok && c.occur != occurShould {
+			filters = append(filters, c)
+		}
+	}
+	if len(filters) == 0 || files == nil {
+		return nil, nil
+	}
+	entries, err := files()
+	if err != nil {
+		return nil, err
+	}
+	accepted := make(map[uint32]struct{})
+	for _, fe := range entries {
+		ok := true
+		for _, c := range filters {
+			if matchField(c.node.(*fieldNode), fe.Path) != (c.occur == occurMust) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			accepted[fe.FileID] = struct{}{}
+		}
+	}
+	return accepted, nil
+}
+
+func matchField(f *fieldNode, chunkPath string) bool {
+	switch f.field {
+	case "path":
+		value := strings.TrimPrefix(f.value, "/")
+		return strings.HasPrefix(chunkPath, value) || strings.Contains(chunkPath, "/"+value)
+	case "ext":
+		ext := "." + strings.TrimPrefix(strings.ToLower(f.value), ".")
+		return strings.HasSuffix(strings.ToLower(chunkPath), ext)
+	case "file":
+		return strings.Contains(strings.ToLower(path.Base(chunkPath)), strings.ToLower(f.value))
+	case "lang":
+		lower := strings.ToLower(chunkPath)
+		if strings.HasSuffix(lower, ".d.ts") {
+			return false
+		}
+		for _, ext := range langExts[strings.ToLower(f.value)] {
+			if strings.HasSuffix(lower, ext) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPhrase reports whether the phrase terms occur at consecutive positions.
+func matchPhrase(lists [][]uint32) bool {
+	if len(lists) == 0 {
+		return false
+	}
+	for _, start := range lists[0] {
+		ok := true
+		for i := 1; i < len(lists); i++ {
+			if !containsPosition(lists[i], start+uint32(i)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPosition(sorted []uint32, want uint32) bool {
+	lo, hi := 0, len(sorted)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if sorted[mid] < want {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo < len(sorted) && sorted[lo] == want
+}
+
+// minSpan returns the width of the smallest token window that contains at least
+// one position from every list. Lists must be sorted and non-empty.
+func minSpan(lists [][]uint32) int {
+	idx := make([]int, len(lists))
+	best := -1
+	for {
+		minList, lo, hi := 0, lists[0][idx[0]], lists[0][idx[0]]
+		for i := 1; i < len(lists); i++ {
+			v := lists[i][idx[i]]
+			if v < lo {
+				lo = v
+				minList = i
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if span := int(hi - lo); best < 0 || span < best {
+			best = span
+		}
+		idx[minList]++
+		if idx[minList] >= len(lists[minList]) {
+			return best
+		}
+	}
+}
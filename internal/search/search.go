@@ -1,21 +1,26 @@
 package search
 
 import (
+	"container/heap"
 	"fmt"
 	"math"
 	"sort"
 
 	"github.com/memkit/repodex/internal/config"
 	"github.com/memkit/repodex/internal/index"
-	"github.com/memkit/repodex/internal/lang"
-	"github.com/memkit/repodex/internal/lang/factory"
 	"github.com/memkit/repodex/internal/store"
 )
 
-// Options controls search behavior.
+// Options controls search behavior. Zero values use the configured limits;
+// larger values are clamped to Limits.MaxTopK.
 type Options struct {
 	TopK       int
 	MaxPerFile int
+	// After is a next_cursor from a previous page of the same query.
+	After string
+	// Collapse merges hits on overlapping chunks of the same file into one
+	// result before the per-file cap is applied.
+	Collapse bool
 }
 
 // Result represents a ranked chunk.
@@ -27,113 +32,241 @@ type Result struct {
 	Score     float64  `json:"score"`
 	Snippet   string   `json:"snippet"`
 	Why       []string `json:"why"`
+	// Phrases lists the quoted query phrases found verbatim in the chunk.
+	Phrases []string `json:"phrases,omitempty"`
+	// Span is the smallest token window covering all matched terms (0 when fewer than two matched).
+	Span int `json:"span,omitempty"`
+	// Highlights are the best-matching chunk lines, filled by HighlightResults.
+	Highlights []Highlight `json:"highlights,omitempty"`
+	// ChunkIDs lists, in line order, the chunks merged into a collapsed result.
+	ChunkIDs []uint32 `json:"chunk_ids,omitempty"`
 }
 
+// Response is the search envelope: ranked results plus the query terms that
+// could not take part in matching.
+type Response struct {
+	Results []Result      `json:"results"`
+	Dropped []DroppedTerm `json:"dropped,omitempty"`
+	// NextCursor resumes after the last result; empty on the final page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// DroppedTerm is a query term ignored by the tokenizer or absent from the index.
+type DroppedTerm struct {
+	Term   string `json:"term"`
+	Reason string `json:"reason"`
+	// Suggestions lists close dictionary terms, most frequent first (not_in_index only).
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// ReasonNotInIndex marks query terms with no postings. Tokenizer reasons are
+// the tokenize.Drop* constants.
+const ReasonNotInIndex = "not_in_index"
+
+// maxSuggestions caps did-you-mean terms per dropped term.
+const maxSuggestions = 5
+
 // Search executes a keyword search over the serialized index.
-func Search(root string, q string, opts Options) ([]Result, error) {
-	topK := opts.TopK
-	if topK <= 0 {
-		topK = 20
-	}
-	if topK > 20 {
-		topK = 20
-	}
-	maxPerFile := opts.MaxPerFile
-	if maxPerFile <= 0 {
-		maxPerFile = 2
+func Search(root string, q string, opts Options) (Response, error) {
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		return Response{}, err
 	}
+	defer lease.Release()
 
 	cfg, _, err := config.Load(store.ConfigPath(root))
 	if err != nil {
-		return nil, err
+		return Response{}, err
 	}
-	plugin, err := factory.FromProjectType(cfg.ProjectType)
+	// A one-off search maps the index and reads only what the query touches.
+	reader, err := index.OpenReader(gen)
 	if err != nil {
-		return nil, err
+		return Response{}, err
 	}
+	defer reader.Close()
 
-	chunks, err := index.LoadChunkEntries(store.ChunksPath(root))
+	files, err := index.LoadFileEntries(gen.FilesPath())
 	if err != nil {
-		return nil, err
+		return Response{}, err
 	}
-	terms, _, err := index.LoadTerms(store.TermsPath(root))
-	if err != nil {
-		return nil, err
+
+	idx := Index{
+		Chunks:     reader.Chunks(),
+		Terms:      reader.Terms(),
+		Postings:   reader.Postings(),
+		Generation: gen.Name,
+		Files:      func() ([]index.FileEntry, error) { return files, nil },
 	}
-	postings, err := index.LoadPostings(store.PostingsPath(root))
+	resp, err := SearchWithIndex(cfg, idx, q, opts)
 	if err != nil {
-		return nil, err
+		return Response{}, err
+	}
+	if err := HighlightResults(root, cfg, files, gen.SnapshotsPath(), resp.Results); err != nil {
+		return Response{}, err
 	}
+	return resp, nil
+}
 
-	return SearchWithIndex(cfg, plugin, chunks, nil, terms, postings, q, Options{TopK: topK, MaxPerFile: maxPerFile})
+// Index is the index data a search reads.
+type Index struct {
+	Chunks   index.Chunks
+	Terms    *index.TermDict
+	Postings *index.Postings
+	// Generation names the index generation; cursors are bound to it.
+	Generation string
+	// Files returns the indexed files. Only queries without text call it, to
+	// pick the files their path filters accept before visiting chunks.
+	Files func() ([]index.FileEntry, error)
 }
 
 // SearchWithIndex executes a keyword search using provided index data.
-func SearchWithIndex(cfg config.Config, plugin lang.LanguagePlugin, chunks []index.ChunkEntry, chunkMap map[uint32]index.ChunkEntry, terms map[string]index.TermInfo, postings []uint32, q string, opts Options) ([]Result, error) {
-	topK := opts.TopK
-	if topK <= 0 {
-		topK = 20
+func SearchWithIndex(cfg config.Config, idx Index, q string, opts Options) (Response, error) {
+	parsed, err := parseQuery(q, cfg.Token)
+	if err != nil {
+		return Response{}, err
 	}
-	if topK > 20 {
-		topK = 20
+	topK, maxPerFile := effectiveLimits(cfg.Limits, opts)
+	key := queryKey(q, maxPerFile, opts.Collapse)
+	var start *cursor
+	if opts.After != "" {
+		c, err := decodeCursor(opts.After, key, idx.Generation)
+		if err != nil {
+			return Response{}, err
+		}
+		start = &c
 	}
-	maxPerFile := opts.MaxPerFile
-	if maxPerFile <= 0 {
-		maxPerFile = 2
+	parsed.expandPatterns(idx.Terms)
+	scored, err := rank(cfg, idx, parsed)
+	if err != nil {
+		return Response{}, err
 	}
 
-	tokens := plugin.TokenizeChunk("", q, cfg.Token)
-	uniqueTerms := make([]string, 0, len(tokens))
-	seen := make(map[string]struct{})
-	for _, tok := range tokens {
-		if _, ok := seen[tok]; ok {
-			continue
-		}
-		seen[tok] = struct{}{}
-		uniqueTerms = append(uniqueTerms, tok)
+	page := selectPage(scored, start, maxPerFile, opts.Collapse, topK+1)
+	resp := Response{Results: page, Dropped: droppedTerms(parsed, idx.Terms)}
+	if len(page) > topK {
+		resp.Results = page[:topK]
+		last := resp.Results[topK-1]
+		resp.NextCursor = encodeCursor(cursor{Query: key, Generation: idx.Generation, Score: last.Score, ChunkID: last.ChunkID})
 	}
+	return resp, nil
+}
 
-	if len(uniqueTerms) == 0 || len(chunks) == 0 {
-		return nil, nil
+// rank scores every chunk the query accepts. The results are unordered;
+// selectPage orders the ones a page needs.
+func rank(cfg config.Config, idx Index, parsed query) ([]Result, error) {
+	chunks, terms, postings := idx.Chunks, idx.Terms, idx.Postings
+	if len(parsed.clauses) == 0 || chunks.Len() == 0 {
+		return []Result{}, nil
 	}
+	queryTerms := parsed.terms
+
+	N := float64(chunks.Len())
+	avgLen := averageTokenCount(chunks)
+	k1, b := cfg.Search.K1(), cfg.Search.B()
 
-	if chunkMap == nil {
-		chunkMap = make(map[uint32]index.ChunkEntry, len(chunks))
-		for _, ch := range chunks {
-			chunkMap[ch.ChunkID] = ch
+	// termScores[chunk][term] holds the BM25 contribution, hits[chunk][term] the term positions.
+	termScores := make(map[uint32]map[string]float64)
+	hits := make(map[uint32]map[string][]uint32)
+	for _, term := range queryTerms {
+		info, ok := terms.Lookup(term)
+		if !ok {
+			continue
+		}
+		if info.DF == 0 {
+			continue
+		}
+		list, err := postings.List(info)
+		if err != nil {
+			return nil, fmt.Errorf("term %s: %w", term, err)
+		}
+		idf := bm25IDF(N, float64(info.DF))
+		for _, p := range list {
+			i, ok := chunks.Find(p.ChunkID)
+			if !ok {
+				return nil, fmt.Errorf("missing chunk %d", p.ChunkID)
+			}
+			if hits[p.ChunkID] == nil {
+				hits[p.ChunkID] = make(map[string][]uint32)
+				termScores[p.ChunkID] = make(map[string]float64)
+			}
+			hits[p.ChunkID][term] = p.Positions
+			termScores[p.ChunkID][term] = idf * bm25TF(float64(p.TF), float64(chunks.TokenCount(i)), avgLen, k1, b)
+		}
+	}
+
+	// Without a required term, filter-only and exclusion-only queries consider
+	// every chunk of the files their path filters accept.
+	candidates := make([]uint32, 0, len(hits))
+	if requiresHit(parsed.clauses) {
+		for id := range hits {
+			candidates = append(candidates, id)
+		}
+	} else {
+		files, err := acceptedFiles(parsed.clauses, idx.Files)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < chunks.Len(); i++ {
+			if _, ok := files[chunks.FileID(i)]; files == nil || ok {
+				candidates = append(candidates, chunks.ID(i))
+			}
 		}
 	}
 
-	N := float64(len(chunks))
 	scores := make(map[uint32]float64)
 	why := make(map[uint32][]string)
-
-	for _, term := range uniqueTerms {
-		info, ok := terms[term]
+	matchedPhrases := make(map[uint32][]string)
+	spans := make(map[uint32]int)
+	matched := make(map[uint32]index.ChunkEntry)
+	for _, id := range candidates {
+		i, ok := chunks.Find(id)
 		if !ok {
-			continue
+			return nil, fmt.Errorf("missing chunk %d", id)
 		}
-		if info.DF == 0 {
+		ch := chunks.At(i)
+		chunkHits := hits[id]
+		m := evalClauses(parsed.clauses, chunkView{path: ch.Path, hits: chunkHits})
+		if !m.ok {
 			continue
 		}
-		idf := math.Log(1 + N/float64(info.DF))
-		start := info.Offset / 4
-		end := start + uint64(info.DF)
-		if end > uint64(len(postings)) {
-			return nil, fmt.Errorf("postings out of range for term %s", term)
+		matched[id] = ch
+		contributing := make(map[string]struct{}, len(m.terms))
+		for _, term := range m.terms {
+			contributing[term] = struct{}{}
+		}
+		seenPhrase := make(map[string]struct{}, len(m.phrases))
+		for _, text := range m.phrases {
+			if _, dup := seenPhrase[text]; dup {
+				continue
+			}
+			seenPhrase[text] = struct{}{}
+			matchedPhrases[id] = append(matchedPhrases[id], text)
 		}
-		for _, chunkID := range postings[start:end] {
-			scores[chunkID] += idf
-			why[chunkID] = append(why[chunkID], term)
+
+		var score float64
+		var positioned [][]uint32
+		why[id] = []string{}
+		for _, term := range queryTerms {
+			if _, ok := contributing[term]; !ok {
+				continue
+			}
+			score += termScores[id][term]
+			why[id] = append(why[id], term)
+			if pos := chunkHits[term]; len(pos) > 0 {
+				positioned = append(positioned, pos)
+			}
+		}
+		if len(positioned) >= 2 {
+			span := minSpan(positioned)
+			spans[id] = span
+			score *= proximityFactor(len(positioned), span)
 		}
+		scores[id] = score
 	}
 
 	results := make([]Result, 0, len(scores))
 	for id, score := range scores {
-		ch, ok := chunkMap[id]
-		if !ok {
-			return nil, fmt.Errorf("missing chunk %d", id)
-		}
+		ch := matched[id]
 		results = append(results, Result{
 			ChunkID:   id,
 			Path:      ch.Path,
@@ -142,28 +275,176 @@ func SearchWithIndex(cfg config.Config, plugin lang.LanguagePlugin, chunks []ind
 			Score:     score,
 			Snippet:   ch.Snippet,
 			Why:       why[id],
+			Phrases:   matchedPhrases[id],
+			Span:      spans[id],
 		})
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		if results[i].Score == results[j].Score {
-			return results[i].ChunkID < results[j].ChunkID
-		}
-		return results[i].Score > results[j].Score
-	})
+	return results, nil
+}
 
-	filtered := make([]Result, 0, len(results))
-	perFileCount := make(map[string]int)
+// selectPage returns up to limit results ranked strictly below start (from
+// the top when start is nil), in rank order. Collapsing and the per-file cap
+// apply over the whole ranking, so each page of a paginated query sees the
+// same filtered order: results at or above the cursor only count towards
+// the cap of their file, and the rest are popped off a heap until the page is
+// full rather than sorted in full.
+func selectPage(results []Result, start *cursor, maxPerFile int, collapse bool, limit int) []Result {
+	if collapse {
+		results = collapseOverlapping(results)
+	}
+	shown := make(map[string]int)
+	rest := make(resultHeap, 0, len(results))
 	for _, r := range results {
-		if perFileCount[r.Path] >= maxPerFile {
+		if start != nil && !start.after(r) {
+			if shown[r.Path] < maxPerFile {
+				shown[r.Path]++
+			}
 			continue
 		}
-		perFileCount[r.Path]++
-		filtered = append(filtered, r)
-		if len(filtered) >= topK {
-			break
+		rest = append(rest, r)
+	}
+	heap.Init(&rest)
+	page := make([]Result, 0, limit)
+	for rest.Len() > 0 && len(page) < limit {
+		r := heap.Pop(&rest).(Result)
+		if shown[r.Path] >= maxPerFile {
+			continue
+		}
+		shown[r.Path]++
+		page = append(page, r)
+	}
+	return page
+}
+
+// ranksBefore orders results by descending score, then ascending chunk id.
+func ranksBefore(a, b Result) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.ChunkID < b.ChunkID
+}
+
+// resultHeap is a heap of results with the best-ranked on top.
+type resultHeap []Result
+
+func (h resultHeap) Len() int           { return len(h) }
+func (h resultHeap) Less(i, j int) bool { return ranksBefore(h[i], h[j]) }
+func (h resultHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x any)        { *h = append(*h, x.(Result)) }
+func (h *resultHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// droppedTerms reports tokenizer drops followed by unknown terms with suggestions.
+func droppedTerms(parsed query, dict *index.TermDict) []DroppedTerm {
+	var out []DroppedTerm
+	seen := make(map[string]struct{})
+	for _, d := range parsed.dropped {
+		if _, ok := seen[d.Token]; ok {
+			continue
+		}
+		seen[d.Token] = struct{}{}
+		out = append(out, DroppedTerm{Term: d.Token, Reason: d.Reason})
+	}
+	unknown, patterns := parsed.unresolved(dict)
+	for _, term := range unknown {
+		out = append(out, DroppedTerm{Term: term, Reason: ReasonNotInIndex, Suggestions: suggest(dict, term)})
+	}
+	for _, p := range patterns {
+		out = append(out, DroppedTerm{Term: p, Reason: ReasonNotInIndex})
+	}
+	return out
+}
+
+// suggest returns dictionary terms within a small edit distance of term,
+// ranked by document frequency, then distance, then term.
+func suggest(dict *index.TermDict, term string) []string {
+	maxDist := 2
+	if len([]rune(term)) <= 4 {
+		maxDist = 1
+	}
+	matches := dict.Fuzzy(term, maxDist)
+	df := make(map[string]uint32, len(matches))
+	for _, m := range matches {
+		info, _ := dict.Lookup(m.Term)
+		df[m.Term] = info.DF
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if df[a.Term] != df[b.Term] {
+			return df[a.Term] > df[b.Term]
+		}
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
 		}
+		return a.Term < b.Term
+	})
+	if len(matches) > maxSuggestions {
+		matches = matches[:maxSuggestions]
 	}
+	out := make([]string, 0, len(matches))
+	for _, m := range matches {
+		out = append(out, m.Term)
+	}
+	return out
+}
 
-	return filtered, nil
+// proximityWeight is the maximum score boost for matched terms that sit next to each other.
+const proximityWeight = 0.5
+
+// proximityFactor boosts chunks whose matched terms are close together. Adjacent
+// terms (span == matched-1) receive the full weight; the boost decays with distance.
+func proximityFactor(matched int, span int) float64 {
+	if matched < 2 || span <= 0 {
+		return 1
+	}
+	return 1 + proximityWeight*float64(matched-1)/float64(span)
+}
+
+// effectiveLimits resolves request options against the configured limits.
+func effectiveLimits(limits config.LimitsConfig, opts Options) (int, int) {
+	maxTopK := limits.MaxTopK
+	if maxTopK <= 0 {
+		maxTopK = config.DefaultMaxTopK
+	}
+	topK := opts.TopK
+	if topK <= 0 || topK > maxTopK {
+		topK = maxTopK
+	}
+	maxPerFile := opts.MaxPerFile
+	if maxPerFile <= 0 {
+		maxPerFile = limits.MaxPerFile
+	}
+	if maxPerFile <= 0 {
+		maxPerFile = config.DefaultMaxPerFile
+	}
+	if maxPerFile > maxTopK {
+		maxPerFile = maxTopK
+	}
+	return topK, maxPerFile
+}
+
+// bm25IDF is the BM25 inverse document frequency, kept non-negative for very common terms.
+func bm25IDF(n, df float64) float64 {
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// bm25TF saturates term frequency and normalizes it by chunk length.
+func bm25TF(tf, chunkLen, avgLen, k1, b float64) float64 {
+	lengthRatio := 1.0
+	if avgLen > 0 {
+		lengthRatio = chunkLen / avgLen
+	}
+	return tf * (k1 + 1) / (tf + k1*(1-b+b*lengthRatio))
+}
+
+func averageTokenCount(chunks index.Chunks) float64 {
+	if chunks.Len() == 0 {
+		return 0
+	}
+	return float64(chunks.TotalTokenCount()) / float64(chunks.Len())
 }
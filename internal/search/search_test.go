@@ -1,11 +1,16 @@
 package search
 
 import (
+	"errors"
 	"math"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/hash"
 	"github.com/memkit/repodex/internal/index"
 	"github.com/memkit/repodex/internal/lang/factory"
 	"github.com/memkit/repodex/internal/store"
@@ -23,16 +28,17 @@ func TestSearchRanking(t *testing.T) {
 		{ChunkID: 2, FileID: 2, Path: "b.ts", StartLine: 4, EndLine: 8, Snippet: "alpha beta"},
 		{ChunkID: 3, FileID: 3, Path: "c.ts", StartLine: 2, EndLine: 6, Snippet: "beta only"},
 	}
-	postings := map[string][]uint32{
-		"alpha": {1, 2},
-		"beta":  {2, 3},
+	postings := map[string][]index.Posting{
+		"alpha": {{ChunkID: 1, TF: 1}, {ChunkID: 2, TF: 1}},
+		"beta":  {{ChunkID: 2, TF: 1}, {ChunkID: 3, TF: 1}},
 	}
 	createIndex(t, root, files, chunks, postings)
 
-	results, err := Search(root, "alpha beta", Options{})
+	resp, err := Search(root, "alpha beta", Options{})
 	if err != nil {
 		t.Fatalf("search failed: %v", err)
 	}
+	results := resp.Results
 	if len(results) != 3 {
 		t.Fatalf("expected 3 results, got %d", len(results))
 	}
@@ -42,7 +48,8 @@ func TestSearchRanking(t *testing.T) {
 	if results[1].ChunkID != 1 || results[2].ChunkID != 3 {
 		t.Fatalf("unexpected order for ties: %d, %d", results[1].ChunkID, results[2].ChunkID)
 	}
-	idf := math.Log(1 + float64(len(chunks))/2.0)
+	// Chunks carry no token counts, so length normalization is neutral and tf=1 scores 1.
+	idf := math.Log(1 + (float64(len(chunks))-2+0.5)/(2+0.5))
 	want := idf * 2
 	if math.Abs(results[0].Score-want) > 1e-9 {
 		t.Fatalf("expected score %.6f, got %.6f", want, results[0].Score)
@@ -64,15 +71,16 @@ func TestSearchMaxPerFile(t *testing.T) {
 		{ChunkID: 3, FileID: 1, Path: "same.ts", StartLine: 5, EndLine: 6, Snippet: "alpha more"},
 		{ChunkID: 4, FileID: 2, Path: "other.ts", StartLine: 1, EndLine: 2, Snippet: "alpha elsewhere"},
 	}
-	postings := map[string][]uint32{
-		"alpha": {1, 2, 3, 4},
+	postings := map[string][]index.Posting{
+		"alpha": {{ChunkID: 1, TF: 1}, {ChunkID: 2, TF: 1}, {ChunkID: 3, TF: 1}, {ChunkID: 4, TF: 1}},
 	}
 	createIndex(t, root, files, chunks, postings)
 
-	results, err := Search(root, "alpha", Options{})
+	resp, err := Search(root, "alpha", Options{})
 	if err != nil {
 		t.Fatalf("search failed: %v", err)
 	}
+	results := resp.Results
 	if len(results) != 3 {
 		t.Fatalf("expected 3 results after max_per_file filter, got %d", len(results))
 	}
@@ -81,7 +89,585 @@ func TestSearchMaxPerFile(t *testing.T) {
 	}
 }
 
-func createIndex(t *testing.T, root string, files []index.FileEntry, chunks []index.ChunkEntry, postings map[string][]uint32) {
+func TestSearchConfiguredLimits(t *testing.T) {
+	root := t.TempDir()
+	var chunks []index.ChunkEntry
+	var list []index.Posting
+	files := []index.FileEntry{{FileID: 1, Path: "many.ts"}}
+	for id := uint32(1); id <= 30; id++ {
+		chunks = append(chunks, index.ChunkEntry{ChunkID: id, FileID: 1, Path: "many.ts", StartLine: id, EndLine: id, Snippet: "alpha"})
+		list = append(list, index.Posting{ChunkID: id, TF: 1})
+	}
+	createIndex(t, root, files, chunks, map[string][]index.Posting{"alpha": list})
+
+	cfg := config.DefaultConfig()
+	cfg.ProjectType = factory.ProjectTypeTS
+	cfg.Limits.MaxTopK = 25
+	if err := config.Save(store.ConfigPath(root), cfg); err != nil {
+		t.Fatalf("config save failed: %v", err)
+	}
+
+	resp, err := Search(root, "alpha", Options{TopK: 100, MaxPerFile: 100})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(resp.Results) != 25 {
+		t.Fatalf("expected results clamped to MaxTopK 25, got %d", len(resp.Results))
+	}
+	resp, err = Search(root, "alpha", Options{MaxPerFile: 4})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(resp.Results) != 4 {
+		t.Fatalf("expected requested max_per_file 4, got %d", len(resp.Results))
+	}
+
+	cfg.Limits.MaxTopK = config.CeilingMaxTopK + 1
+	if err := config.Save(store.ConfigPath(root), cfg); err != nil {
+		t.Fatalf("config save failed: %v", err)
+	}
+	if _, err := Search(root, "alpha", Options{}); err == nil || !strings.Contains(err.Error(), "Limits.MaxTopK") {
+		t.Fatalf("expected limit above ceiling to be rejected, got %v", err)
+	}
+}
+
+func TestSearchCursorPagination(t *testing.T) {
+	root := t.TempDir()
+	files := []index.FileEntry{{FileID: 1, Path: "a.ts"}, {FileID: 2, Path: "b.ts"}, {FileID: 3, Path: "c.ts"}}
+	var chunks []index.ChunkEntry
+	var list []index.Posting
+	for id := uint32(1); id <= 12; id++ {
+		file := files[(id-1)%3]
+		chunks = append(chunks, index.ChunkEntry{ChunkID: id, FileID: file.FileID, Path: file.Path, StartLine: id, EndLine: id, TokenCount: 10, Snippet: "alpha"})
+		list = append(list, index.Posting{ChunkID: id, TF: id})
+	}
+	createIndex(t, root, files, chunks, map[string][]index.Posting{"alpha": list})
+
+	full, err := Search(root, "alpha", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(full.Results) != 6 || full.NextCursor != "" {
+		t.Fatalf("expected 6 results under max_per_file 2 and no cursor, got %d %q", len(full.Results), full.NextCursor)
+	}
+
+	var paged []Result
+	after := ""
+	for page := 0; page < 5; page++ {
+		resp, err := Search(root, "alpha", Options{TopK: 4, After: after})
+		if err != nil {
+			t.Fatalf("page %d failed: %v", page, err)
+		}
+		paged = append(paged, resp.Results...)
+		if resp.NextCursor == "" {
+			break
+		}
+		after = resp.NextCursor
+	}
+	if len(paged) != len(full.Results) {
+		t.Fatalf("expected %d paged results, got %d", len(full.Results), len(paged))
+	}
+	for i := range paged {
+		if paged[i].ChunkID != full.Results[i].ChunkID {
+			t.Fatalf("page order diverged at %d: %d vs %d", i, paged[i].ChunkID, full.Results[i].ChunkID)
+		}
+	}
+
+	first, err := Search(root, "alpha", Options{TopK: 2})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if _, err := Search(root, "beta", Options{After: first.NextCursor}); err == nil {
+		t.Fatalf("expected cursor from another query to be rejected")
+	}
+	if _, err := Search(root, "alpha", Options{After: "%%%"}); err == nil {
+		t.Fatalf("expected malformed cursor to be rejected")
+	}
+	stale := encodeCursor(cursor{Query: queryKey("alpha", 2, false), Generation: "0000000000000001", Score: full.Results[1].Score, ChunkID: full.Results[1].ChunkID})
+	if _, err := Search(root, "alpha", Options{After: stale}); err == nil || !strings.Contains(err.Error(), "generation") {
+		t.Fatalf("expected cursor from another generation to be rejected, got %v", err)
+	}
+}
+
+func TestSearchCollapseOverlappingChunks(t *testing.T) {
+	root := t.TempDir()
+	files := []index.FileEntry{{FileID: 1, Path: "same.ts"}, {FileID: 2, Path: "other.ts"}}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "same.ts", StartLine: 1, EndLine: 10, TokenCount: 10, Snippet: "alpha"},
+		{ChunkID: 2, FileID: 1, Path: "same.ts", StartLine: 5, EndLine: 12, TokenCount: 10, Snippet: "alpha alpha"},
+		{ChunkID: 3, FileID: 1, Path: "same.ts", StartLine: 13, EndLine: 20, TokenCount: 10, Snippet: "alpha"},
+		{ChunkID: 4, FileID: 2, Path: "other.ts", StartLine: 1, EndLine: 4, TokenCount: 10, Snippet: "alpha"},
+	}
+	postings := map[string][]index.Posting{
+		"alpha": {{ChunkID: 1, TF: 1}, {ChunkID: 2, TF: 3}, {ChunkID: 3, TF: 1}, {ChunkID: 4, TF: 1}},
+	}
+	createIndex(t, root, files, chunks, postings)
+
+	plain, err := Search(root, "alpha", Options{MaxPerFile: 10})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(plain.Results) != 4 {
+		t.Fatalf("expected 4 results without collapse, got %d", len(plain.Results))
+	}
+
+	resp, err := Search(root, "alpha", Options{MaxPerFile: 10, Collapse: true})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(resp.Results) != 3 {
+		t.Fatalf("expected overlapping chunks 1 and 2 collapsed, got %v", resp.Results)
+	}
+	top := resp.Results[0]
+	if top.ChunkID != 2 || top.StartLine != 1 || top.EndLine != 12 {
+		t.Fatalf("expected best chunk 2 with combined range 1-12, got %d %d-%d", top.ChunkID, top.StartLine, top.EndLine)
+	}
+	if len(top.ChunkIDs) != 2 || top.ChunkIDs[0] != 1 || top.ChunkIDs[1] != 2 {
+		t.Fatalf("expected chunk_ids [1 2], got %v", top.ChunkIDs)
+	}
+	for _, r := range resp.Results[1:] {
+		if r.ChunkIDs != nil {
+			t.Fatalf("adjacent or other-file chunks should not collapse: %+v", r)
+		}
+	}
+
+	first, err := Search(root, "alpha", Options{TopK: 1, MaxPerFile: 10, Collapse: true})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	rest, err := Search(root, "alpha", Options{MaxPerFile: 10, Collapse: true, After: first.NextCursor})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(first.Results) != 1 || first.Results[0].ChunkID != 2 || len(rest.Results) != 2 {
+		t.Fatalf("expected the collapsed group on the first page only, got %v then %v", first.Results, rest.Results)
+	}
+	for _, r := range rest.Results {
+		if r.ChunkID == 1 || r.ChunkID == 2 {
+			t.Fatalf("collapsed group repeated on the second page: %+v", r)
+		}
+	}
+
+	page, err := Search(root, "alpha", Options{TopK: 1, MaxPerFile: 10})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if _, err := Search(root, "alpha", Options{MaxPerFile: 10, Collapse: true, After: page.NextCursor}); err == nil {
+		t.Fatalf("expected a cursor issued without collapse to be rejected")
+	}
+}
+
+func TestSearchBM25PrefersFocusedShortChunk(t *testing.T) {
+	root := t.TempDir()
+	files := []index.FileEntry{
+		{FileID: 1, Path: "long.ts"},
+		{FileID: 2, Path: "short.ts"},
+		{FileID: 3, Path: "other.ts"},
+	}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "long.ts", StartLine: 1, EndLine: 200, TokenCount: 400, Snippet: "long"},
+		{ChunkID: 2, FileID: 2, Path: "short.ts", StartLine: 1, EndLine: 10, TokenCount: 20, Snippet: "short"},
+		{ChunkID: 3, FileID: 3, Path: "other.ts", StartLine: 1, EndLine: 10, TokenCount: 20, Snippet: "other"},
+	}
+	postings := map[string][]index.Posting{
+		"socket": {{ChunkID: 1, TF: 1}, {ChunkID: 2, TF: 6}},
+		"misc":   {{ChunkID: 3, TF: 1}},
+	}
+	createIndex(t, root, files, chunks, postings)
+
+	resp, err := Search(root, "socket", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	results := resp.Results
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].ChunkID != 2 {
+		t.Fatalf("expected short focused chunk first, got %d", results[0].ChunkID)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Fatalf("expected strictly higher score for focused chunk: %v", results)
+	}
+}
+
+func TestSearchBM25BZeroDisablesLengthNormalization(t *testing.T) {
+	root := t.TempDir()
+	files := []index.FileEntry{{FileID: 1, Path: "long.ts"}, {FileID: 2, Path: "short.ts"}}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "long.ts", StartLine: 1, EndLine: 200, TokenCount: 400, Snippet: "long"},
+		{ChunkID: 2, FileID: 2, Path: "short.ts", StartLine: 1, EndLine: 10, TokenCount: 20, Snippet: "short"},
+	}
+	createIndex(t, root, files, chunks, map[string][]index.Posting{
+		"socket": {{ChunkID: 1, TF: 2}, {ChunkID: 2, TF: 2}},
+	})
+	cfg, _, err := config.Load(store.ConfigPath(root))
+	if err != nil {
+		t.Fatalf("config load failed: %v", err)
+	}
+	zero := 0.0
+	cfg.Search.BM25B = &zero
+	if err := config.Save(store.ConfigPath(root), cfg); err != nil {
+		t.Fatalf("config save failed: %v", err)
+	}
+
+	resp, err := Search(root, "socket", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Score != resp.Results[1].Score {
+		t.Fatalf("expected equal scores without length normalization, got %+v", resp.Results)
+	}
+
+	bad := 1.5
+	cfg.Search.BM25B = &bad
+	if err := config.Save(store.ConfigPath(root), cfg); err != nil {
+		t.Fatalf("config save failed: %v", err)
+	}
+	if _, err := Search(root, "socket", Options{}); err == nil || !strings.Contains(err.Error(), "BM25B") {
+		t.Fatalf("expected BM25B out of range to be rejected, got %v", err)
+	}
+}
+
+func TestSearchWithIndexDefaultsUnsetBM25(t *testing.T) {
+	root := t.TempDir()
+	files := []index.FileEntry{{FileID: 1, Path: "long.ts"}, {FileID: 2, Path: "short.ts"}}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "long.ts", StartLine: 1, EndLine: 200, TokenCount: 400, Snippet: "long"},
+		{ChunkID: 2, FileID: 2, Path: "short.ts", StartLine: 1, EndLine: 10, TokenCount: 20, Snippet: "short"},
+	}
+	createIndex(t, root, files, chunks, map[string][]index.Posting{
+		"socket": {{ChunkID: 1, TF: 2}, {ChunkID: 2, TF: 2}},
+	})
+	reader, err := index.OpenReader(store.Current(root))
+	if err != nil {
+		t.Fatalf("open reader: %v", err)
+	}
+	defer reader.Close()
+	idx := Index{Chunks: reader.Chunks(), Terms: reader.Terms(), Postings: reader.Postings(), Files: func() ([]index.FileEntry, error) { return files, nil }}
+
+	want, err := SearchWithIndex(config.DefaultConfig(), idx, "socket", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	// A config built by hand, not by config.Load, leaves the BM25 fields unset.
+	cfg := config.DefaultConfig()
+	cfg.Search = config.SearchConfig{}
+	got, err := SearchWithIndex(cfg, idx, "socket", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(got.Results) != 2 || got.Results[0].Score != want.Results[0].Score || got.Results[1].Score != want.Results[1].Score {
+		t.Fatalf("expected the default BM25 scores %+v, got %+v", want.Results, got.Results)
+	}
+}
+
+func TestSearchPhraseAndProximity(t *testing.T) {
+	root := t.TempDir()
+	files := []index.FileEntry{
+		{FileID: 1, Path: "far.ts"},
+		{FileID: 2, Path: "near.ts"},
+	}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "far.ts", StartLine: 1, EndLine: 190, TokenCount: 50, Snippet: "far"},
+		{ChunkID: 2, FileID: 2, Path: "near.ts", StartLine: 1, EndLine: 5, TokenCount: 50, Snippet: "near"},
+	}
+	postings := map[string][]index.Posting{
+		"create": {{ChunkID: 1, TF: 1, Positions: []uint32{0}}, {ChunkID: 2, TF: 1, Positions: []uint32{10}}},
+		"server": {{ChunkID: 1, TF: 1, Positions: []uint32{40}}, {ChunkID: 2, TF: 1, Positions: []uint32{11}}},
+	}
+	createIndex(t, root, files, chunks, postings)
+
+	resp, err := Search(root, "create server", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	results := resp.Results
+	if len(results) != 2 || results[0].ChunkID != 2 {
+		t.Fatalf("expected adjacent terms to rank first, got %+v", results)
+	}
+	if results[0].Span != 1 || results[1].Span != 40 {
+		t.Fatalf("unexpected spans %d and %d", results[0].Span, results[1].Span)
+	}
+
+	resp, err = Search(root, `"create server"`, Options{})
+	if err != nil {
+		t.Fatalf("phrase search failed: %v", err)
+	}
+	results = resp.Results
+	if len(results) != 1 || results[0].ChunkID != 2 {
+		t.Fatalf("expected only the phrase match, got %+v", results)
+	}
+	if len(results[0].Phrases) != 1 || results[0].Phrases[0] != "create server" {
+		t.Fatalf("expected matched phrase to be reported, got %v", results[0].Phrases)
+	}
+
+	if _, err := Search(root, `"create server`, Options{}); err == nil {
+		t.Fatalf("expected error for unterminated phrase")
+	}
+}
+
+func TestSearchBooleanOperatorsAndFilters(t *testing.T) {
+	root := t.TempDir()
+	files := []index.FileEntry{
+		{FileID: 1, Path: "src/api/user.ts"},
+		{FileID: 2, Path: "src/api/user.test.ts"},
+		{FileID: 3, Path: "src/web/view.tsx"},
+	}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "src/api/user.ts", StartLine: 1, EndLine: 10, TokenCount: 20, Snippet: "api"},
+		{ChunkID: 2, FileID: 2, Path: "src/api/user.test.ts", StartLine: 1, EndLine: 10, TokenCount: 20, Snippet: "test"},
+		{ChunkID: 3, FileID: 3, Path: "src/web/view.tsx", StartLine: 1, EndLine: 10, TokenCount: 20, Snippet: "web"},
+	}
+	postings := map[string][]index.Posting{
+		"handler":    {{ChunkID: 1, TF: 1, Positions: []uint32{0}}, {ChunkID: 2, TF: 1, Positions: []uint32{0}}, {ChunkID: 3, TF: 1, Positions: []uint32{0}}},
+		"controller": {{ChunkID: 3, TF: 1, Positions: []uint32{1}}},
+		"mock":       {{ChunkID: 2, TF: 1, Positions: []uint32{1}}},
+	}
+	createIndex(t, root, files, chunks, postings)
+
+	cases := []struct {
+		q    string
+		want []uint32
+	}{
+		{q: "handler -mock", want: []uint32{1, 3}},
+		{q: "+handler +controller", want: []uint32{3}},
+		{q: "controller OR mock", want: []uint32{2, 3}},
+		{q: "+(controller OR mock) -path:src/web", want: []uint32{2}},
+		{q: "handler path:src/api/", want: []uint32{1, 2}},
+		{q: "handler ext:tsx", want: []uint32{3}},
+		{q: "handler file:test", want: []uint32{2}},
+		{q: "handler -file:test lang:ts", want: []uint32{1, 3}},
+		{q: "path:api", want: []uint32{1, 2}},
+		{q: "-path:web -file:test", want: []uint32{1}},
+		{q: "handler path:pi/", want: nil},
+		{q: "+controller\u00a0-mock", want: []uint32{3}},
+		{q: "\"handler\"\u3000ext:tsx", want: []uint32{3}},
+	}
+	for _, tc := range cases {
+		resp, err := Search(root, tc.q, Options{TopK: 10, MaxPerFile: 10})
+		if err != nil {
+			t.Fatalf("%q: search failed: %v", tc.q, err)
+		}
+		results := resp.Results
+		got := make([]uint32, 0, len(results))
+		for _, r := range results {
+			got = append(got, r.ChunkID)
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		if len(got) != len(tc.want) {
+			t.Fatalf("%q: expected %v, got %v", tc.q, tc.want, got)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%q: expected %v, got %v", tc.q, tc.want, got)
+			}
+		}
+	}
+
+	resp, err := Search(root, "handler controller", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	results := resp.Results
+	if len(results) != 3 || results[0].ChunkID != 3 {
+		t.Fatalf("expected chunk matching both optional terms first, got %+v", results)
+	}
+
+	for _, q := range []string{"(handler", "handler)", "OR handler", "handler OR", "+handler OR mock", "path:", "lang:cobol", "handler -"} {
+		_, err := Search(root, q, Options{})
+		var qerr *QueryError
+		if !errors.As(err, &qerr) {
+			t.Fatalf("%q: expected query error, got %v", q, err)
+		}
+	}
+}
+
+func TestSearchPrefixSuffixFuzzyExpansion(t *testing.T) {
+	root := t.TempDir()
+	files := []index.FileEntry{
+		{FileID: 1, Path: "auth.ts"},
+		{FileID: 2, Path: "mail.ts"},
+	}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "auth.ts", StartLine: 1, EndLine: 10, TokenCount: 10, Snippet: "auth"},
+		{ChunkID: 2, FileID: 2, Path: "mail.ts", StartLine: 1, EndLine: 10, TokenCount: 10, Snippet: "mail"},
+	}
+	postings := map[string][]index.Posting{
+		"authorize":   {{ChunkID: 1, TF: 1, Positions: []uint32{0}}},
+		"userservice": {{ChunkID: 1, TF: 1, Positions: []uint32{1}}},
+		"receive":     {{ChunkID: 2, TF: 1, Positions: []uint32{0}}},
+	}
+	createIndex(t, root, files, chunks, postings)
+
+	cases := []struct {
+		q       string
+		chunkID uint32
+		why     string
+	}{
+		{q: "auth*", chunkID: 1, why: "authorize"},
+		{q: "*Service", chunkID: 1, why: "userservice"},
+		{q: "recieve~", chunkID: 2, why: "receive"},
+		{q: "recieve~1", chunkID: 2, why: "receive"},
+	}
+	for _, tc := range cases {
+		resp, err := Search(root, tc.q, Options{})
+		if err != nil {
+			t.Fatalf("%q: search failed: %v", tc.q, err)
+		}
+		results := resp.Results
+		if len(results) != 1 || results[0].ChunkID != tc.chunkID {
+			t.Fatalf("%q: unexpected results %+v", tc.q, results)
+		}
+		if len(results[0].Why) != 1 || results[0].Why[0] != tc.why {
+			t.Fatalf("%q: expected expanded term in why, got %v", tc.q, results[0].Why)
+		}
+	}
+
+	resp, err := Search(root, "recieve", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	results := resp.Results
+	if len(results) != 0 {
+		t.Fatalf("plain misspelling should not expand, got %+v", results)
+	}
+
+	for _, q := range []string{"au*th", "recieve~3", "get-user*", "~"} {
+		_, err := Search(root, q, Options{})
+		var qerr *QueryError
+		if !errors.As(err, &qerr) {
+			t.Fatalf("%q: expected query error, got %v", q, err)
+		}
+	}
+}
+
+func TestSearchReportsDroppedTerms(t *testing.T) {
+	root := t.TempDir()
+	files := []index.FileEntry{{FileID: 1, Path: "a.ts"}}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "a.ts", StartLine: 1, EndLine: 5, TokenCount: 5, Snippet: "a"},
+	}
+	postings := map[string][]index.Posting{
+		"receive": {{ChunkID: 1, TF: 1, Positions: []uint32{0}}},
+		"recover": {{ChunkID: 1, TF: 1, Positions: []uint32{1}}},
+		"socket":  {{ChunkID: 1, TF: 1, Positions: []uint32{2}}},
+	}
+	createIndex(t, root, files, chunks, postings)
+
+	resp, err := Search(root, "socket recieve const 12345 ab deadbeefcafebabe zzz*", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("expected known term to still match, got %+v", resp.Results)
+	}
+	want := []DroppedTerm{
+		{Term: "const", Reason: "stop_word"},
+		{Term: "12345", Reason: "numeric"},
+		{Term: "ab", Reason: "too_short"},
+		{Term: "deadbeefcafebabe", Reason: "hex"},
+		{Term: "recieve", Reason: ReasonNotInIndex, Suggestions: []string{"receive"}},
+		{Term: "zzz*", Reason: ReasonNotInIndex},
+	}
+	if len(resp.Dropped) != len(want) {
+		t.Fatalf("expected %d dropped terms, got %+v", len(want), resp.Dropped)
+	}
+	for i, w := range want {
+		got := resp.Dropped[i]
+		if got.Term != w.Term || got.Reason != w.Reason || strings.Join(got.Suggestions, ",") != strings.Join(w.Suggestions, ",") {
+			t.Fatalf("dropped[%d]: expected %+v, got %+v", i, w, got)
+		}
+	}
+
+	resp, err = Search(root, "const", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if resp.Results == nil || len(resp.Results) != 0 || len(resp.Dropped) != 1 {
+		t.Fatalf("expected empty results with a dropped stop word, got %+v", resp)
+	}
+}
+
+func TestSearchHighlightsMatchedLines(t *testing.T) {
+	root := t.TempDir()
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, "// filler line")
+	}
+	lines[19] = "function createServer() {"
+	lines[20] = "  const socket = openSocket(server);"
+	source := []byte(strings.Join(lines, "\n"))
+	if err := os.WriteFile(filepath.Join(root, "net.ts"), source, 0o644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	files := []index.FileEntry{{FileID: 1, Path: "net.ts", Hash64: hash.Sum64(source)}}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "net.ts", StartLine: 1, EndLine: 30, TokenCount: 40, Snippet: "// filler line"},
+	}
+	postings := map[string][]index.Posting{
+		"server": {{ChunkID: 1, TF: 2, Positions: []uint32{30, 33}}},
+		"socket": {{ChunkID: 1, TF: 2, Positions: []uint32{31, 32}}},
+	}
+	createIndex(t, root, files, chunks, postings)
+
+	resp, err := Search(root, "socket server", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("expected one result, got %+v", resp.Results)
+	}
+	r := resp.Results[0]
+	if len(r.Highlights) != 2 || r.Highlights[0].Line != 20 || r.Highlights[1].Line != 21 {
+		t.Fatalf("unexpected highlights %+v", r.Highlights)
+	}
+	for _, h := range r.Highlights {
+		if len(h.Hits) == 0 {
+			t.Fatalf("highlight without hits: %+v", h)
+		}
+		for _, hit := range h.Hits {
+			if strings.ToLower(h.Text[hit.Start:hit.End]) != hit.Term {
+				t.Fatalf("hit %+v does not cover its term in %q", hit, h.Text)
+			}
+		}
+	}
+	if len(r.Highlights[1].Hits) != 3 {
+		t.Fatalf("expected socket, socket and server hits on line 21, got %+v", r.Highlights[1].Hits)
+	}
+	if r.Snippet != "// filler line\nfunction createServer() {\nconst socket = openSocket(server);" {
+		t.Fatalf("expected snippet centred on the match, got %q", r.Snippet)
+	}
+
+	// A file changed since the sync is highlighted from its snapshot, and not
+	// at all without one.
+	if err := os.WriteFile(filepath.Join(root, "net.ts"), []byte("const server = 1;\nconst socket = 2;\n"), 0o644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	resp, err = Search(root, "socket server", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if r := resp.Results[0]; r.Highlights != nil || r.Snippet != "// filler line" {
+		t.Fatalf("expected no highlights for a stale file without a snapshot, got %+v", r)
+	}
+	snapshot, err := index.CompressSnapshot(source)
+	if err != nil {
+		t.Fatalf("compress snapshot: %v", err)
+	}
+	if err := index.WriteSnapshots(store.SnapshotsPath(root), []index.SnapshotEntry{{FileID: 1, Data: snapshot}}); err != nil {
+		t.Fatalf("write snapshots: %v", err)
+	}
+	resp, err = Search(root, "socket server", Options{})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if r := resp.Results[0]; len(r.Highlights) != 2 || r.Highlights[1].Line != 21 {
+		t.Fatalf("expected highlights from the snapshot, got %+v", r.Highlights)
+	}
+}
+
+func createIndex(t *testing.T, root string, files []index.FileEntry, chunks []index.ChunkEntry, postings map[string][]index.Posting) {
 	t.Helper()
 	if err := os.MkdirAll(store.Dir(root), 0o755); err != nil {
 		t.Fatalf("mkdir failed: %v", err)
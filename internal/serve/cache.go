@@ -5,41 +5,59 @@ import (
 
 	"github.com/memkit/repodex/internal/config"
 	"github.com/memkit/repodex/internal/index"
-	"github.com/memkit/repodex/internal/lang"
 	"github.com/memkit/repodex/internal/lang/factory"
 	"github.com/memkit/repodex/internal/store"
 )
 
 // IndexCache holds preloaded index data for reuse within the serve process.
+// While loaded it holds a lease on the generation it read, so a concurrent
+// sync cannot delete artifacts (such as snapshots.dat) still referenced lazily.
 type IndexCache struct {
 	mu       sync.Mutex
 	loaded   bool
+	gen      store.Generation
+	lease    *store.Lease
 	cfg      config.Config
-	cfgBytes []byte
-	plugin   lang.LanguagePlugin
 	chunks   []index.ChunkEntry
 	chunkMap map[uint32]index.ChunkEntry
-	terms    map[string]index.TermInfo
-	postings []uint32
+	list     *index.ChunkList
+	terms    *index.TermDict
+	postings *index.Postings
+	symbols  []index.SymbolEntry
+	files    []index.FileEntry
+	imports  []index.ImportEntry
 }
 
-// Load populates the cache if it is not already loaded.
+// Load populates the cache, or reloads it when meta.json names a generation
+// other than the cached one, as after a sync by the CLI or another process.
+// Checking costs a read of meta.json; the lease on the replaced generation is
+// dropped only once the new one is loaded.
 func (c *IndexCache) Load(root string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.loaded {
+	if c.loaded && store.Current(root) == c.gen {
 		return nil
 	}
 
-	cfg, cfgBytes, err := config.Load(store.ConfigPath(root))
+	cfg, _, err := config.Load(store.ConfigPath(root))
 	if err != nil {
 		return err
 	}
-	plugin, err := factory.FromProjectType(cfg.ProjectType)
+	// Fail on an unknown project type here, as a sync would.
+	if _, err := factory.FromProjectType(cfg.ProjectType); err != nil {
+		return err
+	}
+	gen, lease, err := store.AcquireCurrent(root)
 	if err != nil {
 		return err
 	}
-	chunks, err := index.LoadChunkEntries(store.ChunksPath(root))
+	loaded := false
+	defer func() {
+		if !loaded {
+			lease.Release()
+		}
+	}()
+	chunks, err := index.LoadChunkEntries(gen.ChunksPath())
 	if err != nil {
 		return err
 	}
@@ -47,22 +65,40 @@ func (c *IndexCache) Load(root string) error {
 	for _, ch := range chunks {
 		chunkMap[ch.ChunkID] = ch
 	}
-	terms, _, err := index.LoadTerms(store.TermsPath(root))
+	terms, err := index.LoadTermDict(gen.TermsPath())
+	if err != nil {
+		return err
+	}
+	postings, err := index.LoadPostings(gen.PostingsPath(), gen.PositionsPath())
+	if err != nil {
+		return err
+	}
+	symbols, err := index.LoadSymbols(gen.SymbolsPath())
+	if err != nil {
+		return err
+	}
+	files, err := index.LoadFileEntries(gen.FilesPath())
 	if err != nil {
 		return err
 	}
-	postings, err := index.LoadPostings(store.PostingsPath(root))
+	imports, err := index.LoadImports(gen.ImportsPath())
 	if err != nil {
 		return err
 	}
 
+	loaded = true
+	c.lease.Release()
+	c.gen = gen
+	c.lease = lease
 	c.cfg = cfg
-	c.cfgBytes = cfgBytes
-	c.plugin = plugin
 	c.chunks = chunks
 	c.chunkMap = chunkMap
+	c.list = index.NewChunkList(chunks)
 	c.terms = terms
 	c.postings = postings
+	c.symbols = symbols
+	c.files = files
+	c.imports = imports
 	c.loaded = true
 	return nil
 }
@@ -72,35 +108,88 @@ func (c *IndexCache) Invalidate() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.loaded = false
+	c.lease.Release()
+	c.lease = nil
+	c.gen = store.Generation{}
 	c.cfg = config.Config{}
-	c.cfgBytes = nil
-	c.plugin = nil
 	c.chunks = nil
 	c.chunkMap = nil
+	c.list = nil
 	c.terms = nil
 	c.postings = nil
+	c.symbols = nil
+	c.files = nil
+	c.imports = nil
+}
+
+// Config returns the configuration the cache was loaded with.
+func (c *IndexCache) Config() config.Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg
 }
 
-// Get returns cached index components.
-func (c *IndexCache) Get() (config.Config, []byte, lang.LanguagePlugin, []index.ChunkEntry, map[uint32]index.ChunkEntry, map[string]index.TermInfo, []uint32) {
+// Terms returns the cached term dictionary, which is immutable and shared.
+func (c *IndexCache) Terms() *index.TermDict {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	return c.terms
+}
 
-	cfgCopy := c.cfg
-	cfgBytesCopy := make([]byte, len(c.cfgBytes))
-	copy(cfgBytesCopy, c.cfgBytes)
-	chunksCopy := make([]index.ChunkEntry, len(c.chunks))
-	copy(chunksCopy, c.chunks)
-	chunkMapCopy := make(map[uint32]index.ChunkEntry, len(c.chunkMap))
-	for k, v := range c.chunkMap {
-		chunkMapCopy[k] = v
-	}
-	termsCopy := make(map[string]index.TermInfo, len(c.terms))
-	for k, v := range c.terms {
-		termsCopy[k] = v
-	}
-	postingsCopy := make([]uint32, len(c.postings))
-	copy(postingsCopy, c.postings)
+// Postings returns the cached postings, which are immutable and shared.
+func (c *IndexCache) Postings() *index.Postings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.postings
+}
 
-	return cfgCopy, cfgBytesCopy, c.plugin, chunksCopy, chunkMapCopy, termsCopy, postingsCopy
+// ChunkMap returns the cached chunks by id. It is never mutated, so the map
+// is shared like Symbols.
+func (c *IndexCache) ChunkMap() map[uint32]index.ChunkEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.chunkMap
+}
+
+// ChunkEntries returns the cached chunks in id order, shared like Symbols.
+func (c *IndexCache) ChunkEntries() []index.ChunkEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.chunks
+}
+
+// Generation returns the index generation the cache was loaded from.
+func (c *IndexCache) Generation() store.Generation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// Symbols returns the cached symbol table. Entries are never mutated, so the
+// slice is shared.
+func (c *IndexCache) Symbols() []index.SymbolEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.symbols
+}
+
+// Chunks returns the cached chunks indexed for search, shared like Symbols.
+func (c *IndexCache) Chunks() index.Chunks {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list
+}
+
+// Graph returns the cached file entries and import edges, shared like Symbols.
+func (c *IndexCache) Graph() ([]index.FileEntry, []index.ImportEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.files, c.imports
+}
+
+// Files returns the cached file entries, shared like Symbols.
+func (c *IndexCache) Files() []index.FileEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.files
 }
@@ -0,0 +1,63 @@
+package serve
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/memkit/repodex/internal/store"
+)
+
+func TestIndexCacheFollowsPublishedGeneration(t *testing.T) {
+	root := t.TempDir()
+	buildTestIndex(t, root)
+
+	cache := &IndexCache{}
+	t.Cleanup(cache.Invalidate)
+	if err := cache.Load(root); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if name := cache.Generation().Name; name != "" {
+		t.Fatalf("expected the flat layout, got generation %q", name)
+	}
+
+	// Publish a copy of the index as another process's sync would.
+	gen, lease, err := store.NewGeneration(root)
+	if err != nil {
+		t.Fatalf("new generation: %v", err)
+	}
+	defer lease.Release()
+	for _, name := range []string{store.FilesFile, store.ChunksFile, store.TermsFile, store.PostingsFile, store.PositionsFile, store.SymbolsFile, store.ImportsFile} {
+		data, err := os.ReadFile(filepath.Join(store.Dir(root), name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(gen.Dir, name), data, 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	meta, err := store.LoadMeta(store.MetaPath(root))
+	if err != nil {
+		t.Fatalf("load meta: %v", err)
+	}
+	if err := store.Publish(root, gen, meta); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	if err := cache.Load(root); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := cache.Generation().Name; got != gen.Name {
+		t.Fatalf("expected the cache to move to %q, got %q", gen.Name, got)
+	}
+	if cache.Chunks().Len() == 0 {
+		t.Fatalf("expected chunks from the new generation")
+	}
+	flatLeases, err := os.ReadDir(filepath.Join(store.LeasesDir(root), "flat"))
+	if err != nil {
+		t.Fatalf("read flat leases: %v", err)
+	}
+	if len(flatLeases) != 0 {
+		t.Fatalf("expected the lease on the replaced layout to be released, found %d", len(flatLeases))
+	}
+}
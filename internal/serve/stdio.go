@@ -4,13 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 
+	"github.com/memkit/repodex/internal/depgraph"
 	"github.com/memkit/repodex/internal/fetch"
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/lockx"
 	"github.com/memkit/repodex/internal/search"
+	"github.com/memkit/repodex/internal/symbols"
 )
 
 // MaxRequestBytes limits the size of a single stdio request line.
@@ -18,22 +23,75 @@ const MaxRequestBytes = 1 << 20
 
 // Request describes a stdio request.
 type Request struct {
-	Op       string   `json:"op"`
-	Q        string   `json:"q,omitempty"`
-	TopK     int      `json:"top_k,omitempty"`
-	IDs      []uint32 `json:"ids,omitempty"`
-	MaxLines int      `json:"max_lines,omitempty"`
-	JSON     bool     `json:"json,omitempty"`
+	Op         string `json:"op"`
+	Q          string `json:"q,omitempty"`
+	TopK       int    `json:"top_k,omitempty"`
+	MaxPerFile int    `json:"max_per_file,omitempty"`
+	Collapse   bool   `json:"collapse,omitempty"`
+	// Cursor is an alias of a search's after.
+	Cursor    string   `json:"cursor,omitempty"`
+	IDs       []uint32 `json:"ids,omitempty"`
+	MaxLines  int      `json:"max_lines,omitempty"`
+	JSON      bool     `json:"json,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	Kind      string   `json:"kind,omitempty"`
+	Path      string   `json:"path,omitempty"`
+	Line      int      `json:"line,omitempty"`
+	StartLine int      `json:"start_line,omitempty"`
+	EndLine   int      `json:"end_line,omitempty"`
+	Before    int      `json:"before,omitempty"`
+	// After is read per op: the next_cursor string of the previous page for
+	// search, a count of context lines for fetch and fetch_range.
+	After     json.RawMessage `json:"after,omitempty"`
+	Mode      string          `json:"mode,omitempty"`
+	Rev       string          `json:"rev,omitempty"`
+	MaxBytes  int             `json:"max_bytes,omitempty"`
+	MaxTokens int             `json:"max_tokens,omitempty"`
+}
+
+// searchCursor returns the cursor a search resumes from: after, or the
+// cursor alias when after is absent.
+func (r Request) searchCursor() (string, error) {
+	if len(r.After) == 0 {
+		return r.Cursor, nil
+	}
+	var cursor string
+	if err := json.Unmarshal(r.After, &cursor); err != nil {
+		return "", fmt.Errorf("after must be the next_cursor string of the previous page")
+	}
+	if r.Cursor != "" && r.Cursor != cursor {
+		return "", fmt.Errorf("after and cursor name different pages")
+	}
+	return cursor, nil
+}
+
+// contextAfter returns the context lines a fetch adds below each range.
+func (r Request) contextAfter() (int, error) {
+	if len(r.After) == 0 {
+		return 0, nil
+	}
+	var n int
+	if err := json.Unmarshal(r.After, &n); err != nil {
+		return 0, fmt.Errorf("after must be a line count")
+	}
+	return n, nil
 }
 
 // Response describes a stdio response.
 type Response struct {
-	OK    bool        `json:"ok"`
-	Op    string      `json:"op"`
-	Error string      `json:"error,omitempty"`
-	Data  interface{} `json:"data,omitempty"`
+	OK    bool   `json:"ok"`
+	Op    string `json:"op"`
+	Error string `json:"error,omitempty"`
+	// Code classifies errors a client may want to handle, such as retrying
+	// a sync later; it is empty for other errors.
+	Code string      `json:"code,omitempty"`
+	Data interface{} `json:"data,omitempty"`
 }
 
+// CodeSyncInProgress is returned by the sync op while another process (or an
+// earlier request) holds the sync lock. Data then holds the lock owner.
+const CodeSyncInProgress = "sync_in_progress"
+
 // ServeStdio runs the JSONL stdio server.
 func ServeStdio(root string, statusFn func() (interface{}, error), syncFn func() (interface{}, error)) error {
 	reader := bufio.NewReader(os.Stdin)
@@ -78,6 +136,11 @@ func ServeStdio(root string, statusFn func() (interface{}, error), syncFn func()
 			if err != nil {
 				resp.OK = false
 				resp.Error = err.Error()
+				var held *lockx.HeldError
+				if errors.As(err, &held) {
+					resp.Code = CodeSyncInProgress
+					resp.Data = held.Owner
+				}
 				break
 			}
 			cache.Invalidate()
@@ -88,18 +151,36 @@ func ServeStdio(root string, statusFn func() (interface{}, error), syncFn func()
 				resp.Error = "invalid search request: q is required"
 				break
 			}
+			cursor, err := req.searchCursor()
+			if err != nil {
+				resp.OK = false
+				resp.Error = "invalid search request: " + err.Error()
+				break
+			}
 			if err := cache.Load(root); err != nil {
 				resp.OK = false
 				resp.Error = err.Error()
 				break
 			}
-			cfg, _, plugin, chunks, chunkMap, terms, postings := cache.Get()
-			results, err := search.SearchWithIndex(cfg, plugin, chunks, chunkMap, terms, postings, req.Q, search.Options{TopK: req.TopK})
+			cfg := cache.Config()
+			idx := search.Index{
+				Chunks:     cache.Chunks(),
+				Terms:      cache.Terms(),
+				Postings:   cache.Postings(),
+				Generation: cache.Generation().Name,
+				Files:      func() ([]index.FileEntry, error) { return cache.Files(), nil },
+			}
+			results, err := search.SearchWithIndex(cfg, idx, req.Q, search.Options{TopK: req.TopK, MaxPerFile: req.MaxPerFile, After: cursor, Collapse: req.Collapse})
 			if err != nil {
 				resp.OK = false
 				resp.Error = err.Error()
 				break
 			}
+			if err := search.HighlightResults(root, cfg, cache.Files(), cache.Generation().SnapshotsPath(), results.Results); err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
 			resp.Data = results
 		case "fetch":
 			if len(req.IDs) == 0 {
@@ -107,23 +188,218 @@ func ServeStdio(root string, statusFn func() (interface{}, error), syncFn func()
 				resp.Error = "invalid fetch request: ids are required"
 				break
 			}
-			ids := req.IDs
-			if len(ids) > 5 {
-				ids = ids[:5]
+			if req.Cursor != "" {
+				resp.OK = false
+				resp.Error = "invalid fetch request: cursor only applies to search"
+				break
+			}
+			after, err := req.contextAfter()
+			if err != nil {
+				resp.OK = false
+				resp.Error = "invalid fetch request: " + err.Error()
+				break
 			}
 			if err := cache.Load(root); err != nil {
 				resp.OK = false
 				resp.Error = err.Error()
 				break
 			}
-			_, _, _, _, chunkMap, _, _ := cache.Get()
-			results, err := fetch.FetchWithChunkMap(root, chunkMap, ids, req.MaxLines)
+			cfg := cache.Config()
+			results, err := fetch.FetchWithChunkMap(root, cache.ChunkMap(), cache.Files(), cache.Symbols(), req.IDs, fetch.Options{MaxLines: req.MaxLines, Before: req.Before, After: after, Mode: req.Mode, Rev: req.Rev, MaxBytes: req.MaxBytes, MaxTokens: req.MaxTokens, SnapshotsPath: cache.Generation().SnapshotsPath()}, cfg.Limits)
 			if err != nil {
 				resp.OK = false
 				resp.Error = err.Error()
 				break
 			}
 			resp.Data = results
+		case "fetch_range":
+			if strings.TrimSpace(req.Path) == "" {
+				resp.OK = false
+				resp.Error = "invalid fetch_range request: path is required"
+				break
+			}
+			if req.StartLine < 1 {
+				resp.OK = false
+				resp.Error = "invalid fetch_range request: start_line is required"
+				break
+			}
+			if req.Cursor != "" {
+				resp.OK = false
+				resp.Error = "invalid fetch_range request: cursor only applies to search"
+				break
+			}
+			after, err := req.contextAfter()
+			if err != nil {
+				resp.OK = false
+				resp.Error = "invalid fetch_range request: " + err.Error()
+				break
+			}
+			if err := cache.Load(root); err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			cfg := cache.Config()
+			result, err := fetch.FetchRangeWithFiles(root, cache.Files(), req.Path, req.StartLine, req.EndLine, fetch.Options{MaxLines: req.MaxLines, Before: req.Before, After: after, Rev: req.Rev, MaxBytes: req.MaxBytes, MaxTokens: req.MaxTokens}, cfg.Limits)
+			if err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			resp.Data = result
+		case "git_diff":
+			if len(req.IDs) == 0 && strings.TrimSpace(req.Path) == "" {
+				resp.OK = false
+				resp.Error = "invalid git_diff request: ids or path is required"
+				break
+			}
+			if len(req.IDs) > 0 && strings.TrimSpace(req.Path) != "" {
+				resp.OK = false
+				resp.Error = "invalid git_diff request: ids and path are mutually exclusive"
+				break
+			}
+			if len(req.IDs) == 0 && req.StartLine < 1 {
+				resp.OK = false
+				resp.Error = "invalid git_diff request: start_line is required with path"
+				break
+			}
+			if err := cache.Load(root); err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			rev := req.Rev
+			if rev == "" {
+				base, err := fetch.BaseRev(root)
+				if err != nil {
+					resp.OK = false
+					resp.Error = err.Error()
+					break
+				}
+				rev = base
+			}
+			cfg := cache.Config()
+			var data interface{}
+			var err error
+			if len(req.IDs) > 0 {
+				data, err = fetch.DiffChunksWithChunkMap(root, cache.ChunkMap(), req.IDs, rev, cfg.Limits)
+			} else {
+				data, err = fetch.DiffRangeWithFiles(root, cache.Files(), req.Path, req.StartLine, req.EndLine, rev)
+			}
+			if err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			resp.Data = data
+		case "symbols":
+			if strings.TrimSpace(req.Name) == "" && strings.TrimSpace(req.Kind) == "" {
+				resp.OK = false
+				resp.Error = "invalid symbols request: name or kind is required"
+				break
+			}
+			if err := cache.Load(root); err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			cfg := cache.Config()
+			results, err := symbols.Find(cache.Symbols(), symbols.Query{Name: req.Name, Kind: req.Kind}, cfg.Limits.MaxTopK)
+			if err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			resp.Data = results
+		case "definition":
+			if strings.TrimSpace(req.Name) == "" {
+				resp.OK = false
+				resp.Error = "invalid definition request: name is required"
+				break
+			}
+			if err := cache.Load(root); err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			cfg := cache.Config()
+			results, err := symbols.Definitions(cache.Symbols(), symbols.Target{Name: req.Name, Path: req.Path, Line: req.Line}, cfg.Limits.MaxTopK)
+			if err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			resp.Data = results
+		case "references":
+			if strings.TrimSpace(req.Name) == "" {
+				resp.OK = false
+				resp.Error = "invalid references request: name is required"
+				break
+			}
+			if err := cache.Load(root); err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			cfg := cache.Config()
+			idx := symbols.Index{
+				Chunks:        cache.Chunks(),
+				Terms:         cache.Terms(),
+				Postings:      cache.Postings(),
+				Symbols:       cache.Symbols(),
+				Files:         cache.Files(),
+				SnapshotsPath: cache.Generation().SnapshotsPath(),
+			}
+			results, err := symbols.References(root, cfg, idx, symbols.Target{Name: req.Name, Path: req.Path, Line: req.Line})
+			if err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			resp.Data = results
+		case "imports", "importers":
+			if strings.TrimSpace(req.Path) == "" {
+				resp.OK = false
+				resp.Error = fmt.Sprintf("invalid %s request: path is required", req.Op)
+				break
+			}
+			if err := cache.Load(root); err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			files, edges := cache.Graph()
+			var data interface{}
+			var err error
+			if req.Op == "imports" {
+				data, err = depgraph.Imports(files, edges, req.Path)
+			} else {
+				data, err = depgraph.Importers(files, edges, req.Path)
+			}
+			if err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			resp.Data = data
+		case "outline":
+			if strings.TrimSpace(req.Path) == "" {
+				resp.OK = false
+				resp.Error = "invalid outline request: path is required"
+				break
+			}
+			if err := cache.Load(root); err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			outline, err := symbols.Outline(cache.Files(), cache.ChunkEntries(), cache.Symbols(), req.Path)
+			if err != nil {
+				resp.OK = false
+				resp.Error = err.Error()
+				break
+			}
+			resp.Data = outline
 		default:
 			resp.OK = false
 			resp.Error = "unknown op"
@@ -11,19 +11,24 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
 
 	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/depgraph"
 	"github.com/memkit/repodex/internal/fetch"
 	"github.com/memkit/repodex/internal/hash"
 	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/lang"
 	"github.com/memkit/repodex/internal/lang/factory"
 	"github.com/memkit/repodex/internal/profile"
 	"github.com/memkit/repodex/internal/scan"
 	"github.com/memkit/repodex/internal/search"
 	"github.com/memkit/repodex/internal/store"
+	"github.com/memkit/repodex/internal/symbols"
+	"github.com/memkit/repodex/internal/textutil"
 )
 
 var ioMu sync.Mutex
@@ -128,7 +133,7 @@ func TestServeStdioIntegration(t *testing.T) {
 	if !searchResp.resp.OK || searchResp.resp.Op != "search" {
 		t.Fatalf("unexpected search response: %s", searchResp.raw)
 	}
-	searchResults := parseSearchResults(t, searchResp.resp.Data)
+	searchResults := parseSearchResponse(t, searchResp.resp.Data).Results
 	if len(searchResults) == 0 {
 		t.Fatalf("expected search results")
 	}
@@ -136,6 +141,141 @@ func TestServeStdioIntegration(t *testing.T) {
 	if first.ChunkID == 0 || first.Path == "" {
 		t.Fatalf("incomplete search result: %+v", first)
 	}
+	if len(first.Highlights) == 0 || len(first.Highlights[0].Hits) == 0 {
+		t.Fatalf("expected highlighted lines in search result: %+v", first)
+	}
+
+	writeRequest(t, stdinW, `{"op":"search","q":"summary","top_k":5}`+"\n")
+	full := parseSearchResponse(t, readResponse(t, respCh).resp.Data).Results
+	if len(full) < 2 {
+		t.Fatalf("expected several results to page through, got %+v", full)
+	}
+	writeRequest(t, stdinW, `{"op":"search","q":"summary","top_k":1}`+"\n")
+	pageResp := readResponse(t, respCh)
+	page := parseSearchResponse(t, pageResp.resp.Data)
+	if !pageResp.resp.OK || len(page.Results) != 1 || page.NextCursor == "" {
+		t.Fatalf("expected a first page with a cursor: %s", pageResp.raw)
+	}
+	writeRequest(t, stdinW, fmt.Sprintf(`{"op":"search","q":"summary","top_k":1,"after":%q}`, page.NextCursor)+"\n")
+	nextResp := readResponse(t, respCh)
+	next := parseSearchResponse(t, nextResp.resp.Data)
+	if !nextResp.resp.OK || len(next.Results) != 1 || next.Results[0].ChunkID != full[1].ChunkID {
+		t.Fatalf("expected after to resume at the second result %d: %s", full[1].ChunkID, nextResp.raw)
+	}
+
+	writeRequest(t, stdinW, `{"op":"search","q":"helpr const"}`+"\n")
+	droppedResp := readResponse(t, respCh)
+	if !droppedResp.resp.OK {
+		t.Fatalf("unexpected search response: %s", droppedResp.raw)
+	}
+	dropped := parseSearchResponse(t, droppedResp.resp.Data).Dropped
+	if len(dropped) != 2 || dropped[0].Term != "const" || dropped[0].Reason != "stop_word" {
+		t.Fatalf("expected stop word to be reported first, got %+v", dropped)
+	}
+	if dropped[1].Term != "helpr" || dropped[1].Reason != search.ReasonNotInIndex || len(dropped[1].Suggestions) == 0 || dropped[1].Suggestions[0] != "helper" {
+		t.Fatalf("expected did-you-mean suggestion, got %+v", dropped[1])
+	}
+
+	writeRequest(t, stdinW, `{"op":"search","q":"(alpha OR"}`+"\n")
+	badQueryResp := readResponse(t, respCh)
+	if badQueryResp.resp.OK || badQueryResp.resp.Op != "search" {
+		t.Fatalf("malformed query should fail: %s", badQueryResp.raw)
+	}
+	if !strings.HasPrefix(badQueryResp.resp.Error, "invalid query: ") {
+		t.Fatalf("unexpected malformed query error: %s", badQueryResp.resp.Error)
+	}
+
+	writeRequest(t, stdinW, `{"op":"symbols","name":"alphahelper"}`+"\n")
+	symbolsResp := readResponse(t, respCh)
+	if !symbolsResp.resp.OK || symbolsResp.resp.Op != "symbols" {
+		t.Fatalf("unexpected symbols response: %s", symbolsResp.raw)
+	}
+	found := parseSymbols(t, symbolsResp.resp.Data)
+	if len(found) != 1 || found[0].Name != "alphaHelper" || found[0].Kind != lang.KindFunction ||
+		!found[0].Exported || found[0].Path != "src/sample.ts" || found[0].StartLine != 16 || found[0].EndLine != 18 {
+		t.Fatalf("unexpected symbols: %+v", found)
+	}
+
+	writeRequest(t, stdinW, `{"op":"definition","name":"alphaBetaValue","path":"src/sample.ts","line":17}`+"\n")
+	definitionResp := readResponse(t, respCh)
+	if !definitionResp.resp.OK || definitionResp.resp.Op != "definition" {
+		t.Fatalf("unexpected definition response: %s", definitionResp.raw)
+	}
+	defs := parseSymbols(t, definitionResp.resp.Data)
+	if len(defs) != 1 || defs[0].StartLine != 8 || defs[0].Kind != lang.KindFunction {
+		t.Fatalf("unexpected definitions: %+v", defs)
+	}
+
+	writeRequest(t, stdinW, `{"op":"references","name":"alphaBetaValue"}`+"\n")
+	referencesResp := readResponse(t, respCh)
+	if !referencesResp.resp.OK || referencesResp.resp.Op != "references" {
+		t.Fatalf("unexpected references response: %s", referencesResp.raw)
+	}
+	raw, err := json.Marshal(referencesResp.resp.Data)
+	if err != nil {
+		t.Fatalf("marshal references: %v", err)
+	}
+	var refs symbols.ReferencesResponse
+	if err := json.Unmarshal(raw, &refs); err != nil {
+		t.Fatalf("unmarshal references: %v", err)
+	}
+	var refLines []uint32
+	for _, r := range refs.References {
+		refLines = append(refLines, r.Line)
+	}
+	if fmt.Sprint(refLines) != "[8 14 17]" || !refs.References[0].Definition || refs.References[1].Definition {
+		t.Fatalf("unexpected references: %+v", refs)
+	}
+	if refs.References[1].Column != 39 || refs.References[1].Path != "src/sample.ts" {
+		t.Fatalf("unexpected reference position: %+v", refs.References[1])
+	}
+
+	writeRequest(t, stdinW, `{"op":"importers","path":"src/sample.ts"}`+"\n")
+	importersResp := readResponse(t, respCh)
+	if !importersResp.resp.OK || importersResp.resp.Op != "importers" {
+		t.Fatalf("unexpected importers response: %s", importersResp.raw)
+	}
+	raw, err = json.Marshal(importersResp.resp.Data)
+	if err != nil {
+		t.Fatalf("marshal importers: %v", err)
+	}
+	var importers depgraph.FileImporters
+	if err := json.Unmarshal(raw, &importers); err != nil {
+		t.Fatalf("unmarshal importers: %v", err)
+	}
+	if len(importers.Importers) != 1 || importers.Importers[0].Path != "src/report.ts" || importers.Importers[0].Specifier != "./sample" {
+		t.Fatalf("unexpected importers: %+v", importers)
+	}
+
+	writeRequest(t, stdinW, `{"op":"outline","path":"src/sample.ts"}`+"\n")
+	outlineResp := readResponse(t, respCh)
+	if !outlineResp.resp.OK || outlineResp.resp.Op != "outline" {
+		t.Fatalf("unexpected outline response: %s", outlineResp.raw)
+	}
+	raw, err = json.Marshal(outlineResp.resp.Data)
+	if err != nil {
+		t.Fatalf("marshal outline: %v", err)
+	}
+	var outline symbols.FileOutline
+	if err := json.Unmarshal(raw, &outline); err != nil {
+		t.Fatalf("unmarshal outline: %v", err)
+	}
+	var names []string
+	for _, sym := range outline.Symbols {
+		if len(sym.ChunkIDs) == 0 {
+			t.Fatalf("outline entry without chunks: %+v", sym)
+		}
+		names = append(names, sym.Name)
+	}
+	if strings.Join(names, ",") != "Example,alphaBetaValue,totals,doubled,alphaHelper,betaHelper,summary" || len(outline.Chunks) == 0 {
+		t.Fatalf("unexpected outline: %+v", outline)
+	}
+
+	writeRequest(t, stdinW, `{"op":"imports","path":"src/missing.ts"}`+"\n")
+	missingResp := readResponse(t, respCh)
+	if missingResp.resp.OK || missingResp.resp.Error != "path not indexed: src/missing.ts" {
+		t.Fatalf("unexpected imports response for unknown path: %s", missingResp.raw)
+	}
 
 	fetchPayload := fmt.Sprintf(`{"op":"fetch","ids":[%d],"max_lines":120}`, first.ChunkID) + "\n"
 	writeRequest(t, stdinW, fetchPayload)
@@ -166,6 +306,42 @@ func TestServeStdioIntegration(t *testing.T) {
 		}
 	}
 
+	writeRequest(t, stdinW, `{"op":"fetch_range","path":"src/sample.ts","start_line":9,"end_line":10,"before":1,"after":1}`+"\n")
+	rangeResp := readResponse(t, respCh)
+	if !rangeResp.resp.OK || rangeResp.resp.Op != "fetch_range" {
+		t.Fatalf("unexpected fetch_range response: %s", rangeResp.raw)
+	}
+	raw, err = json.Marshal(rangeResp.resp.Data)
+	if err != nil {
+		t.Fatalf("marshal fetch_range: %v", err)
+	}
+	var fetchedRange fetch.RangeText
+	if err := json.Unmarshal(raw, &fetchedRange); err != nil {
+		t.Fatalf("unmarshal fetch_range: %v", err)
+	}
+	if fetchedRange.ReturnedFrom != 8 || fetchedRange.ReturnedTo != 11 || len(fetchedRange.Lines) != 4 ||
+		fetchedRange.Lines[0] != "8| export function alphaBetaValue(input: number) {" || fetchedRange.Lines[3] != "11| }" {
+		t.Fatalf("unexpected fetch_range result: %+v", fetchedRange)
+	}
+
+	writeRequest(t, stdinW, `{"op":"fetch_range","path":"src/sample.ts","start_line":8,"end_line":11,"max_lines":3}`+"\n")
+	cutResp := readResponse(t, respCh)
+	if !cutResp.resp.OK || !strings.Contains(cutResp.raw, `"truncated":true,"next":{"start_line":11,"end_line":11}`) {
+		t.Fatalf("expected truncated range with continuation: %s", cutResp.raw)
+	}
+
+	writeRequest(t, stdinW, fmt.Sprintf(`{"op":"fetch","ids":[%d],"mode":"whole"}`, first.ChunkID)+"\n")
+	modeResp := readResponse(t, respCh)
+	if modeResp.resp.OK || modeResp.resp.Error != `unknown fetch mode "whole"` {
+		t.Fatalf("expected unknown mode error: %s", modeResp.raw)
+	}
+
+	writeRequest(t, stdinW, `{"op":"fetch_range","path":"src/../../etc/passwd","start_line":1}`+"\n")
+	escapeResp := readResponse(t, respCh)
+	if escapeResp.resp.OK || !strings.Contains(escapeResp.resp.Error, "path traversal detected") {
+		t.Fatalf("expected traversal rejection, got: %s", escapeResp.raw)
+	}
+
 	if err := stdinW.Close(); err != nil {
 		t.Fatalf("close stdin writer: %v", err)
 	}
@@ -256,17 +432,17 @@ func readResponse(t *testing.T, ch <-chan responseLine) responseLine {
 	return resp
 }
 
-func parseSearchResults(t *testing.T, data interface{}) []search.Result {
+func parseSearchResponse(t *testing.T, data interface{}) search.Response {
 	t.Helper()
 	raw, err := json.Marshal(data)
 	if err != nil {
 		t.Fatalf("marshal search data: %v", err)
 	}
-	var results []search.Result
-	if err := json.Unmarshal(raw, &results); err != nil {
+	var resp search.Response
+	if err := json.Unmarshal(raw, &resp); err != nil {
 		t.Fatalf("unmarshal search data: %v", err)
 	}
-	return results
+	return resp
 }
 
 func parseFetchResults(t *testing.T, data interface{}) []fetch.ChunkText {
@@ -282,6 +458,29 @@ func parseFetchResults(t *testing.T, data interface{}) []fetch.ChunkText {
 	return results
 }
 
+func parseSymbols(t *testing.T, data interface{}) []symbols.Symbol {
+	t.Helper()
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("marshal symbols data: %v", err)
+	}
+	var results []symbols.Symbol
+	if err := json.Unmarshal(raw, &results); err != nil {
+		t.Fatalf("unmarshal symbols data: %v", err)
+	}
+	return results
+}
+
+func TestServeStdioValidationSymbols(t *testing.T) {
+	resp := runValidationRequest(t, "", `{"op":"symbols"}`+"\n")
+	if resp.resp.OK || resp.resp.Op != "symbols" {
+		t.Fatalf("empty symbols request should fail: %s", resp.raw)
+	}
+	if resp.resp.Error != "invalid symbols request: name or kind is required" {
+		t.Fatalf("unexpected error: %s", resp.resp.Error)
+	}
+}
+
 func TestServeStdioValidationSearch(t *testing.T) {
 	resp := runValidationRequest(t, "", `{"op":"search","q":""}`+"\n")
 	if resp.resp.OK {
@@ -341,18 +540,69 @@ func TestServeStdioValidationFetch(t *testing.T) {
 		if resp.resp.Op != "fetch" {
 			t.Fatalf("expected fetch op, got %q", resp.resp.Op)
 		}
+		// Overlapping or adjacent chunks come back merged, so compare the
+		// covered ids rather than the number of blocks.
 		results := parseFetchResults(t, resp.resp.Data)
-		if len(results) != 5 {
-			t.Fatalf("expected 5 fetch results, got %d", len(results))
-		}
-		for i, res := range results {
-			if res.ChunkID != ids[i] {
-				t.Fatalf("result %d chunk id mismatch: got %d, want %d", i, res.ChunkID, ids[i])
+		covered := make(map[uint32]bool)
+		for _, res := range results {
+			covered[res.ChunkID] = true
+			for _, id := range res.ChunkIDs {
+				covered[id] = true
 			}
 		}
+		want := make(map[uint32]bool)
+		for _, id := range ids[:5] {
+			want[id] = true
+		}
+		if !reflect.DeepEqual(covered, want) {
+			t.Fatalf("expected fetch to cover the first 5 ids %v, got %v", ids[:5], covered)
+		}
 	})
 }
 
+func TestServeStdioValidationFetchRange(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"missing path", `{"op":"fetch_range","start_line":1}`, "invalid fetch_range request: path is required"},
+		{"missing start", `{"op":"fetch_range","path":"src/a.ts"}`, "invalid fetch_range request: start_line is required"},
+		{"cursor", `{"op":"fetch_range","path":"src/a.ts","start_line":1,"cursor":"abc"}`, "invalid fetch_range request: cursor only applies to search"},
+		{"line count after", `{"op":"fetch_range","path":"src/a.ts","start_line":1,"after":"abc"}`, "invalid fetch_range request: after must be a line count"},
+		{"search after", `{"op":"search","q":"alpha","after":3}`, "invalid search request: after must be the next_cursor string of the previous page"},
+		{"search after and cursor", `{"op":"search","q":"alpha","after":"abc","cursor":"def"}`, "invalid search request: after and cursor name different pages"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := runValidationRequest(t, "", tc.payload+"\n")
+			if resp.resp.OK || resp.resp.Error != tc.want {
+				t.Fatalf("unexpected response: %s", resp.raw)
+			}
+		})
+	}
+}
+
+func TestServeStdioValidationGitDiff(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"missing target", `{"op":"git_diff"}`, "invalid git_diff request: ids or path is required"},
+		{"both targets", `{"op":"git_diff","ids":[1],"path":"src/a.ts","start_line":1}`, "invalid git_diff request: ids and path are mutually exclusive"},
+		{"missing start", `{"op":"git_diff","path":"src/a.ts"}`, "invalid git_diff request: start_line is required with path"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := runValidationRequest(t, "", tc.payload+"\n")
+			if resp.resp.OK || resp.resp.Op != "git_diff" || resp.resp.Error != tc.want {
+				t.Fatalf("unexpected response: %s", resp.raw)
+			}
+		})
+	}
+}
+
 func runValidationRequest(t *testing.T, root, payload string) responseLine {
 	t.Helper()
 	ioMu.Lock()
@@ -472,6 +722,11 @@ func buildTestIndex(t *testing.T, root string) {
 		t.Fatalf("write sample file: %v", err)
 	}
 
+	consumer := "import { summary } from './sample';\n\nexport const report = () => summary;\n"
+	if err := os.WriteFile(filepath.Join(srcDir, "report.ts"), []byte(consumer), 0o644); err != nil {
+		t.Fatalf("write consumer file: %v", err)
+	}
+
 	cfg := config.DefaultConfig()
 	cfg.ProjectType = "ts"
 	if err := config.Save(store.ConfigPath(root), cfg); err != nil {
@@ -504,6 +759,24 @@ func buildTestIndex(t *testing.T, root string) {
 	if err := index.Serialize(root, fileEntries, chunkEntries, postings); err != nil {
 		t.Fatalf("serialize index: %v", err)
 	}
+	fileSymbols := make(map[string][]lang.Symbol, len(files))
+	for _, f := range files {
+		fileSymbols[filepath.ToSlash(f.Path)] = plugin.Symbols(f.Path, textutil.NormalizeNewlinesBytes(f.Content))
+	}
+	if err := index.WriteSymbols(store.SymbolsPath(root), index.BuildSymbols(fileEntries, fileSymbols)); err != nil {
+		t.Fatalf("write symbols: %v", err)
+	}
+	fileImports := make(map[string][]lang.Import, len(files))
+	for _, f := range files {
+		fileImports[filepath.ToSlash(f.Path)] = plugin.Imports(f.Path, textutil.NormalizeNewlinesBytes(f.Content))
+	}
+	edges, err := depgraph.Build(root, fileEntries, fileImports)
+	if err != nil {
+		t.Fatalf("build import graph: %v", err)
+	}
+	if err := index.WriteImports(store.ImportsPath(root), edges); err != nil {
+		t.Fatalf("write imports: %v", err)
+	}
 	buf := make([]byte, 8)
 	binary.LittleEndian.PutUint64(buf, rules.RulesHash)
 	cfgHash := hash.Sum64(append(cfgBytes, buf...))
@@ -0,0 +1,326 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/memkit/repodex/internal/procx"
+)
+
+const (
+	genDirName   = "gen"
+	leaseDirName = "leases"
+	syncLockName = "sync.lock"
+	// flatLeaseName holds leases on the flat layout, which has no generation name.
+	flatLeaseName = "flat"
+	// maxAcquireAttempts bounds retries when syncs publish while a reader opens the index.
+	maxAcquireAttempts = 5
+	// unreadableLeaseAge is how long a lease file may stay unparseable.
+	unreadableLeaseAge = time.Minute
+)
+
+// Generation locates the artifacts of one index build. Each sync writes a new
+// generation directory under .repodex/gen and publishes it by rewriting
+// meta.json, which names the current one, with an atomic rename. An empty Name
+// is the flat layout of indexes written before generations existed, with the
+// artifacts directly in .repodex.
+type Generation struct {
+	Name string
+	Dir  string
+}
+
+// GenerationsDir holds one directory per generation.
+func GenerationsDir(root string) string {
+	return filepath.Join(Dir(root), genDirName)
+}
+
+// LeasesDir holds one directory of lease files per generation in use, and
+// the sync lock.
+func LeasesDir(root string) string {
+	return filepath.Join(Dir(root), leaseDirName)
+}
+
+// SyncLockPath is the lock file held for the duration of a sync.
+func SyncLockPath(root string) string {
+	return filepath.Join(LeasesDir(root), syncLockName)
+}
+
+// EnsureLeasesDir creates LeasesDir. Leases and locks are per machine and
+// process, so the directory ignores itself to stay out of a committed index.
+func EnsureLeasesDir(root string) error {
+	if err := os.MkdirAll(LeasesDir(root), 0o755); err != nil {
+		return fmt.Errorf("create leases dir: %w", err)
+	}
+	ignorePath := filepath.Join(LeasesDir(root), ".gitignore")
+	if _, err := os.Stat(ignorePath); errors.Is(err, os.ErrNotExist) {
+		_ = os.WriteFile(ignorePath, []byte("*\n"), 0o644)
+	}
+	return nil
+}
+
+// Current returns the generation meta.json points at, or the flat layout when
+// meta.json is missing, unreadable or names no valid generation.
+func Current(root string) Generation {
+	meta, err := LoadMeta(MetaPath(root))
+	if err != nil || !validGenerationName(meta.Generation) {
+		return Generation{Dir: Dir(root)}
+	}
+	return Generation{Name: meta.Generation, Dir: filepath.Join(GenerationsDir(root), meta.Generation)}
+}
+
+func validGenerationName(name string) bool {
+	return name != "" && name != "." && name != ".." && name != flatLeaseName &&
+		!strings.ContainsAny(name, `/\`)
+}
+
+func (g Generation) FilesPath() string     { return filepath.Join(g.Dir, FilesFile) }
+func (g Generation) ChunksPath() string    { return filepath.Join(g.Dir, ChunksFile) }
+func (g Generation) TermsPath() string     { return filepath.Join(g.Dir, TermsFile) }
+func (g Generation) PostingsPath() string  { return filepath.Join(g.Dir, PostingsFile) }
+func (g Generation) PositionsPath() string { return filepath.Join(g.Dir, PositionsFile) }
+func (g Generation) SymbolsPath() string   { return filepath.Join(g.Dir, SymbolsFile) }
+func (g Generation) ImportsPath() string   { return filepath.Join(g.Dir, ImportsFile) }
+func (g Generation) SnapshotsPath() string { return filepath.Join(g.Dir, SnapshotsFile) }
+
+func (g Generation) leaseName() string {
+	if g.Name == "" {
+		return flatLeaseName
+	}
+	return g.Name
+}
+
+// Lease keeps a generation from being garbage-collected while it is read.
+// A lease whose process has exited is ignored and removed by
+// CollectGenerations, so a crashed reader never pins a generation.
+type Lease struct {
+	path string
+}
+
+type leaseInfo struct {
+	PID          int    `json:"pid"`
+	Host         string `json:"host"`
+	AcquiredUnix int64  `json:"acquired_unix"`
+}
+
+// AcquireCurrent leases the current generation and returns it. The pointer
+// is re-read after the lease is taken: a generation that is still current
+// then cannot be collected, since collection only removes generations that
+// were already replaced when it checked their leases. The returned lease must
+// be released; it is nil when there is no .repodex directory.
+func AcquireCurrent(root string) (Generation, *Lease, error) {
+	if _, err := os.Stat(Dir(root)); err != nil {
+		return Current(root), nil, nil
+	}
+	for attempt := 0; attempt < maxAcquireAttempts; attempt++ {
+		gen := Current(root)
+		lease, err := acquireLease(root, gen.leaseName())
+		if err != nil {
+			return Generation{}, nil, err
+		}
+		if Current(root) == gen {
+			return gen, lease, nil
+		}
+		lease.Release()
+	}
+	return Generation{}, nil, fmt.Errorf("index generation changed during %d attempts to open it", maxAcquireAttempts)
+}
+
+func acquireLease(root, name string) (*Lease, error) {
+	if err := EnsureLeasesDir(root); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(LeasesDir(root), name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create lease dir: %w", err)
+	}
+	data, err := json.Marshal(leaseInfo{PID: os.Getpid(), Host: procx.Hostname(), AcquiredUnix: time.Now().Unix()})
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, strconv.Itoa(os.Getpid())+"-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("create lease: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("write lease: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("write lease: %w", err)
+	}
+	return &Lease{path: f.Name()}, nil
+}
+
+// Release drops the lease. It is safe on a nil lease and when called twice.
+func (l *Lease) Release() {
+	if l == nil || l.path == "" {
+		return
+	}
+	_ = os.Remove(l.path)
+	l.path = ""
+}
+
+// NewGeneration creates an empty generation directory, leased to the caller
+// until it is published or abandoned. Names start with the creation time in
+// milliseconds so they sort in build order.
+func NewGeneration(root string) (Generation, *Lease, error) {
+	if err := os.MkdirAll(GenerationsDir(root), 0o755); err != nil {
+		return Generation{}, nil, fmt.Errorf("create generations dir: %w", err)
+	}
+	dir, err := os.MkdirTemp(GenerationsDir(root), fmt.Sprintf("%013d-", time.Now().UnixMilli()))
+	if err != nil {
+		return Generation{}, nil, fmt.Errorf("create generation: %w", err)
+	}
+	gen := Generation{Name: filepath.Base(dir), Dir: dir}
+	lease, err := acquireLease(root, gen.Name)
+	if err != nil {
+		os.RemoveAll(dir)
+		return Generation{}, nil, err
+	}
+	return gen, lease, nil
+}
+
+// Publish flushes every file of gen to disk and then makes it current by
+// saving meta with its name. Readers see either the previous generation or
+// this one, never a mix.
+func Publish(root string, gen Generation, meta Meta) error {
+	if !validGenerationName(gen.Name) {
+		return fmt.Errorf("invalid generation %q", gen.Name)
+	}
+	if err := syncDir(gen.Dir); err != nil {
+		return fmt.Errorf("flush generation %s: %w", gen.Name, err)
+	}
+	meta.Generation = gen.Name
+	return SaveMeta(MetaPath(root), meta)
+}
+
+// syncDir fsyncs every regular file in dir and then dir itself.
+func syncDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if err := syncFile(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	// Directories cannot be fsynced on every platform (Windows); the files are.
+	_ = syncFile(dir)
+	return nil
+}
+
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// CollectGenerations removes generations that are no longer current and not
+// leased by a live process, and the flat-layout artifacts once a generation
+// is current. Leases of exited processes on this host are deleted first.
+// It returns the names of the removed generations.
+func CollectGenerations(root string) ([]string, error) {
+	// Read the pointer before any lease: see AcquireCurrent.
+	current := Current(root)
+	if current.Name == "" {
+		return nil, nil
+	}
+
+	var removed []string
+	entries, err := os.ReadDir(GenerationsDir(root))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || name == current.Name {
+			continue
+		}
+		held, err := leaseHeld(root, name)
+		if err != nil {
+			return removed, err
+		}
+		if held {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(GenerationsDir(root), name)); err != nil {
+			return removed, err
+		}
+		_ = os.Remove(filepath.Join(LeasesDir(root), name))
+		removed = append(removed, name)
+	}
+
+	held, err := leaseHeld(root, flatLeaseName)
+	if err != nil {
+		return removed, err
+	}
+	if !held {
+		for _, name := range artifactFiles {
+			if err := os.Remove(filepath.Join(Dir(root), name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// leaseHeld reports whether a live process leases the named generation,
+// removing leases left behind by exited processes on this host. Leases from
+// other hosts cannot be checked and count as held.
+func leaseHeld(root, name string) (bool, error) {
+	dir := filepath.Join(LeasesDir(root), name)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	host := procx.Hostname()
+	held := false
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return false, err
+		}
+		var info leaseInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			// A lease being written is empty for a moment; only an old
+			// unreadable one was abandoned mid-write.
+			if fi, statErr := e.Info(); statErr == nil && time.Since(fi.ModTime()) > unreadableLeaseAge {
+				_ = os.Remove(path)
+				continue
+			}
+			held = true
+			continue
+		}
+		if info.Host != host || procx.Alive(info.PID) {
+			held = true
+			continue
+		}
+		_ = os.Remove(path)
+	}
+	if !held {
+		_ = os.Remove(dir)
+	}
+	return held, nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/memkit/repodex/internal/procx"
+)
+
+func writeGeneration(t *testing.T, root string) (Generation, *Lease) {
+	t.Helper()
+	gen, lease, err := NewGeneration(root)
+	if err != nil {
+		t.Fatalf("NewGeneration: %v", err)
+	}
+	for _, name := range artifactFiles {
+		if err := os.WriteFile(filepath.Join(gen.Dir, name), []byte(gen.Name), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return gen, lease
+}
+
+func TestPublishSwitchesCurrentGeneration(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(Dir(root), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(Dir(root), FilesFile), []byte("flat"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Current(root); got.Name != "" || got.FilesPath() != filepath.Join(Dir(root), FilesFile) {
+		t.Fatalf("expected flat layout before publishing, got %+v", got)
+	}
+
+	gen, lease := writeGeneration(t, root)
+	if err := Publish(root, gen, Meta{FileCount: 3}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	lease.Release()
+
+	if got := Current(root); got != gen {
+		t.Fatalf("expected current %+v, got %+v", gen, got)
+	}
+	meta, err := LoadMeta(MetaPath(root))
+	if err != nil {
+		t.Fatalf("LoadMeta: %v", err)
+	}
+	if meta.Generation != gen.Name || meta.FileCount != 3 {
+		t.Fatalf("unexpected meta %+v", meta)
+	}
+	if data, err := os.ReadFile(FilesPath(root)); err != nil || string(data) != gen.Name {
+		t.Fatalf("expected FilesPath to resolve into the generation, got %q, %v", data, err)
+	}
+
+	if _, err := CollectGenerations(root); err != nil {
+		t.Fatalf("CollectGenerations: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(Dir(root), FilesFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected flat artifacts to be removed, got %v", err)
+	}
+}
+
+func TestCollectGenerationsHonoursLeases(t *testing.T) {
+	root := t.TempDir()
+
+	first, lease := writeGeneration(t, root)
+	if err := Publish(root, first, Meta{}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	lease.Release()
+
+	gen, reader, err := AcquireCurrent(root)
+	if err != nil {
+		t.Fatalf("AcquireCurrent: %v", err)
+	}
+	if gen != first {
+		t.Fatalf("expected reader on %s, got %+v", first.Name, gen)
+	}
+
+	second, lease := writeGeneration(t, root)
+	if err := Publish(root, second, Meta{}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	lease.Release()
+
+	removed, err := CollectGenerations(root)
+	if err != nil {
+		t.Fatalf("CollectGenerations: %v", err)
+	}
+	if len(removed) != 0 {
+		t.Fatalf("expected leased generation to be kept, removed %v", removed)
+	}
+	if data, err := os.ReadFile(gen.ChunksPath()); err != nil || string(data) != first.Name {
+		t.Fatalf("expected leased generation to stay readable, got %q, %v", data, err)
+	}
+
+	reader.Release()
+	removed, err = CollectGenerations(root)
+	if err != nil {
+		t.Fatalf("CollectGenerations: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != first.Name {
+		t.Fatalf("expected %s to be removed, got %v", first.Name, removed)
+	}
+	if _, err := os.Stat(second.Dir); err != nil {
+		t.Fatalf("expected current generation to remain: %v", err)
+	}
+}
+
+func TestCollectGenerationsIgnoresDeadLeases(t *testing.T) {
+	root := t.TempDir()
+
+	first, lease := writeGeneration(t, root)
+	if err := Publish(root, first, Meta{}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	lease.Release()
+
+	// A reader that exited without releasing its lease.
+	dir := filepath.Join(LeasesDir(root), first.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(leaseInfo{PID: 1 << 30, Host: procx.Hostname(), AcquiredUnix: time.Now().Unix()})
+	if err := os.WriteFile(filepath.Join(dir, "dead.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	second, lease := writeGeneration(t, root)
+	if err := Publish(root, second, Meta{}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	lease.Release()
+
+	removed, err := CollectGenerations(root)
+	if err != nil {
+		t.Fatalf("CollectGenerations: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != first.Name {
+		t.Fatalf("expected dead lease to be ignored, removed %v", removed)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected dead lease dir to be removed, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -17,9 +18,12 @@ type Meta struct {
 	SchemaVersion  int    `json:"SchemaVersion"`
 	RepoHead       string `json:"RepoHead"`
 	RepodexVersion string `json:"RepodexVersion"`
+	// Generation names the published directory under .repodex/gen holding
+	// the artifacts; empty for the flat layout.
+	Generation string `json:"Generation,omitempty"`
 }
 
-const SchemaVersion = 2
+const SchemaVersion = 11
 
 var RepodexVersion = "dev"
 
@@ -38,13 +42,41 @@ func NewMeta(indexVersion int, fileCount, chunkCount, termCount int, configHash
 	}
 }
 
-// SaveMeta writes the metadata to disk.
+// SaveMeta writes the metadata to disk. The file is replaced atomically, so
+// a reader sees either the old or the new generation pointer.
 func SaveMeta(path string, meta Meta) error {
 	data, err := json.MarshalIndent(meta, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, 0o644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
 }
 
 // LoadMeta reads metadata from disk.
@@ -4,6 +4,21 @@ import "path/filepath"
 
 const dirName = ".repodex"
 
+// Artifact file names, found in every generation directory.
+const (
+	FilesFile     = "files.dat"
+	ChunksFile    = "chunks.dat"
+	TermsFile     = "terms.dat"
+	PostingsFile  = "postings.dat"
+	PositionsFile = "positions.dat"
+	SymbolsFile   = "symbols.dat"
+	ImportsFile   = "imports.dat"
+	SnapshotsFile = "snapshots.dat"
+)
+
+// artifactFiles lists every artifact a generation may hold.
+var artifactFiles = []string{FilesFile, ChunksFile, TermsFile, PostingsFile, PositionsFile, SymbolsFile, ImportsFile, SnapshotsFile}
+
 // Dir returns the base directory for Repodex data.
 func Dir(root string) string {
 	return filepath.Join(root, dirName)
@@ -17,22 +32,43 @@ func IgnorePath(root string) string {
 	return filepath.Join(Dir(root), "ignore")
 }
 
+// MetaPath is the index metadata, which also names the current generation.
 func MetaPath(root string) string {
 	return filepath.Join(Dir(root), "meta.json")
 }
 
+// The artifact paths below resolve the current generation on every call.
+// Readers that load more than one artifact should use AcquireCurrent and the
+// Generation methods instead, so they cannot mix two generations.
+
 func FilesPath(root string) string {
-	return filepath.Join(Dir(root), "files.dat")
+	return Current(root).FilesPath()
 }
 
 func ChunksPath(root string) string {
-	return filepath.Join(Dir(root), "chunks.dat")
+	return Current(root).ChunksPath()
 }
 
 func TermsPath(root string) string {
-	return filepath.Join(Dir(root), "terms.dat")
+	return Current(root).TermsPath()
 }
 
 func PostingsPath(root string) string {
-	return filepath.Join(Dir(root), "postings.dat")
+	return Current(root).PostingsPath()
+}
+
+func PositionsPath(root string) string {
+	return Current(root).PositionsPath()
+}
+
+func SymbolsPath(root string) string {
+	return Current(root).SymbolsPath()
+}
+
+func ImportsPath(root string) string {
+	return Current(root).ImportsPath()
+}
+
+func SnapshotsPath(root string) string {
+	return Current(root).SnapshotsPath()
 }
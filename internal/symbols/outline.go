@@ -0,0 +1,119 @@
+package symbols
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/store"
+)
+
+// FileOutline is the declaration structure of an indexed file and the chunks
+// that cover it, so regions can be fetched by chunk id.
+type FileOutline struct {
+	Path    string         `json:"path"`
+	Symbols []OutlineEntry `json:"symbols"`
+	Chunks  []OutlineChunk `json:"chunks"`
+}
+
+// OutlineEntry is a top-level declaration or class member.
+type OutlineEntry struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Exported  bool   `json:"exported"`
+	StartLine uint32 `json:"start_line"`
+	EndLine   uint32 `json:"end_line"`
+	// ChunkIDs lists the chunks overlapping the declaration's line range.
+	ChunkIDs []uint32       `json:"chunk_ids"`
+	Members  []OutlineEntry `json:"members,omitempty"`
+}
+
+// OutlineChunk is a chunk boundary within the file.
+type OutlineChunk struct {
+	ChunkID   uint32 `json:"chunk_id"`
+	StartLine uint32 `json:"start_line"`
+	EndLine   uint32 `json:"end_line"`
+}
+
+// LookupOutline loads the index and returns the outline of path.
+func LookupOutline(root string, p string) (FileOutline, error) {
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		return FileOutline{}, err
+	}
+	defer lease.Release()
+
+	files, err := index.LoadFileEntries(gen.FilesPath())
+	if err != nil {
+		return FileOutline{}, err
+	}
+	chunks, err := index.LoadChunkEntries(gen.ChunksPath())
+	if err != nil {
+		return FileOutline{}, err
+	}
+	entries, err := index.LoadSymbols(gen.SymbolsPath())
+	if err != nil {
+		return FileOutline{}, err
+	}
+	return Outline(files, chunks, entries, p)
+}
+
+// Outline nests class members under their class and attaches covering chunk
+// ids to every entry. Members whose class is not in the file stay top-level.
+func Outline(files []index.FileEntry, chunks []index.ChunkEntry, entries []index.SymbolEntry, p string) (FileOutline, error) {
+	fe, ok := index.FileByPath(files, p)
+	if !ok {
+		return FileOutline{}, fmt.Errorf("path not indexed: %s", path.Clean(filepath.ToSlash(strings.TrimSpace(p))))
+	}
+	out := FileOutline{Path: fe.Path, Symbols: []OutlineEntry{}, Chunks: []OutlineChunk{}}
+	for _, ch := range chunks {
+		if ch.FileID == fe.FileID {
+			out.Chunks = append(out.Chunks, OutlineChunk{ChunkID: ch.ChunkID, StartLine: ch.StartLine, EndLine: ch.EndLine})
+		}
+	}
+
+	// symbols.dat is ordered by path then start line, so classes precede their members.
+	for _, e := range entries {
+		if e.FileID != fe.FileID {
+			continue
+		}
+		entry := OutlineEntry{
+			Name:      e.Name,
+			Kind:      e.Kind,
+			Exported:  e.Exported,
+			StartLine: e.StartLine,
+			EndLine:   e.EndLine,
+			ChunkIDs:  coveringChunks(out.Chunks, e.StartLine, e.EndLine),
+		}
+		if e.Parent != "" {
+			if owner := enclosingClass(out.Symbols, e); owner != nil {
+				owner.Members = append(owner.Members, entry)
+				continue
+			}
+		}
+		out.Symbols = append(out.Symbols, entry)
+	}
+	return out, nil
+}
+
+func enclosingClass(top []OutlineEntry, member index.SymbolEntry) *OutlineEntry {
+	for i := len(top) - 1; i >= 0; i-- {
+		c := &top[i]
+		if c.Name == member.Parent && c.StartLine <= member.StartLine && member.StartLine <= c.EndLine {
+			return c
+		}
+	}
+	return nil
+}
+
+func coveringChunks(chunks []OutlineChunk, start, end uint32) []uint32 {
+	ids := []uint32{}
+	for _, ch := range chunks {
+		if ch.StartLine <= end && ch.EndLine >= start {
+			ids = append(ids, ch.ChunkID)
+		}
+	}
+	return ids
+}
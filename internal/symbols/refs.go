@@ -0,0 +1,361 @@
+package symbols
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/fetch"
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/store"
+	"github.com/memkit/repodex/internal/tokenize"
+)
+
+// Target names an identifier, optionally with the file and line where it was
+// seen. The context ranks declarations and occurrences in that file first.
+type Target struct {
+	Name string
+	Path string
+	Line int
+}
+
+// Reference is a whole-identifier occurrence.
+type Reference struct {
+	Path string `json:"path"`
+	Line uint32 `json:"line"`
+	// Column is the 1-based byte offset of the identifier in the line.
+	Column int    `json:"column"`
+	Text   string `json:"text"`
+	// Definition marks occurrences on the first line of a declaration of the identifier.
+	Definition bool `json:"definition,omitempty"`
+	// Stale reports that the file changed since the last sync, so the line may
+	// no longer hold the occurrence. Source is fetch.SourceSnapshot when the
+	// line was read from snapshots.dat instead of the working tree.
+	Stale  bool   `json:"stale,omitempty"`
+	Source string `json:"source,omitempty"`
+}
+
+// ReferencesResponse lists occurrences in path and line order, context file first.
+type ReferencesResponse struct {
+	Name       string      `json:"name"`
+	References []Reference `json:"references"`
+	// Truncated is set when more than Limits.MaxReferences occurrences exist.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][\w$]*$`)
+
+// LookupDefinitions loads symbols.dat and returns declarations of t.Name.
+func LookupDefinitions(root string, t Target) ([]Symbol, error) {
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		return nil, err
+	}
+	defer lease.Release()
+
+	cfg, _, err := config.Load(store.ConfigPath(root))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := index.LoadSymbols(gen.SymbolsPath())
+	if err != nil {
+		return nil, err
+	}
+	return Definitions(entries, t, cfg.Limits.MaxTopK)
+}
+
+// Definitions returns declarations named t.Name ("Class.method" selects a
+// method of that class). Names match case-insensitively, like Find: exact-case
+// declarations come first, then those in t.Path, nearest preceding t.Line
+// first when a line is given, then exported ones, then path and line order.
+func Definitions(entries []index.SymbolEntry, t Target, limit int) ([]Symbol, error) {
+	name := strings.TrimSpace(t.Name)
+	parent, name, ok := splitQualified(name)
+	if !ok || !identifierPattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid definition request: name must be an identifier or Class.member")
+	}
+	contextPath := filepath.ToSlash(t.Path)
+
+	var matches []index.SymbolEntry
+	for _, e := range entries {
+		if strings.EqualFold(e.Name, name) && (parent == "" || strings.EqualFold(e.Parent, parent)) {
+			matches = append(matches, e)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if ea, eb := a.Name == name && (parent == "" || a.Parent == parent), b.Name == name && (parent == "" || b.Parent == parent); ea != eb {
+			return ea
+		}
+		if ca, cb := a.Path == contextPath, b.Path == contextPath; ca != cb {
+			return ca
+		} else if ca && t.Line > 0 {
+			if da, db := lineDistance(a, t.Line), lineDistance(b, t.Line); da != db {
+				return da < db
+			}
+		}
+		if a.Exported != b.Exported {
+			return a.Exported
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.StartLine < b.StartLine
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return toSymbols(matches), nil
+}
+
+// lineDistance ranks declarations at or above line by how far above they start;
+// declarations below line rank after all of those.
+func lineDistance(e index.SymbolEntry, line int) int {
+	start := int(e.StartLine)
+	if start <= line {
+		return line - start
+	}
+	return start - line + int(^uint32(0))
+}
+
+// LookupReferences loads the index and returns occurrences of t.Name.
+func LookupReferences(root string, t Target) (ReferencesResponse, error) {
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		return ReferencesResponse{}, err
+	}
+	defer lease.Release()
+
+	cfg, _, err := config.Load(store.ConfigPath(root))
+	if err != nil {
+		return ReferencesResponse{}, err
+	}
+	reader, err := index.OpenReader(gen)
+	if err != nil {
+		return ReferencesResponse{}, err
+	}
+	defer reader.Close()
+	entries, err := index.LoadSymbols(gen.SymbolsPath())
+	if err != nil {
+		return ReferencesResponse{}, err
+	}
+	files, err := index.LoadFileEntries(gen.FilesPath())
+	if err != nil {
+		return ReferencesResponse{}, err
+	}
+	idx := Index{
+		Chunks:        reader.Chunks(),
+		Terms:         reader.Terms(),
+		Postings:      reader.Postings(),
+		Symbols:       entries,
+		Files:         files,
+		SnapshotsPath: gen.SnapshotsPath(),
+	}
+	return References(root, cfg, idx, t)
+}
+
+// Index is the index data References reads.
+type Index struct {
+	Chunks   index.Chunks
+	Terms    *index.TermDict
+	Postings *index.Postings
+	Symbols  []index.SymbolEntry
+	// Files and SnapshotsPath tell files changed since the sync apart and
+	// serve their indexed lines, as fetch does.
+	Files         []index.FileEntry
+	SnapshotsPath string
+}
+
+// References finds whole-identifier, case-sensitive occurrences of t.Name.
+// Candidate chunks are those whose postings contain every indexed token the
+// identifier splits into; tokens the tokenizer drops do not narrow the search,
+// so getUserById scans the chunks holding both get and user, since by and id
+// are shorter than MinTokenLen. Those chunk lines are then re-read and matched
+// on identifier boundaries. Files changed since the sync are read from their
+// snapshot when the index has one, and their occurrences are marked Stale.
+func References(root string, cfg config.Config, idx Index, t Target) (ReferencesResponse, error) {
+	name := strings.TrimSpace(t.Name)
+	if !identifierPattern.MatchString(name) {
+		return ReferencesResponse{}, fmt.Errorf("invalid references request: name must be an identifier")
+	}
+	resp := ReferencesResponse{Name: name, References: []Reference{}}
+
+	candidates, err := candidateChunks(cfg, idx.Chunks, idx.Terms, idx.Postings, name)
+	if err != nil {
+		return ReferencesResponse{}, err
+	}
+	if len(candidates) == 0 {
+		return resp, nil
+	}
+	byPath := make(map[string][]index.ChunkEntry)
+	for _, ch := range candidates {
+		byPath[ch.Path] = append(byPath[ch.Path], ch)
+	}
+	paths := make([]string, 0, len(byPath))
+	for p := range byPath {
+		paths = append(paths, p)
+	}
+	contextPath := filepath.ToSlash(t.Path)
+	sort.Slice(paths, func(i, j int) bool {
+		if ci, cj := paths[i] == contextPath, paths[j] == contextPath; ci != cj {
+			return ci
+		}
+		return paths[i] < paths[j]
+	})
+
+	declared := make(map[string]map[uint32]struct{})
+	for _, e := range idx.Symbols {
+		if e.Name != name {
+			continue
+		}
+		if declared[e.Path] == nil {
+			declared[e.Path] = make(map[uint32]struct{})
+		}
+		declared[e.Path][e.StartLine] = struct{}{}
+	}
+
+	rootReal, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return ReferencesResponse{}, fmt.Errorf("resolve root: %w", err)
+	}
+	limit := cfg.Limits.MaxReferences
+	for _, path := range paths {
+		fileChunks := byPath[path]
+		lines, stale, source, err := fetch.IndexedLines(rootReal, idx.Files, idx.SnapshotsPath, fileChunks[0].FileID, path)
+		if err != nil && stale {
+			return ReferencesResponse{}, err
+		} else if err != nil {
+			// Files removed since the last sync have no occurrences to report.
+			continue
+		}
+		// Chunks never nest, so visiting them by start line keeps occurrences in line order.
+		sort.Slice(fileChunks, func(i, j int) bool { return fileChunks[i].StartLine < fileChunks[j].StartLine })
+		seen := make(map[int]struct{})
+		for _, ch := range fileChunks {
+			for ln := int(ch.StartLine); ln <= int(ch.EndLine) && ln <= len(lines); ln++ {
+				if _, ok := seen[ln]; ok || ln < 1 {
+					continue
+				}
+				seen[ln] = struct{}{}
+				for _, col := range identifierColumns(lines[ln-1], name) {
+					if limit > 0 && len(resp.References) >= limit {
+						resp.Truncated = true
+						return resp, nil
+					}
+					_, def := declared[path][uint32(ln)]
+					resp.References = append(resp.References, Reference{
+						Path:       path,
+						Line:       uint32(ln),
+						Column:     col + 1,
+						Text:       trimLine(lines[ln-1], cfg.Limits.MaxSnippetBytes),
+						Definition: def,
+						Stale:      stale,
+						Source:     source,
+					})
+				}
+			}
+		}
+	}
+	return resp, nil
+}
+
+// candidateChunks intersects the postings of every token in name. The rarest
+// list leads and the others skip ahead to its chunk ids, so long lists are
+// mostly skipped block by block. When the tokenizer keeps none of the tokens
+// (e.g. all stop words), every chunk is a candidate.
+func candidateChunks(cfg config.Config, chunks index.Chunks, terms *index.TermDict, postings *index.Postings, name string) ([]index.ChunkEntry, error) {
+	parts := tokenize.New(cfg.Token).Text(name)
+	if len(parts) == 0 {
+		out := make([]index.ChunkEntry, chunks.Len())
+		for i := range out {
+			out[i] = chunks.At(i)
+		}
+		return out, nil
+	}
+	infos := make([]index.TermInfo, 0, len(parts))
+	for _, part := range parts {
+		info, ok := terms.Lookup(part)
+		if !ok {
+			return nil, nil
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].DF < infos[j].DF })
+	iters := make([]*index.PostingIterator, len(infos))
+	for i, info := range infos {
+		it, err := postings.Iterator(info)
+		if err != nil {
+			return nil, err
+		}
+		iters[i] = it
+	}
+
+	var out []index.ChunkEntry
+	lead := iters[0]
+next:
+	for lead.Next() {
+		id := lead.Posting().ChunkID
+		for _, it := range iters[1:] {
+			if !it.SkipTo(id) {
+				if err := it.Err(); err != nil {
+					return nil, err
+				}
+				break next
+			}
+			if it.Posting().ChunkID != id {
+				continue next
+			}
+		}
+		i, ok := chunks.Find(id)
+		if !ok {
+			return nil, fmt.Errorf("missing chunk %d", id)
+		}
+		out = append(out, chunks.At(i))
+	}
+	if err := lead.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// identifierColumns returns the byte offsets of name in line where it is not
+// part of a longer identifier.
+func identifierColumns(line, name string) []int {
+	var cols []int
+	for from := 0; from <= len(line)-len(name); {
+		i := strings.Index(line[from:], name)
+		if i < 0 {
+			break
+		}
+		start := from + i
+		end := start + len(name)
+		if (start == 0 || !isIdentByte(line[start-1])) && (end == len(line) || !isIdentByte(line[end])) {
+			cols = append(cols, start)
+		}
+		from = start + 1
+	}
+	return cols
+}
+
+// isIdentByte treats non-ASCII bytes as identifier characters so names are
+// never matched inside Unicode identifiers.
+func isIdentByte(b byte) bool {
+	return b == '_' || b == '$' || b >= utf8.RuneSelf ||
+		(b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func trimLine(line string, maxBytes int) string {
+	text := strings.TrimSpace(line)
+	if maxBytes > 0 && len(text) > maxBytes {
+		b := []byte(text[:maxBytes])
+		for len(b) > 0 && !utf8.Valid(b) {
+			b = b[:len(b)-1]
+		}
+		text = string(b)
+	}
+	return text
+}
@@ -0,0 +1,139 @@
+package symbols
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/lang"
+	"github.com/memkit/repodex/internal/store"
+)
+
+// Symbol is a declaration returned by symbol lookups.
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Parent    string `json:"parent,omitempty"`
+	Exported  bool   `json:"exported"`
+	Path      string `json:"path"`
+	StartLine uint32 `json:"start_line"`
+	EndLine   uint32 `json:"end_line"`
+}
+
+// Query selects symbols by name and/or kind. Name matches case-insensitively;
+// "Class.method" restricts a method name to its enclosing class.
+type Query struct {
+	Name string
+	Kind string
+}
+
+var kinds = map[string]struct{}{
+	lang.KindFunction:  {},
+	lang.KindClass:     {},
+	lang.KindMethod:    {},
+	lang.KindInterface: {},
+	lang.KindType:      {},
+	lang.KindEnum:      {},
+	lang.KindNamespace: {},
+	lang.KindVariable:  {},
+}
+
+// Lookup loads symbols.dat and returns matches capped at Limits.MaxTopK.
+func Lookup(root string, q Query) ([]Symbol, error) {
+	gen, lease, err := store.AcquireCurrent(root)
+	if err != nil {
+		return nil, err
+	}
+	defer lease.Release()
+
+	cfg, _, err := config.Load(store.ConfigPath(root))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := index.LoadSymbols(gen.SymbolsPath())
+	if err != nil {
+		return nil, err
+	}
+	return Find(entries, q, cfg.Limits.MaxTopK)
+}
+
+// splitQualified splits a "Class.member" name at its last dot into the class
+// and the member; a name without a dot is a member of any class. ok is false
+// when a dot leaves either side not an identifier, as in ".foo" or "Foo.".
+func splitQualified(name string) (parent, member string, ok bool) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return "", name, true
+	}
+	parent, member = name[:i], name[i+1:]
+	return parent, member, identifierPattern.MatchString(parent) && identifierPattern.MatchString(member)
+}
+
+// Find filters entries by q. Exact-case name matches come first, then exported
+// symbols, then path and line order. limit <= 0 returns every match.
+func Find(entries []index.SymbolEntry, q Query, limit int) ([]Symbol, error) {
+	name := strings.TrimSpace(q.Name)
+	kind := strings.ToLower(strings.TrimSpace(q.Kind))
+	if name == "" && kind == "" {
+		return nil, fmt.Errorf("invalid symbols request: name or kind is required")
+	}
+	if kind != "" {
+		if _, ok := kinds[kind]; !ok {
+			return nil, fmt.Errorf("invalid symbols request: unknown kind %q", q.Kind)
+		}
+	}
+	parent, name, ok := splitQualified(name)
+	if !ok {
+		return nil, fmt.Errorf("invalid symbols request: name must be an identifier or Class.member")
+	}
+
+	var matches []index.SymbolEntry
+	for _, e := range entries {
+		if kind != "" && e.Kind != kind {
+			continue
+		}
+		if name != "" && !strings.EqualFold(e.Name, name) {
+			continue
+		}
+		if parent != "" && !strings.EqualFold(e.Parent, parent) {
+			continue
+		}
+		matches = append(matches, e)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if ea, eb := a.Name == name, b.Name == name; ea != eb {
+			return ea
+		}
+		if a.Exported != b.Exported {
+			return a.Exported
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.StartLine < b.StartLine
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return toSymbols(matches), nil
+}
+
+func toSymbols(entries []index.SymbolEntry) []Symbol {
+	out := make([]Symbol, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, Symbol{
+			Name:      e.Name,
+			Kind:      e.Kind,
+			Parent:    e.Parent,
+			Exported:  e.Exported,
+			Path:      e.Path,
+			StartLine: e.StartLine,
+			EndLine:   e.EndLine,
+		})
+	}
+	return out
+}
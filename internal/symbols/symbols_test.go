@@ -0,0 +1,196 @@
+package symbols
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/memkit/repodex/internal/config"
+	"github.com/memkit/repodex/internal/hash"
+	"github.com/memkit/repodex/internal/index"
+	"github.com/memkit/repodex/internal/lang"
+	"github.com/memkit/repodex/internal/store"
+)
+
+func TestFindByNameAndKind(t *testing.T) {
+	entries := []index.SymbolEntry{
+		{FileID: 1, Path: "a.ts", Name: "userRepository", Kind: lang.KindVariable, StartLine: 1, EndLine: 1},
+		{FileID: 2, Path: "b.ts", Name: "UserRepository", Kind: lang.KindClass, StartLine: 3, EndLine: 20},
+		{FileID: 2, Path: "b.ts", Name: "find", Kind: lang.KindMethod, Parent: "UserRepository", Exported: true, StartLine: 4, EndLine: 6},
+		{FileID: 3, Path: "c.ts", Name: "UserRepository", Kind: lang.KindClass, Exported: true, StartLine: 1, EndLine: 9},
+		{FileID: 3, Path: "c.ts", Name: "find", Kind: lang.KindMethod, Parent: "OrderRepository", StartLine: 12, EndLine: 14},
+	}
+
+	got, err := Find(entries, Query{Name: "UserRepository"}, 0)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(got) != 3 || got[0].Path != "c.ts" || got[1].Path != "b.ts" || got[2].Kind != lang.KindVariable {
+		t.Fatalf("unexpected order %+v", got)
+	}
+
+	got, err = Find(entries, Query{Name: "userrepository", Kind: "class"}, 1)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(got) != 1 || got[0].Path != "c.ts" {
+		t.Fatalf("expected exported class first, got %+v", got)
+	}
+
+	got, err = Find(entries, Query{Name: "UserRepository.find"}, 0)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(got) != 1 || got[0].StartLine != 4 {
+		t.Fatalf("expected method scoped to class, got %+v", got)
+	}
+
+	if _, err := Find(entries, Query{}, 0); err == nil {
+		t.Fatalf("expected error for empty query")
+	}
+	if _, err := Find(entries, Query{Kind: "struct"}, 0); err == nil {
+		t.Fatalf("expected error for unknown kind")
+	}
+}
+
+func TestDefinitionsPreferContext(t *testing.T) {
+	entries := []index.SymbolEntry{
+		{Path: "a.ts", Name: "load", Kind: lang.KindFunction, StartLine: 1, EndLine: 3},
+		{Path: "b.ts", Name: "load", Kind: lang.KindFunction, StartLine: 2, EndLine: 4},
+		{Path: "b.ts", Name: "load", Kind: lang.KindMethod, Parent: "Store", StartLine: 20, EndLine: 22},
+		{Path: "c.ts", Name: "load", Kind: lang.KindFunction, Exported: true, StartLine: 5, EndLine: 9},
+		{Path: "d.ts", Name: "Load", Kind: lang.KindClass, StartLine: 1, EndLine: 9},
+	}
+	got, err := Definitions(entries, Target{Name: "load", Path: "b.ts", Line: 30}, 0)
+	if err != nil {
+		t.Fatalf("definitions: %v", err)
+	}
+	var order []string
+	for _, s := range got {
+		order = append(order, s.Path+":"+s.Kind)
+	}
+	// Names match case-insensitively, with exact-case declarations first.
+	want := []string{"b.ts:method", "b.ts:function", "c.ts:function", "a.ts:function", "d.ts:class"}
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected order %v", order)
+	}
+
+	got, err = Definitions(entries, Target{Name: "Store.load"}, 0)
+	if err != nil || len(got) != 1 || got[0].StartLine != 20 {
+		t.Fatalf("expected class-scoped method, got %+v (%v)", got, err)
+	}
+	if _, err := Definitions(entries, Target{Name: "load()"}, 0); err == nil {
+		t.Fatalf("expected error for non-identifier name")
+	}
+}
+
+func TestQualifiedNamesSplitAlikeInFindAndDefinitions(t *testing.T) {
+	entries := []index.SymbolEntry{
+		{Path: "a.ts", Name: "Store", Kind: lang.KindClass, StartLine: 1, EndLine: 9},
+		{Path: "a.ts", Name: "load", Kind: lang.KindMethod, Parent: "Store", StartLine: 2, EndLine: 4},
+	}
+	for _, name := range []string{"Store.load", "store.LOAD"} {
+		found, err := Find(entries, Query{Name: name}, 0)
+		if err != nil || len(found) != 1 || found[0].StartLine != 2 {
+			t.Fatalf("Find(%q) = %+v, %v", name, found, err)
+		}
+		defs, err := Definitions(entries, Target{Name: name}, 0)
+		if err != nil || len(defs) != 1 || defs[0].StartLine != 2 {
+			t.Fatalf("Definitions(%q) = %+v, %v", name, defs, err)
+		}
+	}
+	for _, name := range []string{".load", "Store.", "a.Store.load", "."} {
+		if _, err := Find(entries, Query{Name: name}, 0); err == nil || !strings.Contains(err.Error(), "Class.member") {
+			t.Fatalf("expected Find(%q) to reject the name, got %v", name, err)
+		}
+		if _, err := Definitions(entries, Target{Name: name}, 0); err == nil || !strings.Contains(err.Error(), "Class.member") {
+			t.Fatalf("expected Definitions(%q) to reject the name, got %v", name, err)
+		}
+	}
+}
+
+func TestReferencesFlagStaleFiles(t *testing.T) {
+	root := t.TempDir()
+	source := []byte("export function loadUser() {}\nloadUser();")
+	if err := os.WriteFile(filepath.Join(root, "a.ts"), source, 0o644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	files := []index.FileEntry{{FileID: 1, Path: "a.ts", Hash64: hash.Sum64(source)}}
+	chunks := []index.ChunkEntry{{ChunkID: 1, FileID: 1, Path: "a.ts", StartLine: 1, EndLine: 2}}
+	postings := map[string][]index.Posting{
+		"load": {{ChunkID: 1, TF: 2, Positions: []uint32{0, 2}}},
+		"user": {{ChunkID: 1, TF: 2, Positions: []uint32{1, 3}}},
+	}
+	if err := os.MkdirAll(store.Dir(root), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := index.Serialize(root, files, chunks, postings); err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+	reader, err := index.OpenReader(store.Current(root))
+	if err != nil {
+		t.Fatalf("open reader: %v", err)
+	}
+	defer reader.Close()
+	idx := Index{Chunks: reader.Chunks(), Terms: reader.Terms(), Postings: reader.Postings(), Files: files}
+	cfg := config.DefaultConfig()
+
+	resp, err := References(root, cfg, idx, Target{Name: "loadUser"})
+	if err != nil || len(resp.References) != 2 || resp.References[0].Stale {
+		t.Fatalf("expected two current references, got %+v (%v)", resp, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "a.ts"), []byte("// moved\nloadUser();"), 0o644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	resp, err = References(root, cfg, idx, Target{Name: "loadUser"})
+	if err != nil || len(resp.References) != 1 || !resp.References[0].Stale || resp.References[0].Source != "" {
+		t.Fatalf("expected a stale reference from the working tree, got %+v (%v)", resp, err)
+	}
+}
+
+func TestIdentifierColumnsWholeWord(t *testing.T) {
+	line := "const getUser = getUserById(getUser_id) + getUser + $getUser;"
+	got := identifierColumns(line, "getUser")
+	if len(got) != 2 || got[0] != 6 || got[1] != 42 {
+		t.Fatalf("unexpected columns %v", got)
+	}
+}
+
+func TestOutlineNestsMembersAndCoversChunks(t *testing.T) {
+	files := []index.FileEntry{{FileID: 1, Path: "src/a.ts"}, {FileID: 2, Path: "src/b.ts"}}
+	chunks := []index.ChunkEntry{
+		{ChunkID: 1, FileID: 1, Path: "src/a.ts", StartLine: 1, EndLine: 10},
+		{ChunkID: 2, FileID: 1, Path: "src/a.ts", StartLine: 8, EndLine: 30},
+		{ChunkID: 3, FileID: 2, Path: "src/b.ts", StartLine: 1, EndLine: 5},
+	}
+	entries := []index.SymbolEntry{
+		{FileID: 1, Path: "src/a.ts", Name: "helper", Kind: lang.KindFunction, StartLine: 1, EndLine: 3},
+		{FileID: 1, Path: "src/a.ts", Name: "Repo", Kind: lang.KindClass, Exported: true, StartLine: 5, EndLine: 25},
+		{FileID: 1, Path: "src/a.ts", Name: "find", Kind: lang.KindMethod, Parent: "Repo", Exported: true, StartLine: 6, EndLine: 9},
+		{FileID: 1, Path: "src/a.ts", Name: "save", Kind: lang.KindMethod, Parent: "Repo", Exported: true, StartLine: 12, EndLine: 20},
+		{FileID: 2, Path: "src/b.ts", Name: "other", Kind: lang.KindFunction, StartLine: 1, EndLine: 2},
+	}
+	out, err := Outline(files, chunks, entries, "./src/a.ts")
+	if err != nil {
+		t.Fatalf("outline: %v", err)
+	}
+	if out.Path != "src/a.ts" || len(out.Chunks) != 2 || len(out.Symbols) != 2 {
+		t.Fatalf("unexpected outline %+v", out)
+	}
+	class := out.Symbols[1]
+	if class.Name != "Repo" || len(class.Members) != 2 || fmt.Sprint(class.ChunkIDs) != "[1 2]" {
+		t.Fatalf("unexpected class entry %+v", class)
+	}
+	if fmt.Sprint(class.Members[0].ChunkIDs) != "[1 2]" || fmt.Sprint(class.Members[1].ChunkIDs) != "[2]" {
+		t.Fatalf("unexpected member chunks %+v", class.Members)
+	}
+	if fmt.Sprint(out.Symbols[0].ChunkIDs) != "[1]" {
+		t.Fatalf("unexpected function chunks %+v", out.Symbols[0])
+	}
+	if _, err := Outline(files, chunks, entries, "src/missing.ts"); err == nil {
+		t.Fatalf("expected error for unindexed path")
+	}
+}
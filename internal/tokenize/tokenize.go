@@ -60,6 +60,88 @@ func (t Tokenizer) TextWithState(text string, st *StringScanState) []string {
 	if t.cfg.TokenizeStringLiterals {
 		return t.Text(text)
 	}
+	return t.normalize(scanWithState(text, st))
+}
+
+// Sequence tokenizes text like Text but keeps every surviving token occurrence in
+// source order instead of returning a unique sorted set. It is used for term
+// frequency counting.
+func (t Tokenizer) Sequence(text string) []string {
+	raw := t.scan(text, t.cfg.TokenizeStringLiterals)
+	return t.filter(raw)
+}
+
+// SequenceWithState is the occurrence-preserving counterpart of TextWithState.
+func (t Tokenizer) SequenceWithState(text string, st *StringScanState) []string {
+	if t.cfg.TokenizeStringLiterals {
+		return t.Sequence(text)
+	}
+	return t.filter(scanWithState(text, st))
+}
+
+// Span is a normalized token and its byte range [Start, End) in the scanned text.
+type Span struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// SpansWithState tokenizes one line like SequenceWithState and reports where each
+// surviving token sits in the line. Pass the same state across consecutive lines
+// so multi-line string literals are skipped when TokenizeStringLiterals is off.
+func (t Tokenizer) SpansWithState(line string, st *StringScanState) []Span {
+	if st == nil {
+		st = &StringScanState{}
+	}
+	skipStrings := !t.cfg.TokenizeStringLiterals
+	var spans []Span
+	wordStart := -1
+	flush := func(end int) {
+		if wordStart < 0 {
+			return
+		}
+		offset := wordStart
+		for _, part := range splitIdentifier(line[wordStart:end]) {
+			if lower, ok := t.keep(part); ok {
+				spans = append(spans, Span{Term: lower, Start: offset, End: offset + len(part)})
+			}
+			offset += len(part)
+		}
+		wordStart = -1
+	}
+
+	for i, r := range line {
+		if skipStrings && st.InString {
+			switch {
+			case st.Escaped:
+				st.Escaped = false
+			case r == '\\':
+				st.Escaped = true
+			case r == st.Delim:
+				st.InString = false
+			}
+			continue
+		}
+		if skipStrings && (r == '\'' || r == '"' || r == '`') {
+			flush(i)
+			st.InString = true
+			st.Delim = r
+			st.Escaped = false
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if wordStart < 0 {
+				wordStart = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(line))
+	return spans
+}
+
+func scanWithState(text string, st *StringScanState) []string {
 	if st == nil {
 		st = &StringScanState{}
 	}
@@ -103,7 +185,7 @@ func (t Tokenizer) TextWithState(text string, st *StringScanState) []string {
 		flush()
 	}
 	flush()
-	return t.normalize(expandTokens(tokens))
+	return expandTokens(tokens)
 }
 
 // Path tokenizes a path, handling separators and extensions using the same
@@ -235,26 +317,8 @@ func splitIdentifier(tok string) []string {
 func (t Tokenizer) normalize(tokens []string) []string {
 	unique := make(map[string]struct{}, len(tokens))
 	for _, tok := range tokens {
-		lower := strings.ToLower(tok)
-		if lower == "" {
-			continue
-		}
-		if _, ok := t.stopWords[lower]; ok {
-			continue
-		}
-		length := utf8.RuneCountInString(lower)
-		if length > t.cfg.MaxTokenLen {
-			continue
-		}
-		if length < t.cfg.MinTokenLen {
-			if _, ok := t.allowShort[lower]; !ok {
-				continue
-			}
-		}
-		if isNumeric(lower) {
-			continue
-		}
-		if t.cfg.DropHexLen > 0 && length >= t.cfg.DropHexLen && isHex(lower) {
+		lower, ok := t.keep(tok)
+		if !ok {
 			continue
 		}
 		unique[lower] = struct{}{}
@@ -267,6 +331,81 @@ func (t Tokenizer) normalize(tokens []string) []string {
 	return out
 }
 
+func (t Tokenizer) filter(tokens []string) []string {
+	out := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		if lower, ok := t.keep(tok); ok {
+			out = append(out, lower)
+		}
+	}
+	return out
+}
+
+// Reasons reported by DropReason.
+const (
+	DropStopWord = "stop_word"
+	DropTooShort = "too_short"
+	DropTooLong  = "too_long"
+	DropNumeric  = "numeric"
+	DropHex      = "hex"
+)
+
+// Dropped is a raw token removed by normalization together with the rule that removed it.
+type Dropped struct {
+	Token  string
+	Reason string
+}
+
+// Inspect tokenizes text like Sequence and also reports the lowercased tokens
+// that the normalization rules removed, in source order.
+func (t Tokenizer) Inspect(text string) ([]string, []Dropped) {
+	var kept []string
+	var dropped []Dropped
+	for _, tok := range t.scan(text, t.cfg.TokenizeStringLiterals) {
+		if reason := t.DropReason(tok); reason != "" {
+			dropped = append(dropped, Dropped{Token: strings.ToLower(tok), Reason: reason})
+			continue
+		}
+		kept = append(kept, strings.ToLower(tok))
+	}
+	return kept, dropped
+}
+
+// DropReason returns why a raw token would be removed, or "" if it is kept.
+func (t Tokenizer) DropReason(tok string) string {
+	lower := strings.ToLower(tok)
+	if lower == "" {
+		return DropTooShort
+	}
+	if _, ok := t.stopWords[lower]; ok {
+		return DropStopWord
+	}
+	length := utf8.RuneCountInString(lower)
+	if length > t.cfg.MaxTokenLen {
+		return DropTooLong
+	}
+	if length < t.cfg.MinTokenLen {
+		if _, ok := t.allowShort[lower]; !ok {
+			return DropTooShort
+		}
+	}
+	if isNumeric(lower) {
+		return DropNumeric
+	}
+	if t.cfg.DropHexLen > 0 && length >= t.cfg.DropHexLen && isHex(lower) {
+		return DropHex
+	}
+	return ""
+}
+
+// keep lowercases a raw token and reports whether it survives the configured rules.
+func (t Tokenizer) keep(tok string) (string, bool) {
+	if t.DropReason(tok) != "" {
+		return "", false
+	}
+	return strings.ToLower(tok), true
+}
+
 func mergeUnique(groups ...[]string) []string {
 	total := 0
 	for _, g := range groups {
@@ -2,6 +2,7 @@ package tokenize
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/memkit/repodex/internal/config"
@@ -280,6 +281,20 @@ func TestTokenizerEscapedBacktickDoesNotCloseTemplate(t *testing.T) {
 	}
 }
 
+func TestTokenizerSequenceKeepsOccurrencesInOrder(t *testing.T) {
+	tok := New(newTestCfg())
+	got := tok.Sequence("userRepo.save(user) // user")
+	want := []string{"user", "repo", "save", "user", "user"}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected sequence %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("unexpected sequence %v, want %v", got, want)
+		}
+	}
+}
+
 func contains(tokens []string, want string) bool {
 	for _, tok := range tokens {
 		if tok == want {
@@ -288,3 +303,59 @@ func contains(tokens []string, want string) bool {
 	}
 	return false
 }
+
+func TestTokenizerInspectReportsDropReasons(t *testing.T) {
+	cfg := newTestCfg()
+	cfg.MinTokenLen = 3
+	tok := New(cfg)
+
+	kept, dropped := tok.Inspect("const getUserById 12345 deadbeefcafebabe xy")
+	if !reflect.DeepEqual(kept, []string{"get", "user", "id"}) {
+		t.Fatalf("unexpected kept tokens %v", kept)
+	}
+	reasons := make(map[string]string, len(dropped))
+	for _, d := range dropped {
+		reasons[d.Token] = d.Reason
+	}
+	want := map[string]string{
+		"const":            DropStopWord,
+		"12345":            DropNumeric,
+		"deadbeefcafebabe": DropHex,
+		"by":               DropTooShort,
+		"xy":               DropTooShort,
+	}
+	for token, reason := range want {
+		if reasons[token] != reason {
+			t.Fatalf("expected %s to be dropped as %s, got %q", token, reason, reasons[token])
+		}
+	}
+}
+
+func TestTokenizerSpansWithStateReportsByteRanges(t *testing.T) {
+	cfg := newTestCfg()
+	cfg.TokenizeStringLiterals = false
+	tok := New(cfg)
+
+	line := `const fooBar = "skip me" + bazQux`
+	got := tok.SpansWithState(line, &StringScanState{})
+	want := []Span{
+		{Term: "foo", Start: 6, End: 9},
+		{Term: "bar", Start: 9, End: 12},
+		{Term: "baz", Start: 27, End: 30},
+		{Term: "qux", Start: 30, End: 33},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected spans %+v", got)
+	}
+	for _, sp := range got {
+		if strings.ToLower(line[sp.Start:sp.End]) != sp.Term {
+			t.Fatalf("span %+v does not cover its term", sp)
+		}
+	}
+
+	st := &StringScanState{}
+	tok.SpansWithState("const s = `open", st)
+	if got := tok.SpansWithState("still inside` + after", st); len(got) != 1 || got[0].Term != "after" {
+		t.Fatalf("expected string state to carry across lines, got %+v", got)
+	}
+}